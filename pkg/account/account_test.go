@@ -310,3 +310,20 @@ func TestManualUpdateAccount(t *testing.T) {
 	}
 	t.Logf("Successfully updated account %s to name %s", targetID, updated.Name)
 }
+
+func TestNormalizeClass(t *testing.T) {
+	for name, want := range map[string]string{
+		"Asset":        ClassAssets,
+		" liabilities": ClassLiabilities,
+		"Capital":      ClassEquity,
+		"Revenue":      ClassIncome,
+		"EXPENSES":     ClassExpenses,
+	} {
+		if got, err := NormalizeClass(name); err != nil || got != want {
+			t.Fatalf("NormalizeClass(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := NormalizeClass("Suspense"); err == nil {
+		t.Fatal("expected an unknown class to be rejected")
+	}
+}
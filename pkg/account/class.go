@@ -0,0 +1,35 @@
+package account
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The five account classes, in the order they appear on a trial balance.
+const (
+	ClassAssets      = "Assets"
+	ClassLiabilities = "Liabilities"
+	ClassEquity      = "Equity"
+	ClassIncome      = "Income"
+	ClassExpenses    = "Expenses"
+)
+
+// NormalizeClass maps a Tigg account class name to one of the five classes.
+// Matching is case-insensitive and on prefix, so "Asset" and "Assets" both
+// map to ClassAssets; "Capital" and "Revenue" are taken as Equity and Income.
+func NormalizeClass(name string) (string, error) {
+	c := strings.ToLower(strings.TrimSpace(name))
+	switch {
+	case strings.HasPrefix(c, "asset"):
+		return ClassAssets, nil
+	case strings.HasPrefix(c, "liabilit"):
+		return ClassLiabilities, nil
+	case strings.HasPrefix(c, "equity"), strings.HasPrefix(c, "capital"):
+		return ClassEquity, nil
+	case strings.HasPrefix(c, "income"), strings.HasPrefix(c, "revenue"):
+		return ClassIncome, nil
+	case strings.HasPrefix(c, "expense"):
+		return ClassExpenses, nil
+	}
+	return "", fmt.Errorf("unknown account class %q", name)
+}
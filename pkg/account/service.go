@@ -13,16 +13,33 @@ import (
 
 	"github.com/rohankarmacharya/TigIntegration/pkg/client"
 	"github.com/rohankarmacharya/TigIntegration/pkg/errors"
+	"github.com/rohankarmacharya/TigIntegration/pkg/idempotency"
 )
 
 type Service struct {
 	client *client.TiggClient
+	idem   idempotency.Key
 }
 
 func NewService(c *client.TiggClient) *Service {
 	return &Service{client: c}
 }
 
+// WithIdempotencyKey returns a copy of the service whose mutating calls are
+// recorded in store under key and the account they act on: its code for a
+// create, its ID otherwise. Retrying a call on the copy returns the first
+// result instead of repeating it; see idempotency.Do.
+func (s *Service) WithIdempotencyKey(store idempotency.Store, key string) *Service {
+	return s.withKey(idempotency.Key{Store: store, Value: key})
+}
+
+// withKey returns a copy of the service sending k as its idempotency key.
+func (s *Service) withKey(k idempotency.Key) *Service {
+	out := *s
+	out.idem = k
+	return &out
+}
+
 type accountListResponse struct {
 	Data []Account `json:"data"`
 }
@@ -103,12 +120,27 @@ func (s *Service) signPayload(method, url string, payload interface{}) (*http.Re
 	}
 
 	s.client.AddHeaders(req)
+	if s.idem.Enabled() {
+		req.Header.Set(idempotency.Header, s.idem.Value)
+	}
 	req.Header.Set("X-Nonce", nonce)
 	req.Header.Set("X-Timestamp", fmt.Sprintf("%d", timestampMs))
 
 	return req, nil
 }
+
+// CreateAccount sends POST /accounts request to Tigg. With an idempotency key,
+// a retry after an unknown outcome first looks the account up by code.
 func (s *Service) CreateAccount(acc Account) (*Account, error) {
+	k := s.idem.For(acc.Code)
+	return idempotency.Do(k, "account.create", acc, func() (*Account, error) {
+		return s.withKey(k).createAccount(acc)
+	}, func() (*Account, error) {
+		return s.findAccountByCode(acc.Code)
+	})
+}
+
+func (s *Service) createAccount(acc Account) (*Account, error) {
 	url := fmt.Sprintf("%s/accounts", s.client.BaseURL)
 
 	req, err := s.signPayload("POST", url, acc)
@@ -135,6 +167,14 @@ func (s *Service) CreateAccount(acc Account) (*Account, error) {
 
 // UpdateAccount sends POST /accounts/{id} request to Tigg to update an existing account.
 func (s *Service) UpdateAccount(id string, acc UpdateAccountRequest) (*Account, error) {
+	acc.ID = id
+	k := s.idem.For(id)
+	return idempotency.Do(k, "account.update", acc, func() (*Account, error) {
+		return s.withKey(k).updateAccount(id, acc)
+	}, nil)
+}
+
+func (s *Service) updateAccount(id string, acc UpdateAccountRequest) (*Account, error) {
 	if id == "" {
 		return nil, fmt.Errorf("id is required for update to prevent duplicate creation")
 	}
@@ -190,6 +230,18 @@ func (s *Service) GetAccountByID(id string) (*Account, error) {
 
 // GetAccountByCode performs a ListAccounts call and searches by exact code.
 func (s *Service) GetAccountByCode(code string) (*Account, error) {
+	acc, err := s.findAccountByCode(code)
+	if err != nil {
+		return nil, err
+	}
+	if acc == nil {
+		return nil, fmt.Errorf("account with code %q not found", code)
+	}
+	return acc, nil
+}
+
+// findAccountByCode is GetAccountByCode returning nil when there is no match.
+func (s *Service) findAccountByCode(code string) (*Account, error) {
 	accounts, err := s.ListAccounts()
 	if err != nil {
 		return nil, err
@@ -200,12 +252,18 @@ func (s *Service) GetAccountByCode(code string) (*Account, error) {
 			return &accounts[i], nil
 		}
 	}
-
-	return nil, fmt.Errorf("account with code %q not found", code)
+	return nil, nil
 }
 
 // ActivateAccount sends PATCH /accounts/{id}/active request to Tigg
 func (s *Service) ActivateAccount(id string) (*Account, error) {
+	k := s.idem.For(id)
+	return idempotency.Do(k, "account.activate", id, func() (*Account, error) {
+		return s.withKey(k).activateAccount(id)
+	}, nil)
+}
+
+func (s *Service) activateAccount(id string) (*Account, error) {
 	url := fmt.Sprintf("%s/accounts/%s/active", s.client.BaseURL, id)
 
 	req, err := s.signPayload("PATCH", url, map[string]string{})
@@ -228,6 +286,13 @@ func (s *Service) ActivateAccount(id string) (*Account, error) {
 
 // DeactivateAccount sends PATCH /accounts/{id}/inactive request to Tigg
 func (s *Service) DeactivateAccount(id string) (*Account, error) {
+	k := s.idem.For(id)
+	return idempotency.Do(k, "account.deactivate", id, func() (*Account, error) {
+		return s.withKey(k).deactivateAccount(id)
+	}, nil)
+}
+
+func (s *Service) deactivateAccount(id string) (*Account, error) {
 	url := fmt.Sprintf("%s/accounts/%s/inactive", s.client.BaseURL, id)
 
 	req, err := s.signPayload("PATCH", url, map[string]string{})
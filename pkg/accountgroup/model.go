@@ -1,5 +1,7 @@
 package accountgroup
 
+import "github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+
 // AccountGroup represents the response model returned by the Tigg API.
 type AccountGroup struct {
 	ID        string `json:"id"`
@@ -18,7 +20,7 @@ type AccountGroup struct {
 	Description string `json:"description"`
 	Inactive    bool   `json:"inactive"`
 
-	CreatedAt string `json:"created_at"`
+	CreatedAt calendar.Timestamp `json:"created_at"`
 }
 
 // CreateAccountGroupRequest is the payload used when creating an account group.
@@ -13,11 +13,13 @@ import (
 
 	"github.com/rohankarmacharya/TigIntegration/pkg/client"
 	"github.com/rohankarmacharya/TigIntegration/pkg/errors"
+	"github.com/rohankarmacharya/TigIntegration/pkg/idempotency"
 )
 
 // Service wraps the Tigg client
 type Service struct {
 	client *client.TiggClient
+	idem   idempotency.Key
 }
 
 // NewService constructor makes it reusable
@@ -25,6 +27,21 @@ func NewService(c *client.TiggClient) *Service {
 	return &Service{client: c}
 }
 
+// WithIdempotencyKey returns a copy of the service whose mutating calls are
+// recorded in store under key and the group they act on: its parent and name
+// for a create, its ID otherwise. Retrying a call on the copy returns the
+// first result instead of repeating it; see idempotency.Do.
+func (s *Service) WithIdempotencyKey(store idempotency.Store, key string) *Service {
+	return s.withKey(idempotency.Key{Store: store, Value: key})
+}
+
+// withKey returns a copy of the service sending k as its idempotency key.
+func (s *Service) withKey(k idempotency.Key) *Service {
+	out := *s
+	out.idem = k
+	return &out
+}
+
 // ListAccountGroups
 type accountGroupListResponse struct {
 	Data []AccountGroup `json:"data"`
@@ -116,13 +133,40 @@ func (s *Service) signPayload(method, url string, payload interface{}) (*http.Re
 	}
 
 	s.client.AddHeaders(req)
+	if s.idem.Enabled() {
+		req.Header.Set(idempotency.Header, s.idem.Value)
+	}
 	req.Header.Set("X-Nonce", nonce)
 	req.Header.Set("X-Timestamp", fmt.Sprintf("%d", timestampMs))
 
 	return req, nil
 }
 
+// CreateAccountGroup sends POST /account-groups request to Tigg. With an
+// idempotency key, a retry after an unknown outcome first looks the group up by name.
 func (s *Service) CreateAccountGroup(reqBody CreateAccountGroupRequest) (*AccountGroup, error) {
+	k := s.idem.For(groupResource(reqBody))
+	return idempotency.Do(k, "account_group.create", reqBody, func() (*AccountGroup, error) {
+		return s.withKey(k).createAccountGroup(reqBody)
+	}, func() (*AccountGroup, error) {
+		return s.findAccountGroupByName(reqBody.Name)
+	})
+}
+
+// groupResource names a new group by its parent and name, as names need only
+// be unique under one parent.
+func groupResource(g CreateAccountGroupRequest) string {
+	parent := ""
+	switch {
+	case g.ParentGroupID != nil:
+		parent = *g.ParentGroupID
+	case g.ParentGroupName != nil:
+		parent = *g.ParentGroupName
+	}
+	return parent + ":" + g.Name
+}
+
+func (s *Service) createAccountGroup(reqBody CreateAccountGroupRequest) (*AccountGroup, error) {
 	url := fmt.Sprintf("%s/account-groups", s.client.BaseURL)
 	req, err := s.signPayload("POST", url, reqBody)
 	if err != nil {
@@ -181,6 +225,18 @@ func (s *Service) GetAccountGroupByID(id string) (*AccountGroup, error) {
 
 // GetAccountGroupByName performs a ListAccountGroups call and searches by exact name.
 func (s *Service) GetAccountGroupByName(name string) (*AccountGroup, error) {
+	group, err := s.findAccountGroupByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, fmt.Errorf("account group with name %q not found", name)
+	}
+	return group, nil
+}
+
+// findAccountGroupByName is GetAccountGroupByName returning nil when there is no match.
+func (s *Service) findAccountGroupByName(name string) (*AccountGroup, error) {
 	groups, err := s.ListAccountGroups()
 	if err != nil {
 		return nil, err
@@ -191,12 +247,19 @@ func (s *Service) GetAccountGroupByName(name string) (*AccountGroup, error) {
 			return &groups[i], nil
 		}
 	}
-
-	return nil, fmt.Errorf("account group with name %q not found", name)
+	return nil, nil
 }
 
 // UpdateAccountGroup
 func (s *Service) UpdateAccountGroup(id string, reqBody UpdateAccountGroupRequest) (*AccountGroup, error) {
+	reqBody.ID = id
+	k := s.idem.For(id)
+	return idempotency.Do(k, "account_group.update", reqBody, func() (*AccountGroup, error) {
+		return s.withKey(k).updateAccountGroup(id, reqBody)
+	}, nil)
+}
+
+func (s *Service) updateAccountGroup(id string, reqBody UpdateAccountGroupRequest) (*AccountGroup, error) {
 	if id == "" {
 		return nil, fmt.Errorf("id is required for update to prevent duplicate creation")
 	}
@@ -228,6 +291,13 @@ func (s *Service) UpdateAccountGroup(id string, reqBody UpdateAccountGroupReques
 // Activate/Deactivate AccountGroup
 // ActivateAccountGroup
 func (s *Service) ActivateAccountGroup(id string) (*AccountGroup, error) {
+	k := s.idem.For(id)
+	return idempotency.Do(k, "account_group.activate", id, func() (*AccountGroup, error) {
+		return s.withKey(k).activateAccountGroup(id)
+	}, nil)
+}
+
+func (s *Service) activateAccountGroup(id string) (*AccountGroup, error) {
 	url := fmt.Sprintf("%s/account-groups/%s/active", s.client.BaseURL, id)
 	// Even if there's no body content, we might need the signature wrapper.
 	// Passing an empty struct or nil. Let's try passing empty struct to ensure signature fields are added.
@@ -251,6 +321,13 @@ func (s *Service) ActivateAccountGroup(id string) (*AccountGroup, error) {
 }
 
 func (s *Service) DeactivateAccountGroup(id string) (*AccountGroup, error) {
+	k := s.idem.For(id)
+	return idempotency.Do(k, "account_group.deactivate", id, func() (*AccountGroup, error) {
+		return s.withKey(k).deactivateAccountGroup(id)
+	}, nil)
+}
+
+func (s *Service) deactivateAccountGroup(id string) (*AccountGroup, error) {
 	url := fmt.Sprintf("%s/account-groups/%s/inactive", s.client.BaseURL, id)
 	req, err := s.signPayload("PATCH", url, map[string]string{})
 	if err != nil {
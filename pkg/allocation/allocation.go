@@ -0,0 +1,276 @@
+package allocation
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// Basis is how a rule's target weights are interpreted.
+type Basis string
+
+const (
+	// Percent weights are fixed percentages that must add up to 100.
+	Percent Basis = "PERCENT"
+	// Driver weights are quantities such as headcount or floor area. They can
+	// be replaced on every run; see Allocate.
+	Driver Basis = "DRIVER"
+)
+
+// Target is one destination of an allocation.
+type Target struct {
+	// Key identifies the target in driver values and RemainderTarget. It
+	// defaults to AccountCode.
+	Key         string            `json:"key,omitempty"`
+	AccountCode string            `json:"account_code"`
+	Dimensions  map[string]string `json:"dimensions,omitempty"`
+	// Weight is a decimal such as "33.33" or "12".
+	Weight string `json:"weight,omitempty"`
+}
+
+func (t Target) key() string {
+	if t.Key != "" {
+		return t.Key
+	}
+	return t.AccountCode
+}
+
+// Rule spreads an amount from a source account over targets.
+type Rule struct {
+	ID                string            `json:"id"`
+	Name              string            `json:"name,omitempty"`
+	SourceAccountCode string            `json:"source_account_code"`
+	SourceDimensions  map[string]string `json:"source_dimensions,omitempty"`
+	Basis             Basis             `json:"basis"`
+	Targets           []Target          `json:"targets"`
+	// RemainderTarget is the key of the target that absorbs the minor units
+	// lost to rounding. If empty they go to the targets with the largest
+	// rounding remainders, earliest target first.
+	RemainderTarget string `json:"remainder_target,omitempty"`
+	Narration       string `json:"narration,omitempty"`
+}
+
+// Share is the part of an allocation that goes to one target.
+type Share struct {
+	Target Target
+	Amount money.Money
+}
+
+// Validate checks the rule's structure. Weights are checked by Allocate, as
+// driver weights may be supplied per run.
+func (r Rule) Validate() error {
+	if r.SourceAccountCode == "" {
+		return fmt.Errorf("allocation rule %s: source account code is required", r.ID)
+	}
+	if r.Basis != Percent && r.Basis != Driver {
+		return fmt.Errorf("allocation rule %s: basis must be %s or %s, got %q", r.ID, Percent, Driver, r.Basis)
+	}
+	if len(r.Targets) == 0 {
+		return fmt.Errorf("allocation rule %s: at least one target is required", r.ID)
+	}
+	seen := map[string]bool{}
+	for _, t := range r.Targets {
+		if t.AccountCode == "" {
+			return fmt.Errorf("allocation rule %s: target account code is required", r.ID)
+		}
+		if seen[t.key()] {
+			return fmt.Errorf("allocation rule %s: duplicate target %q", r.ID, t.key())
+		}
+		seen[t.key()] = true
+	}
+	if r.RemainderTarget != "" && !seen[r.RemainderTarget] {
+		return fmt.Errorf("allocation rule %s: remainder target %q is not a target", r.ID, r.RemainderTarget)
+	}
+	return nil
+}
+
+// weights returns the integer ratios of the targets. drivers overrides the
+// stored weight of the targets it names.
+func (r Rule) weights(drivers map[string]string) ([]int64, error) {
+	rats := make([]*big.Rat, len(r.Targets))
+	total := new(big.Rat)
+	for i, t := range r.Targets {
+		w := t.Weight
+		if d, ok := drivers[t.key()]; ok {
+			w = d
+		}
+		rat, ok := new(big.Rat).SetString(w)
+		if !ok || rat.Sign() < 0 {
+			return nil, fmt.Errorf("allocation rule %s: target %s has invalid weight %q", r.ID, t.key(), w)
+		}
+		rats[i] = rat
+		total.Add(total, rat)
+	}
+	if total.Sign() == 0 {
+		return nil, fmt.Errorf("allocation rule %s: weights sum to zero", r.ID)
+	}
+	if r.Basis == Percent && total.Cmp(big.NewRat(100, 1)) != 0 {
+		return nil, fmt.Errorf("allocation rule %s: percentages sum to %s, expected 100", r.ID, total.FloatString(4))
+	}
+
+	// Scale every weight by the common denominator so they become integers.
+	denom := big.NewInt(1)
+	for _, rat := range rats {
+		d := rat.Denom()
+		gcd := new(big.Int).GCD(nil, nil, denom, d)
+		denom.Mul(denom, new(big.Int).Quo(d, gcd))
+	}
+	out := make([]int64, len(rats))
+	for i, rat := range rats {
+		n := new(big.Int).Mul(rat.Num(), new(big.Int).Quo(denom, rat.Denom()))
+		if !n.IsInt64() {
+			return nil, fmt.Errorf("allocation rule %s: weights are too precise", r.ID)
+		}
+		out[i] = n.Int64()
+	}
+	return out, nil
+}
+
+// Allocate splits amount over the rule's targets. The shares always add up to
+// amount exactly. drivers replaces target weights by key for this run, e.g.
+// this month's headcount per cost center.
+func Allocate(rule Rule, amount money.Money, drivers map[string]string) ([]Share, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	weights, err := rule.weights(drivers)
+	if err != nil {
+		return nil, err
+	}
+
+	var amounts []money.Money
+	if rule.RemainderTarget == "" {
+		if amounts, err = amount.Allocate(weights...); err != nil {
+			return nil, err
+		}
+	} else {
+		amounts, err = allocateWithRemainder(rule, amount, weights)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	shares := make([]Share, len(rule.Targets))
+	for i, t := range rule.Targets {
+		shares[i] = Share{Target: t, Amount: amounts[i]}
+	}
+	return shares, nil
+}
+
+// allocateWithRemainder gives every target except RemainderTarget its share
+// of the amount their weights cover, rounded towards zero and split by
+// largest remainder, and gives the remainder target what is left. The
+// remainder target therefore absorbs the rounding and never changes sign.
+func allocateWithRemainder(rule Rule, amount money.Money, weights []int64) ([]money.Money, error) {
+	var total, others int64
+	var ratios []int64
+	remainderAt := -1
+	for i, t := range rule.Targets {
+		total += weights[i]
+		if t.key() == rule.RemainderTarget {
+			remainderAt = i
+			continue
+		}
+		others += weights[i]
+		ratios = append(ratios, weights[i])
+	}
+
+	amounts := make([]money.Money, len(weights))
+	zero := money.Zero(amount.Currency())
+	for i := range amounts {
+		amounts[i] = zero
+	}
+	left := amount
+	if others > 0 {
+		places := max(amount.Scale(), money.Precision(amount.Currency()))
+		covered := amount.MulRat(big.NewRat(others, total), places, money.RoundDown)
+		split, err := covered.Allocate(ratios...)
+		if err != nil {
+			return nil, err
+		}
+		for i, j := 0, 0; i < len(amounts); i++ {
+			if i == remainderAt {
+				continue
+			}
+			amounts[i] = split[j]
+			j++
+		}
+		if left, err = amount.Sub(covered); err != nil {
+			return nil, err
+		}
+	}
+	if left.Sign()*amount.Sign() < 0 {
+		return nil, fmt.Errorf("allocation rule %s: remainder %s has the opposite sign of %s", rule.ID, left, amount)
+	}
+	amounts[remainderAt] = left
+	return amounts, nil
+}
+
+// Engine turns allocation rules into journal vouchers.
+type Engine struct {
+	accounts journal.AccountLister
+}
+
+func NewEngine(accounts journal.AccountLister) *Engine {
+	return &Engine{accounts: accounts}
+}
+
+// Voucher allocates amount with rule and builds a balanced voucher that
+// credits the source account and debits every target with its share. A
+// negative amount reverses the direction. Targets with a zero share are left out.
+func (e *Engine) Voucher(ctx context.Context, rule Rule, code string, date calendar.Date, amount money.Money, drivers map[string]string) (*journal.JournalVoucher, error) {
+	shares, err := Allocate(rule, amount, drivers)
+	if err != nil {
+		return nil, err
+	}
+
+	narration := rule.Narration
+	if narration == "" {
+		narration = fmt.Sprintf("Allocation %s", rule.ID)
+	}
+	b := journal.NewBuilder(e.accounts, code, date, amount.Currency()).Narration(narration)
+
+	reverse := amount.Sign() < 0
+	source, debit, credit := amount, b.Debit, b.Credit
+	if reverse {
+		source, debit, credit = amount.Neg(), credit, debit
+	}
+	credit(rule.SourceAccountCode, source, "")
+	for name, value := range rule.SourceDimensions {
+		b.Tag(name, value)
+	}
+	for _, s := range shares {
+		if s.Amount.IsZero() {
+			continue
+		}
+		share := s.Amount
+		if reverse {
+			share = share.Neg()
+		}
+		if share.Sign() < 0 {
+			return nil, fmt.Errorf("allocation rule %s: target %s share %s runs against %s", rule.ID, s.Target.key(), s.Amount, amount)
+		}
+		debit(s.Target.AccountCode, share, "")
+		for name, value := range s.Target.Dimensions {
+			b.Tag(name, value)
+		}
+	}
+	return b.Build(ctx)
+}
+
+// Run loads the rule with the given id from store and builds its voucher; see
+// Engine.Voucher.
+func (e *Engine) Run(ctx context.Context, store RuleStore, id, code string, date calendar.Date, amount money.Money, drivers map[string]string) (*journal.JournalVoucher, error) {
+	rule, err := store.GetRule(id)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, fmt.Errorf("allocation rule %q not found", id)
+	}
+	return e.Voucher(ctx, *rule, code, date, amount, drivers)
+}
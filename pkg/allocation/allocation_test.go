@@ -0,0 +1,129 @@
+package allocation
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+type stubAccounts struct{}
+
+func (stubAccounts) ListAccounts() ([]account.Account, error) {
+	return []account.Account{
+		{ID: "acc-rent", Code: "EX0001"},
+		{ID: "acc-rent-alloc", Code: "EX0101"},
+	}, nil
+}
+
+func rentRule() Rule {
+	return Rule{
+		ID:                "rent",
+		SourceAccountCode: "EX0001",
+		Basis:             Percent,
+		Targets: []Target{
+			{Key: "KTM", AccountCode: "EX0101", Dimensions: map[string]string{"cost_center": "KTM"}, Weight: "33.33"},
+			{Key: "PKR", AccountCode: "EX0101", Dimensions: map[string]string{"cost_center": "PKR"}, Weight: "33.33"},
+			{Key: "BRT", AccountCode: "EX0101", Dimensions: map[string]string{"cost_center": "BRT"}, Weight: "33.34"},
+		},
+	}
+}
+
+func amounts(shares []Share) []string {
+	out := make([]string, len(shares))
+	for i, s := range shares {
+		out[i] = s.Amount.String()
+	}
+	return out
+}
+
+func TestAllocatePercent(t *testing.T) {
+	shares, err := Allocate(rentRule(), money.MustParse("100.00", "NPR"), nil)
+	if err != nil {
+		t.Fatalf("Allocate failed: %v", err)
+	}
+	if got := amounts(shares); got[0] != "33.33" || got[1] != "33.33" || got[2] != "33.34" {
+		t.Fatalf("unexpected shares %v", got)
+	}
+
+	rule := rentRule()
+	rule.Targets[2].Weight = "33.33"
+	if _, err := Allocate(rule, money.MustParse("100.00", "NPR"), nil); err == nil {
+		t.Fatal("expected percentages not adding up to 100 to be rejected")
+	}
+}
+
+func TestAllocateRemainderTarget(t *testing.T) {
+	rule := Rule{
+		ID:                "utilities",
+		SourceAccountCode: "EX0001",
+		Basis:             Driver,
+		Targets:           []Target{{Key: "A", AccountCode: "EX0101"}, {Key: "B", AccountCode: "EX0101"}, {Key: "C", AccountCode: "EX0101"}},
+		RemainderTarget:   "A",
+	}
+	amount := money.MustParse("100.00", "NPR")
+
+	// Equal headcount: B and C get 33.33 each and A absorbs the leftover paisa.
+	shares, err := Allocate(rule, amount, map[string]string{"A": "1", "B": "1", "C": "1"})
+	if err != nil {
+		t.Fatalf("Allocate failed: %v", err)
+	}
+	if got := amounts(shares); got[0] != "33.34" || got[1] != "33.33" || got[2] != "33.33" {
+		t.Fatalf("unexpected shares %v", got)
+	}
+
+	if _, err := Allocate(rule, amount, nil); err == nil {
+		t.Fatal("expected missing driver values to be rejected")
+	}
+
+	// Splitting a few paisa never drives the remainder negative, whichever
+	// way the amount runs.
+	rule.Targets = append(rule.Targets, Target{Key: "D", AccountCode: "EX0101"}, Target{Key: "E", AccountCode: "EX0101"})
+	even := map[string]string{"A": "1", "B": "1", "C": "1", "D": "1", "E": "1"}
+	for _, c := range []struct {
+		amount string
+		want   []string
+	}{
+		{"0.03", []string{"0.01", "0.01", "0.01", "0.00", "0.00"}},
+		{"-0.03", []string{"-0.01", "-0.01", "-0.01", "0.00", "0.00"}},
+	} {
+		shares, err := Allocate(rule, money.MustParse(c.amount, "NPR"), even)
+		if err != nil {
+			t.Fatalf("Allocate failed: %v", err)
+		}
+		if got := amounts(shares); strings.Join(got, " ") != strings.Join(c.want, " ") {
+			t.Errorf("%s: unexpected shares %v", c.amount, got)
+		}
+	}
+}
+
+func TestEngineVoucher(t *testing.T) {
+	store := NewFileRuleStore(filepath.Join(t.TempDir(), "rules.json"))
+	if err := store.PutRule(rentRule()); err != nil {
+		t.Fatalf("PutRule failed: %v", err)
+	}
+
+	date := calendar.MustParseDate("2081-04-32", calendar.BS)
+	jv, err := NewEngine(stubAccounts{}).Run(context.Background(), store, "rent", "ALLOC-RENT-2081-04", date, money.MustParse("45000.00", "NPR"), nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(jv.Items) != 4 || jv.Items[0].TxnType != journal.TxnTypeCredit || jv.Items[0].AccountID != "acc-rent" {
+		t.Fatalf("unexpected voucher items %+v", jv.Items)
+	}
+	if jv.Items[3].Dimensions["cost_center"] != "BRT" || jv.Items[3].Amount.String() != "15003.00" {
+		t.Fatalf("unexpected last line %+v", jv.Items[3])
+	}
+	if err := jv.Validate(); err != nil {
+		t.Fatalf("expected a balanced voucher, got %v", err)
+	}
+
+	if _, err := NewEngine(stubAccounts{}).Run(context.Background(), store, "missing", "X", date, money.MustParse("1", "NPR"), nil); err == nil {
+		t.Fatal("expected unknown rule to fail")
+	}
+}
@@ -0,0 +1,146 @@
+package allocation
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/internal/jsonfile"
+)
+
+// RuleStore persists allocation rules so they can be re-run every period.
+type RuleStore interface {
+	GetRule(id string) (*Rule, error)
+	PutRule(rule Rule) error
+	DeleteRule(id string) error
+	ListRules() ([]Rule, error)
+}
+
+// MemoryRuleStore keeps rules in memory.
+type MemoryRuleStore struct {
+	mu    sync.Mutex
+	rules map[string]Rule
+}
+
+func NewMemoryRuleStore() *MemoryRuleStore {
+	return &MemoryRuleStore{rules: map[string]Rule{}}
+}
+
+func (s *MemoryRuleStore) GetRule(id string) (*Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.rules[id]
+	if !ok {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+func (s *MemoryRuleStore) PutRule(rule Rule) error {
+	if rule.ID == "" {
+		return fmt.Errorf("allocation rule id is required")
+	}
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules[rule.ID] = rule
+	return nil
+}
+
+func (s *MemoryRuleStore) DeleteRule(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.rules, id)
+	return nil
+}
+
+func (s *MemoryRuleStore) ListRules() ([]Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedRules(s.rules), nil
+}
+
+func sortedRules(m map[string]Rule) []Rule {
+	out := make([]Rule, 0, len(m))
+	for _, r := range m {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// FileRuleStore persists rules in a JSON file keyed by rule ID.
+type FileRuleStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileRuleStore(path string) *FileRuleStore {
+	return &FileRuleStore{path: path}
+}
+
+func (s *FileRuleStore) load() (map[string]Rule, error) {
+	data := map[string]Rule{}
+	if err := jsonfile.Read(s.path, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *FileRuleStore) save(data map[string]Rule) error {
+	return jsonfile.Write(s.path, data)
+}
+
+func (s *FileRuleStore) GetRule(id string) (*Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	r, ok := data[id]
+	if !ok {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+func (s *FileRuleStore) PutRule(rule Rule) error {
+	if rule.ID == "" {
+		return fmt.Errorf("allocation rule id is required")
+	}
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := s.load()
+	if err != nil {
+		return err
+	}
+	data[rule.ID] = rule
+	return s.save(data)
+}
+
+func (s *FileRuleStore) DeleteRule(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := s.load()
+	if err != nil {
+		return err
+	}
+	delete(data, id)
+	return s.save(data)
+}
+
+func (s *FileRuleStore) ListRules() ([]Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return sortedRules(data), nil
+}
@@ -0,0 +1,251 @@
+package approval
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+var (
+	ErrSelfApproval     = fmt.Errorf("the maker of a voucher cannot approve it")
+	ErrAlreadyApproved  = fmt.Errorf("user has already approved this voucher")
+	ErrNotPending       = fmt.Errorf("approval request is not pending")
+	ErrVoucherChanged   = fmt.Errorf("voucher changed after it was submitted; submit it again")
+	ErrRequestNotFound  = fmt.Errorf("approval request not found")
+	ErrAlreadySubmitted = fmt.Errorf("voucher is already pending approval")
+)
+
+// Status is the state of an approval request.
+type Status string
+
+const (
+	StatusPending  Status = "PENDING"
+	StatusRejected Status = "REJECTED"
+	StatusPosted   Status = "POSTED"
+)
+
+// Action is what a recorded step did.
+type Action string
+
+const (
+	ActionSubmit  Action = "SUBMIT"
+	ActionApprove Action = "APPROVE"
+	ActionReject  Action = "REJECT"
+	ActionPost    Action = "POST"
+)
+
+// Step is one entry in a request's audit trail.
+type Step struct {
+	Action  Action    `json:"action"`
+	User    string    `json:"user"`
+	At      time.Time `json:"at"`
+	Comment string    `json:"comment,omitempty"`
+}
+
+// Request tracks one submission of a voucher. Approvals only count towards the
+// current submission; a rejected voucher must be submitted again.
+type Request struct {
+	VoucherID   string `json:"voucher_id"`
+	VoucherCode string `json:"voucher_code"`
+	Maker       string `json:"maker"`
+	Status      Status `json:"status"`
+	// Required is the number of approvals the policy asked for at submission.
+	Required int `json:"required"`
+	// Fingerprint is a hash of the voucher at submission, used to detect edits.
+	Fingerprint string `json:"fingerprint"`
+	Steps       []Step `json:"steps"`
+}
+
+// Approvers returns the users who approved the current submission.
+func (r Request) Approvers() []string {
+	var users []string
+	for _, s := range r.Steps {
+		switch s.Action {
+		case ActionSubmit:
+			users = nil
+		case ActionApprove:
+			users = append(users, s.User)
+		}
+	}
+	return users
+}
+
+// Tier requires Approvers approvals for vouchers whose total debits exceed Above.
+// Currency is the currency of Above. Amounts read from JSON carry none, so
+// when both are empty the threshold is taken in the voucher's own currency.
+type Tier struct {
+	Above     money.Money `json:"above"`
+	Currency  string      `json:"currency,omitempty"`
+	Approvers int         `json:"approvers"`
+}
+
+// threshold returns Above in the currency the tier applies to for jv.
+func (t Tier) threshold(jv journal.JournalVoucher) (money.Money, error) {
+	currency := firstNonEmpty(t.Currency, t.Above.Currency(), jv.CurrencyCode)
+	if currency == "" {
+		return money.Money{}, fmt.Errorf("approval tier above %s has no currency and voucher %s has none either", t.Above, jv.Code)
+	}
+	if c := t.Above.Currency(); c != "" && c != currency {
+		return money.Money{}, fmt.Errorf("approval tier above %s is in %s, not %s", t.Above, c, currency)
+	}
+	return t.Above.WithCurrency(currency), nil
+}
+
+// Policy decides how many approvals a voucher needs. Every voucher needs at
+// least one; the tier with the highest threshold the voucher exceeds wins.
+// Build one with NewPolicy or from JSON, which check and order its tiers.
+type Policy struct {
+	Tiers []Tier `json:"tiers"`
+}
+
+// NewPolicy returns a policy with tiers ordered from the highest threshold
+// down. All tiers must be in one currency, or all in none, so that their
+// thresholds can be ordered.
+func NewPolicy(tiers ...Tier) (Policy, error) {
+	var currency string
+	for i, t := range tiers {
+		c := firstNonEmpty(t.Currency, t.Above.Currency())
+		if a := t.Above.Currency(); a != "" && a != c {
+			return Policy{}, fmt.Errorf("approval tier above %s is in %s, not %s", t.Above, a, c)
+		}
+		if i > 0 && c != currency {
+			return Policy{}, fmt.Errorf("approval tiers mix currencies %q and %q", currency, c)
+		}
+		currency = c
+	}
+
+	sorted := append([]Tier(nil), tiers...)
+	var err error
+	sort.SliceStable(sorted, func(i, j int) bool {
+		c, cerr := sorted[i].Above.WithCurrency(currency).Cmp(sorted[j].Above.WithCurrency(currency))
+		if cerr != nil && err == nil {
+			err = cerr
+		}
+		return c > 0
+	})
+	if err != nil {
+		return Policy{}, err
+	}
+	return Policy{Tiers: sorted}, nil
+}
+
+// UnmarshalJSON reads a policy and checks it as NewPolicy does.
+func (p *Policy) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Tiers []Tier `json:"tiers"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	policy, err := NewPolicy(raw.Tiers...)
+	if err != nil {
+		return err
+	}
+	*p = policy
+	return nil
+}
+
+// Required returns the number of approvals jv needs. Tier thresholds are
+// compared with the voucher total in their currency, which must be the
+// voucher's currency or its base currency.
+func (p Policy) Required(jv journal.JournalVoucher) (int, error) {
+	for _, t := range p.Tiers {
+		above, err := t.threshold(jv)
+		if err != nil {
+			return 0, err
+		}
+		total, err := debitTotal(jv, above.Currency())
+		if err != nil {
+			return 0, err
+		}
+		c, err := total.Cmp(above)
+		if err != nil {
+			return 0, err
+		}
+		if c > 0 {
+			return max(t.Approvers, 1), nil
+		}
+	}
+	return 1, nil
+}
+
+func debitTotal(jv journal.JournalVoucher, currency string) (money.Money, error) {
+	base := currency != jv.CurrencyCode
+	if base && currency != jv.BaseCurrencyCode {
+		return money.Money{}, fmt.Errorf("approval tier currency %s does not match voucher %s currency %s", currency, jv.Code, jv.CurrencyCode)
+	}
+	total := money.Zero(currency)
+	for i, item := range jv.Items {
+		if item.TxnType != journal.TxnTypeDebit {
+			continue
+		}
+		amount := item.Amount
+		if base {
+			if item.BaseAmount == nil {
+				return money.Money{}, fmt.Errorf("voucher %s line %d has no %s amount to compare with the approval tier", jv.Code, i, currency)
+			}
+			amount = *item.BaseAmount
+		}
+		var err error
+		if total, err = total.Add(amount); err != nil {
+			return money.Money{}, err
+		}
+	}
+	return total, nil
+}
+
+func firstNonEmpty(s ...string) string {
+	for _, v := range s {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// fingerprint hashes the parts of a voucher that approvers sign off on.
+// Amounts are hashed by value, so "100" and "100.00" are the same.
+func fingerprint(jv journal.JournalVoucher) (string, error) {
+	type line struct {
+		Account    string
+		TxnType    string
+		Amount     string
+		BaseAmount string
+		Narration  string
+		Dimensions map[string]string
+	}
+	lines := make([]line, len(jv.Items))
+	for i, item := range jv.Items {
+		l := line{
+			Account:    item.AccountID + "|" + item.AccountCode,
+			TxnType:    item.TxnType,
+			Amount:     item.Amount.Rat().RatString(),
+			Narration:  item.Narration,
+			Dimensions: item.Dimensions,
+		}
+		if item.BaseAmount != nil {
+			l.BaseAmount = item.BaseAmount.Rat().RatString()
+		}
+		lines[i] = l
+	}
+	b, err := json.Marshal(struct {
+		Code         string
+		Date         string
+		CurrencyCode string
+		BaseCurrency string
+		Narration    string
+		Lines        []line
+	}{jv.Code, jv.Date.Format(calendar.AD), jv.CurrencyCode, jv.BaseCurrencyCode, jv.Narration, lines})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,229 @@
+package approval
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// fakeVouchers is an in-memory Poster.
+type fakeVouchers struct {
+	vouchers map[string]journal.JournalVoucher
+	posts    int
+}
+
+func (f *fakeVouchers) GetJournalVoucherByID(id string) (*journal.JournalVoucher, error) {
+	jv, ok := f.vouchers[id]
+	if !ok {
+		return nil, fmt.Errorf("journal voucher %s not found", id)
+	}
+	return &jv, nil
+}
+
+func (f *fakeVouchers) PostVoucher(jv journal.JournalVoucher) (*journal.JournalVoucher, error) {
+	f.posts++
+	jv = f.vouchers[jv.ID]
+	jv.VoucherStatus = journal.StatusPosted
+	f.vouchers[jv.ID] = jv
+	return &jv, nil
+}
+
+func draft(id, amount string) journal.JournalVoucher {
+	return journal.JournalVoucher{
+		ID:            id,
+		Code:          "JV-" + id,
+		Date:          calendar.MustParseDate("2024-07-16", calendar.AD),
+		CurrencyCode:  "NPR",
+		VoucherStatus: journal.StatusDraft,
+		Items: []journal.JournalVoucherItem{
+			{AccountID: "acc-rent", Amount: money.MustParse(amount, "NPR"), TxnType: journal.TxnTypeDebit},
+			{AccountID: "acc-bank", Amount: money.MustParse(amount, "NPR"), TxnType: journal.TxnTypeCredit},
+		},
+	}
+}
+
+func newWorkflow(store Store) (*Workflow, *fakeVouchers) {
+	fake := &fakeVouchers{vouchers: map[string]journal.JournalVoucher{
+		"small": draft("small", "5000.00"),
+		"large": draft("large", "250000.00"),
+	}}
+	policy := Policy{Tiers: []Tier{{Above: money.MustParse("100000", "NPR"), Approvers: 2}}}
+	w := NewWorkflow(fake, store, policy)
+	w.Now = func() time.Time { return time.Date(2024, 7, 16, 10, 0, 0, 0, time.UTC) }
+	return w, fake
+}
+
+func TestSingleApproval(t *testing.T) {
+	w, fake := newWorkflow(NewMemoryStore())
+
+	req, err := w.Submit("small", "maker", "July rent")
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if req.Required != 1 {
+		t.Fatalf("expected 1 required approval, got %d", req.Required)
+	}
+	if _, err := w.Submit("small", "maker", ""); !errors.Is(err, ErrAlreadySubmitted) {
+		t.Fatalf("expected ErrAlreadySubmitted, got %v", err)
+	}
+	if _, err := w.Approve("small", "maker", ""); !errors.Is(err, ErrSelfApproval) {
+		t.Fatalf("expected ErrSelfApproval, got %v", err)
+	}
+
+	req, err = w.Approve("small", "checker", "ok")
+	if err != nil {
+		t.Fatalf("Approve failed: %v", err)
+	}
+	if req.Status != StatusPosted || fake.posts != 1 {
+		t.Fatalf("expected voucher to be posted, got %s after %d posts", req.Status, fake.posts)
+	}
+	if len(req.Steps) != 3 || req.Steps[1].User != "checker" || req.Steps[1].Comment != "ok" || req.Steps[2].Action != ActionPost {
+		t.Fatalf("unexpected steps %+v", req.Steps)
+	}
+}
+
+func TestTieredApprovalSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "approvals.json")
+	w, fake := newWorkflow(NewFileStore(path))
+
+	if _, err := w.Submit("large", "maker", ""); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	req, err := w.Approve("large", "checker-1", "")
+	if err != nil {
+		t.Fatalf("Approve failed: %v", err)
+	}
+	if req.Status != StatusPending || req.Required != 2 || fake.posts != 0 {
+		t.Fatalf("expected a second approval to be needed, got %+v", req)
+	}
+	if _, err := w.Approve("large", "checker-1", ""); !errors.Is(err, ErrAlreadyApproved) {
+		t.Fatalf("expected ErrAlreadyApproved, got %v", err)
+	}
+
+	// A new workflow over the same file picks up where the first left off.
+	w2 := NewWorkflow(fake, NewFileStore(path), Policy{})
+	req, err = w2.Approve("large", "checker-2", "")
+	if err != nil {
+		t.Fatalf("Approve failed: %v", err)
+	}
+	if req.Status != StatusPosted || fake.posts != 1 {
+		t.Fatalf("expected voucher to be posted, got %s", req.Status)
+	}
+	if _, err := w2.Approve("large", "checker-3", ""); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("expected ErrNotPending, got %v", err)
+	}
+}
+
+func TestChangedVoucherIsNotPosted(t *testing.T) {
+	w, fake := newWorkflow(NewMemoryStore())
+	if _, err := w.Submit("small", "maker", ""); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	edited := fake.vouchers["small"]
+	edited.Items[0].Amount = money.MustParse("6000.00", "NPR")
+	edited.Items[1].Amount = money.MustParse("6000.00", "NPR")
+	fake.vouchers["small"] = edited
+
+	req, err := w.Approve("small", "checker", "")
+	if !errors.Is(err, ErrVoucherChanged) || req.Status != StatusRejected || fake.posts != 0 {
+		t.Fatalf("expected edited voucher to be rejected, got %v %+v", err, req)
+	}
+
+	// Resubmitting starts a fresh round of approvals.
+	req, err = w.Submit("small", "maker", "fixed amount")
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if len(req.Approvers()) != 0 {
+		t.Fatalf("expected approvals to reset, got %v", req.Approvers())
+	}
+	if req, err = w.Approve("small", "checker", ""); err != nil || req.Status != StatusPosted {
+		t.Fatalf("expected resubmitted voucher to be posted, got %v %+v", err, req)
+	}
+}
+
+func TestPolicyFromJSON(t *testing.T) {
+	// Amounts in JSON carry no currency, so a tier without one applies in the
+	// voucher's currency.
+	var p Policy
+	if err := json.Unmarshal([]byte(`{"tiers":[{"above":"100000","approvers":2},{"above":"1000000","approvers":3}]}`), &p); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	for id, want := range map[string]int{"small": 1, "large": 2, "huge": 3} {
+		amount := map[string]string{"small": "5000.00", "large": "250000.00", "huge": "2500000.00"}[id]
+		if got, err := p.Required(draft(id, amount)); err != nil || got != want {
+			t.Errorf("%s: expected %d approvals, got %d (%v)", id, want, got, err)
+		}
+	}
+
+	// A tier in the base currency compares base amounts and survives a round
+	// trip.
+	b, err := json.Marshal(Policy{Tiers: []Tier{{Above: money.MustParse("100000", "NPR"), Currency: "NPR", Approvers: 2}}})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	p = Policy{}
+	if err := json.Unmarshal(b, &p); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	jv := draft("usd", "2000.00")
+	jv.CurrencyCode, jv.BaseCurrencyCode = "USD", "NPR"
+	for i := range jv.Items {
+		jv.Items[i].Amount = money.MustParse("2000.00", "USD")
+		base := money.MustParse("266000.00", "NPR")
+		jv.Items[i].BaseAmount = &base
+	}
+	if got, err := p.Required(jv); err != nil || got != 2 {
+		t.Fatalf("expected 2 approvals, got %d (%v)", got, err)
+	}
+
+	jv.Items[0].BaseAmount = nil
+	if _, err := p.Required(jv); err == nil {
+		t.Fatal("expected an error for a debit without a base amount")
+	}
+
+	// Thresholds in different currencies cannot be ordered.
+	if err := json.Unmarshal([]byte(`{"tiers":[{"above":"100000","approvers":2},{"above":"1000000","currency":"NPR","approvers":3}]}`), &p); err == nil {
+		t.Fatal("expected tiers with and without a currency to be rejected")
+	}
+	if _, err := NewPolicy(Tier{Above: money.MustParse("100000", "NPR"), Approvers: 2}, Tier{Above: money.MustParse("1000", "USD"), Approvers: 3}); err == nil {
+		t.Fatal("expected tiers in NPR and USD to be rejected")
+	}
+}
+
+func TestConcurrentApprovals(t *testing.T) {
+	w, fake := newWorkflow(NewMemoryStore())
+	w.policy = Policy{Tiers: []Tier{{Above: money.MustParse("100000", "NPR"), Approvers: 5}}}
+	if _, err := w.Submit("large", "maker", ""); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := w.Approve("large", fmt.Sprintf("checker-%d", i), ""); err != nil {
+				t.Errorf("Approve failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	req, err := w.Request("large")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if len(req.Approvers()) != 5 || req.Status != StatusPosted || fake.posts != 1 {
+		t.Fatalf("expected 5 approvals and one post, got %v %s after %d posts", req.Approvers(), req.Status, fake.posts)
+	}
+}
@@ -0,0 +1,86 @@
+package approval
+
+import (
+	"sync"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/internal/jsonfile"
+)
+
+// Store persists approval requests by voucher ID.
+type Store interface {
+	GetRequest(voucherID string) (*Request, error)
+	PutRequest(r Request) error
+}
+
+// MemoryStore keeps requests in memory.
+type MemoryStore struct {
+	mu   sync.Mutex
+	data map[string]Request
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: map[string]Request{}}
+}
+
+func (s *MemoryStore) GetRequest(voucherID string) (*Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.data[voucherID]
+	if !ok {
+		return nil, nil
+	}
+	r.Steps = append([]Step(nil), r.Steps...)
+	return &r, nil
+}
+
+func (s *MemoryStore) PutRequest(r Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r.Steps = append([]Step(nil), r.Steps...)
+	s.data[r.VoucherID] = r
+	return nil
+}
+
+// FileStore persists requests in a JSON file keyed by voucher ID, so pending
+// approvals and their audit trail outlive the process.
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (s *FileStore) load() (map[string]Request, error) {
+	data := map[string]Request{}
+	if err := jsonfile.Read(s.path, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *FileStore) GetRequest(voucherID string) (*Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	r, ok := data[voucherID]
+	if !ok {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+func (s *FileStore) PutRequest(r Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := s.load()
+	if err != nil {
+		return err
+	}
+	data[r.VoucherID] = r
+	return jsonfile.Write(s.path, data)
+}
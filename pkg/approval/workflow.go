@@ -0,0 +1,222 @@
+package approval
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+)
+
+// Poster reads and posts journal vouchers. *journal.Service satisfies it.
+type Poster interface {
+	GetJournalVoucherByID(id string) (*journal.JournalVoucher, error)
+	PostVoucher(jv journal.JournalVoucher) (*journal.JournalVoucher, error)
+}
+
+// Workflow puts a maker-checker layer over posting: a draft is submitted by its
+// maker, signed off by approvers other than the maker, and posted only once
+// the policy's approval count is met.
+//
+// The workflow only controls posting that goes through it: journal.Service's
+// PostVoucher still posts a draft directly, so users who must not bypass
+// approval should not be given access to it. Calls on one voucher are
+// serialized within a Workflow; processes sharing a store need their own
+// coordination.
+type Workflow struct {
+	vouchers Poster
+	store    Store
+	policy   Policy
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+
+	// Now returns the time recorded on each step. It defaults to time.Now.
+	Now func() time.Time
+}
+
+func NewWorkflow(vouchers Poster, store Store, policy Policy) *Workflow {
+	return &Workflow{vouchers: vouchers, store: store, policy: policy, Now: time.Now}
+}
+
+// lock locks voucherID's request against concurrent changes and returns the
+// unlock function.
+func (w *Workflow) lock(voucherID string) func() {
+	w.mu.Lock()
+	if w.locks == nil {
+		w.locks = map[string]*sync.Mutex{}
+	}
+	l, ok := w.locks[voucherID]
+	if !ok {
+		l = &sync.Mutex{}
+		w.locks[voucherID] = l
+	}
+	w.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+// Submit puts a draft voucher up for approval. A rejected voucher can be
+// submitted again; earlier approvals do not carry over.
+func (w *Workflow) Submit(voucherID, maker, comment string) (*Request, error) {
+	defer w.lock(voucherID)()
+
+	jv, err := w.vouchers.GetJournalVoucherByID(voucherID)
+	if err != nil {
+		return nil, err
+	}
+	if jv.VoucherStatus != "" && jv.VoucherStatus != journal.StatusDraft {
+		return nil, fmt.Errorf("journal voucher %s is %s; only drafts can be submitted", voucherID, jv.VoucherStatus)
+	}
+
+	req, err := w.store.GetRequest(voucherID)
+	if err != nil {
+		return nil, err
+	}
+	if req != nil && req.Status == StatusPending {
+		return nil, ErrAlreadySubmitted
+	}
+	if req == nil {
+		req = &Request{VoucherID: voucherID}
+	}
+
+	required, err := w.policy.Required(*jv)
+	if err != nil {
+		return nil, err
+	}
+	fp, err := fingerprint(*jv)
+	if err != nil {
+		return nil, err
+	}
+	req.VoucherCode = jv.Code
+	req.Maker = maker
+	req.Status = StatusPending
+	req.Required = required
+	req.Fingerprint = fp
+	req.Steps = append(req.Steps, Step{Action: ActionSubmit, User: maker, At: w.Now(), Comment: comment})
+	if err := w.store.PutRequest(*req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// Approve records an approval. When the request has enough approvals the
+// voucher is checked against the submitted version and posted. If posting
+// fails the approval stays recorded; call Post to retry.
+func (w *Workflow) Approve(voucherID, approver, comment string) (*Request, error) {
+	defer w.lock(voucherID)()
+
+	req, err := w.pending(voucherID)
+	if err != nil {
+		return nil, err
+	}
+	if approver == req.Maker {
+		return nil, ErrSelfApproval
+	}
+	for _, u := range req.Approvers() {
+		if u == approver {
+			return nil, ErrAlreadyApproved
+		}
+	}
+
+	req.Steps = append(req.Steps, Step{Action: ActionApprove, User: approver, At: w.Now(), Comment: comment})
+	if err := w.store.PutRequest(*req); err != nil {
+		return nil, err
+	}
+	if len(req.Approvers()) < req.Required {
+		return req, nil
+	}
+	return w.post(req, approver)
+}
+
+// Reject ends the current submission. The maker may fix the voucher and
+// submit it again.
+func (w *Workflow) Reject(voucherID, approver, comment string) (*Request, error) {
+	defer w.lock(voucherID)()
+
+	req, err := w.pending(voucherID)
+	if err != nil {
+		return nil, err
+	}
+	if approver == req.Maker {
+		return nil, ErrSelfApproval
+	}
+	req.Status = StatusRejected
+	req.Steps = append(req.Steps, Step{Action: ActionReject, User: approver, At: w.Now(), Comment: comment})
+	if err := w.store.PutRequest(*req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// Post posts a voucher whose request already has enough approvals, e.g. after
+// a failed attempt during Approve. The maker cannot post.
+func (w *Workflow) Post(voucherID, user string) (*Request, error) {
+	defer w.lock(voucherID)()
+
+	req, err := w.pending(voucherID)
+	if err != nil {
+		return nil, err
+	}
+	if user == req.Maker {
+		return nil, ErrSelfApproval
+	}
+	if n := len(req.Approvers()); n < req.Required {
+		return nil, fmt.Errorf("journal voucher %s has %d of %d required approvals", voucherID, n, req.Required)
+	}
+	return w.post(req, user)
+}
+
+// Request returns the approval request of a voucher.
+func (w *Workflow) Request(voucherID string) (*Request, error) {
+	req, err := w.store.GetRequest(voucherID)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil {
+		return nil, ErrRequestNotFound
+	}
+	return req, nil
+}
+
+func (w *Workflow) pending(voucherID string) (*Request, error) {
+	req, err := w.Request(voucherID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Status != StatusPending {
+		return nil, fmt.Errorf("journal voucher %s is %s: %w", voucherID, req.Status, ErrNotPending)
+	}
+	return req, nil
+}
+
+func (w *Workflow) post(req *Request, user string) (*Request, error) {
+	jv, err := w.vouchers.GetJournalVoucherByID(req.VoucherID)
+	if err != nil {
+		return nil, err
+	}
+	fp, err := fingerprint(*jv)
+	if err != nil {
+		return nil, err
+	}
+	if fp != req.Fingerprint {
+		req.Status = StatusRejected
+		req.Steps = append(req.Steps, Step{Action: ActionReject, User: user, At: w.Now(), Comment: ErrVoucherChanged.Error()})
+		if err := w.store.PutRequest(*req); err != nil {
+			return nil, err
+		}
+		return req, ErrVoucherChanged
+	}
+
+	if jv.VoucherStatus != journal.StatusPosted {
+		if _, err := w.vouchers.PostVoucher(*jv); err != nil {
+			return req, err
+		}
+	}
+	req.Status = StatusPosted
+	req.Steps = append(req.Steps, Step{Action: ActionPost, User: user, At: w.Now()})
+	if err := w.store.PutRequest(*req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
@@ -0,0 +1,202 @@
+package bankstatement
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+)
+
+type stubBooks struct {
+	vouchers []journal.JournalVoucher
+}
+
+func (b *stubBooks) ListAccounts() ([]account.Account, error) {
+	return []account.Account{
+		{ID: "acc-bank", Code: "BA0001"},
+		{ID: "acc-suspense", Code: "SU0001"},
+		{ID: "acc-fees", Code: "EX0200"},
+		{ID: "acc-sales", Code: "IN0001"},
+	}, nil
+}
+
+func (b *stubBooks) CreateJournalVoucher(jv journal.JournalVoucher) (*journal.JournalVoucher, error) {
+	jv.ID = fmt.Sprintf("jv-%d", len(b.vouchers)+1)
+	b.vouchers = append(b.vouchers, jv)
+	return &jv, nil
+}
+
+const sampleCSV = `Account statement,NIC Asia
+Txn Date,Remarks,Withdrawal,Deposit,Cheque No
+2024-07-16,SERVICE CHARGE,"1,130.00",,
+2024-07-17,FONEPAY QR SALES,,"12,500.50",
+2024-07-18,TRANSFER TO HARI,500.00,,000123
+,Closing balance,,,
+`
+
+func TestParseCSV(t *testing.T) {
+	st, err := ParseCSV(strings.NewReader(sampleCSV), CSVMapping{
+		Date: "txn date", Description: "Remarks", Withdrawal: "Withdrawal", Deposit: "Deposit", Reference: "Cheque No",
+		SkipRows: 1, Currency: "NPR",
+	})
+	if err != nil {
+		t.Fatalf("ParseCSV failed: %v", err)
+	}
+	if len(st.Transactions) != 3 {
+		t.Fatalf("expected 3 transactions, got %d", len(st.Transactions))
+	}
+	if got := st.Transactions[0].Amount.String(); got != "-1130.00" {
+		t.Fatalf("expected withdrawal -1130.00, got %s", got)
+	}
+	if got := st.Transactions[1].Amount; got.String() != "12500.50" || got.Currency() != "NPR" {
+		t.Fatalf("expected deposit 12500.50 NPR, got %s %s", got, got.Currency())
+	}
+	if st.Transactions[2].Reference != "000123" {
+		t.Fatalf("unexpected reference %q", st.Transactions[2].Reference)
+	}
+
+	_, err = ParseCSV(strings.NewReader("Date,Amount\n16/07/2024,5\n"), CSVMapping{Date: "Date", Amount: "Amount"})
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected a date error on line 2, got %v", err)
+	}
+	st, err = ParseCSV(strings.NewReader("Date,Amount\n16/07/2024,\"-1.234,56\"\n"), CSVMapping{Date: "Date", Amount: "Amount", DateLayout: "02/01/2006", DecimalComma: true})
+	if err != nil || st.Transactions[0].Amount.String() != "-1234.56" {
+		t.Fatalf("expected -1234.56 with a decimal comma, got %v %v", st, err)
+	}
+}
+
+func TestParseOFX(t *testing.T) {
+	sgml := `OFXHEADER:100
+DATA:OFXSGML
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>NPR
+<BANKACCTFROM><BANKID>NICA<ACCTID>0123456789</BANKACCTFROM>
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20240716120000.000[+5:45:NPT]
+<TRNAMT>-1130.00
+<FITID>FT2407160001
+<NAME>NIC ASIA
+<MEMO>SERVICE CHARGE &amp; VAT
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20240717
+<TRNAMT>12500.50
+<FITID>FT2407170002
+<PAYEE><NAME>Fonepay</PAYEE>
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+	sts, err := ParseOFX(strings.NewReader(sgml))
+	if err != nil {
+		t.Fatalf("ParseOFX failed: %v", err)
+	}
+	st := sts[0]
+	if len(sts) != 1 || st.AccountNumber != "0123456789" || st.Currency != "NPR" || len(st.Transactions) != 2 {
+		t.Fatalf("unexpected statements %+v", sts)
+	}
+	first := st.Transactions[0]
+	if first.ID != "FT2407160001" || first.Date.String() != "2024-07-16" || first.Description != "SERVICE CHARGE & VAT" || first.Counterparty != "NIC ASIA" {
+		t.Fatalf("unexpected first transaction %+v", first)
+	}
+	if second := st.Transactions[1]; second.Counterparty != "Fonepay" || second.Description != "Fonepay" || second.Amount.Currency() != "NPR" {
+		t.Fatalf("unexpected second transaction %+v", second)
+	}
+}
+
+func TestParseCAMT053(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.08">
+ <BkToCstmrStmt>
+  <Stmt>
+   <Acct><Id><IBAN>NP12NICA0123456789</IBAN></Id><Ccy>NPR</Ccy></Acct>
+   <Ntry>
+    <Amt Ccy="NPR">12500.50</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts><Cd>BOOK</Cd></Sts>
+    <BookgDt><Dt>2024-07-17</Dt></BookgDt><ValDt><Dt>2024-07-18</Dt></ValDt>
+    <AcctSvcrRef>REF-2</AcctSvcrRef>
+    <NtryDtls><TxDtls>
+     <Refs><EndToEndId>INV-0042</EndToEndId></Refs>
+     <RltdPties><Dbtr><Pty><Nm>Himal Traders</Nm></Pty></Dbtr></RltdPties>
+     <RmtInf><Ustrd>Invoice 42</Ustrd></RmtInf>
+    </TxDtls></NtryDtls>
+   </Ntry>
+   <Ntry>
+    <Amt Ccy="NPR">99.00</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts><Cd>PDNG</Cd></Sts>
+    <BookgDt><Dt>2024-07-19</Dt></BookgDt>
+   </Ntry>
+  </Stmt>
+ </BkToCstmrStmt>
+</Document>`
+	sts, err := ParseCAMT053(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("ParseCAMT053 failed: %v", err)
+	}
+	if len(sts) != 1 || sts[0].AccountNumber != "NP12NICA0123456789" || len(sts[0].Transactions) != 1 {
+		t.Fatalf("unexpected statements %+v", sts)
+	}
+	txn := sts[0].Transactions[0]
+	if txn.ID != "REF-2" || txn.Amount.String() != "12500.50" || txn.Counterparty != "Himal Traders" || txn.Reference != "INV-0042" || txn.Description != "Invoice 42" || txn.ValueDate.String() != "2024-07-18" {
+		t.Fatalf("unexpected transaction %+v", txn)
+	}
+}
+
+func TestImport(t *testing.T) {
+	st, err := ParseCSV(strings.NewReader(sampleCSV), CSVMapping{
+		Date: "Txn Date", Description: "Remarks", Withdrawal: "Withdrawal", Deposit: "Deposit", SkipRows: 1, Currency: "NPR",
+	})
+	if err != nil {
+		t.Fatalf("ParseCSV failed: %v", err)
+	}
+	matcher, err := NewMatcher([]Rule{
+		{Name: "bank fees", DescriptionContains: "service charge", Direction: Out, MaxAmount: "5000", AccountCode: "EX0200", Dimensions: map[string]string{"cost_center": "KTM"}},
+		{Name: "qr sales", DescriptionPattern: `^FONEPAY\b`, Direction: In, AccountCode: "IN0001", Narration: "QR sales"},
+	})
+	if err != nil {
+		t.Fatalf("NewMatcher failed: %v", err)
+	}
+
+	books := &stubBooks{}
+	report, err := NewImporter(books, books, matcher, "BA0001", "SU0001").Import(context.Background(), st)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if report.Failed() != 0 || report.Unmatched() != 1 || len(books.vouchers) != 3 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	fees := books.vouchers[0]
+	if fees.Code != "BNK-2024-07-16-0001" || fees.Items[0].AccountID != "acc-bank" || fees.Items[0].TxnType != journal.TxnTypeCredit {
+		t.Fatalf("unexpected fee voucher %+v", fees)
+	}
+	if contra := fees.Items[1]; contra.AccountID != "acc-fees" || contra.Dimensions["cost_center"] != "KTM" {
+		t.Fatalf("expected the fee rule to tag its contra line, got %+v", contra)
+	}
+	if sales := books.vouchers[1]; sales.Narration != "QR sales" || sales.Items[1].AccountID != "acc-sales" || sales.Items[1].TxnType != journal.TxnTypeCredit {
+		t.Fatalf("unexpected sales voucher %+v", sales)
+	}
+	if transfer := books.vouchers[2]; transfer.Items[1].AccountID != "acc-suspense" || report.Lines[2].Rule != "" {
+		t.Fatalf("expected the unmatched transfer to go to suspense, got %+v", transfer)
+	}
+}
+
+func TestNewMatcherRejectsBadRules(t *testing.T) {
+	for _, r := range []Rule{
+		{Name: "no account"},
+		{Name: "bad direction", AccountCode: "EX0200", Direction: "SIDEWAYS"},
+		{Name: "bad pattern", AccountCode: "EX0200", DescriptionPattern: "("},
+		{Name: "bad amount", AccountCode: "EX0200", MinAmount: "ten"},
+	} {
+		if _, err := NewMatcher([]Rule{r}); err == nil {
+			t.Fatalf("expected rule %q to be rejected", r.Name)
+		}
+	}
+}
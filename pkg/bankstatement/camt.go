@@ -0,0 +1,170 @@
+package bankstatement
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// The subset of ISO 20022 camt.053 (BankToCustomerStatement) that is read.
+// Elements are matched by local name, so any message version works.
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	IBAN     string      `xml:"Acct>Id>IBAN"`
+	Other    string      `xml:"Acct>Id>Othr>Id"`
+	Currency string      `xml:"Acct>Ccy"`
+	Entries  []camtEntry `xml:"Ntry"`
+}
+
+type camtEntry struct {
+	Ref             string        `xml:"NtryRef"`
+	Amount          camtAmount    `xml:"Amt"`
+	CreditDebit     string        `xml:"CdtDbtInd"`
+	Status          camtStatus    `xml:"Sts"`
+	BookingDate     string        `xml:"BookgDt>Dt"`
+	BookingDateTime string        `xml:"BookgDt>DtTm"`
+	ValueDate       string        `xml:"ValDt>Dt"`
+	ServicerRef     string        `xml:"AcctSvcrRef"`
+	Info            string        `xml:"AddtlNtryInf"`
+	Details         []camtDetails `xml:"NtryDtls>TxDtls"`
+}
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// camtStatus is <Sts>BOOK</Sts> before version 8 and <Sts><Cd>BOOK</Cd></Sts> after.
+type camtStatus struct {
+	Text string `xml:",chardata"`
+	Code string `xml:"Cd"`
+}
+
+func (s camtStatus) String() string {
+	return strings.TrimSpace(s.Text + s.Code)
+}
+
+type camtDetails struct {
+	EndToEndID    string   `xml:"Refs>EndToEndId"`
+	Unstructured  []string `xml:"RmtInf>Ustrd"`
+	Debtor        string   `xml:"RltdPties>Dbtr>Nm"`
+	DebtorParty   string   `xml:"RltdPties>Dbtr>Pty>Nm"`
+	Creditor      string   `xml:"RltdPties>Cdtr>Nm"`
+	CreditorParty string   `xml:"RltdPties>Cdtr>Pty>Nm"`
+	Info          string   `xml:"AddtlTxInf"`
+}
+
+// ParseCAMT053 reads an ISO 20022 camt.053 bank-to-customer statement and
+// returns one statement per Stmt element. Pending entries are left out. For
+// batch entries the details of the first transaction are used.
+func ParseCAMT053(r io.Reader) ([]Statement, error) {
+	var doc camtDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("camt.053: %w", err)
+	}
+	if len(doc.Statements) == 0 {
+		return nil, fmt.Errorf("camt.053: no statements found")
+	}
+
+	out := make([]Statement, 0, len(doc.Statements))
+	for _, cs := range doc.Statements {
+		st := Statement{AccountNumber: cs.IBAN, Currency: cs.Currency}
+		if st.AccountNumber == "" {
+			st.AccountNumber = cs.Other
+		}
+		for _, e := range cs.Entries {
+			if e.Status.String() == "PDNG" {
+				continue
+			}
+			txn, err := e.transaction(st.Currency)
+			if err != nil {
+				return nil, fmt.Errorf("camt.053: entry %s: %w", e.ServicerRef+e.Ref, err)
+			}
+			if st.Currency == "" {
+				st.Currency = txn.Amount.Currency()
+			}
+			st.Transactions = append(st.Transactions, txn)
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+func (e camtEntry) transaction(currency string) (Transaction, error) {
+	if e.Amount.Currency != "" {
+		currency = e.Amount.Currency
+	}
+	amount, err := money.Parse(e.Amount.Value, currency)
+	if err != nil {
+		return Transaction{}, err
+	}
+	switch e.CreditDebit {
+	case "CRDT":
+	case "DBIT":
+		amount = amount.Neg()
+	default:
+		return Transaction{}, fmt.Errorf("CdtDbtInd must be CRDT or DBIT, got %q", e.CreditDebit)
+	}
+
+	booking := e.BookingDate
+	if booking == "" && len(e.BookingDateTime) >= 10 {
+		booking = e.BookingDateTime[:10]
+	}
+	date, err := parseISODate(booking)
+	if err != nil {
+		return Transaction{}, err
+	}
+	txn := Transaction{ID: e.ServicerRef, Date: date, Amount: amount, Description: e.Info}
+	if txn.ID == "" {
+		txn.ID = e.Ref
+	}
+	if e.ValueDate != "" {
+		if txn.ValueDate, err = parseISODate(e.ValueDate); err != nil {
+			return Transaction{}, err
+		}
+	}
+
+	if len(e.Details) > 0 {
+		d := e.Details[0]
+		if ustrd := strings.TrimSpace(strings.Join(d.Unstructured, " ")); ustrd != "" {
+			txn.Description = ustrd
+		} else if txn.Description == "" {
+			txn.Description = d.Info
+		}
+		// The counterparty is whoever is on the other side of the entry.
+		if amount.Sign() > 0 {
+			txn.Counterparty = firstNonEmpty(d.Debtor, d.DebtorParty)
+		} else {
+			txn.Counterparty = firstNonEmpty(d.Creditor, d.CreditorParty)
+		}
+		if d.EndToEndID != "NOTPROVIDED" {
+			txn.Reference = d.EndToEndID
+		}
+	}
+	return txn, nil
+}
+
+func parseISODate(s string) (calendar.Date, error) {
+	t, err := time.Parse(calendar.ADLayout, strings.TrimSpace(s))
+	if err != nil {
+		return calendar.Date{}, fmt.Errorf("invalid date %q", s)
+	}
+	return calendar.NewDate(t), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
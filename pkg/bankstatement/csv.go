@@ -0,0 +1,169 @@
+package bankstatement
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// CSVMapping names the columns of a bank's CSV export. Header names are
+// matched case-insensitively. Either Amount (signed, positive for money
+// received) or Withdrawal and Deposit must be set.
+type CSVMapping struct {
+	Date         string `json:"date"`
+	ValueDate    string `json:"value_date,omitempty"`
+	Description  string `json:"description,omitempty"`
+	Counterparty string `json:"counterparty,omitempty"`
+	Reference    string `json:"reference,omitempty"`
+	ID           string `json:"id,omitempty"`
+	Amount       string `json:"amount,omitempty"`
+	Withdrawal   string `json:"withdrawal,omitempty"`
+	Deposit      string `json:"deposit,omitempty"`
+
+	// DateLayout is a Go time layout for AD dates, "2006-01-02" by default.
+	// BS dates are always read as YYYY-MM-DD.
+	DateLayout string          `json:"date_layout,omitempty"`
+	DateSystem calendar.System `json:"date_system,omitempty"`
+	// DecimalComma reads "1.234,56" style amounts.
+	DecimalComma bool `json:"decimal_comma,omitempty"`
+	// SkipRows is the number of lines before the header, such as a bank's
+	// account summary.
+	SkipRows int    `json:"skip_rows,omitempty"`
+	Currency string `json:"currency"`
+}
+
+// ParseCSV reads a CSV statement with the given column mapping. Rows without
+// a date, such as totals at the bottom, are skipped.
+func ParseCSV(r io.Reader, m CSVMapping) (*Statement, error) {
+	if m.Date == "" || (m.Amount == "" && m.Withdrawal == "" && m.Deposit == "") {
+		return nil, fmt.Errorf("csv mapping needs a date column and an amount or withdrawal/deposit columns")
+	}
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) <= m.SkipRows {
+		return nil, fmt.Errorf("csv has no header row")
+	}
+	header, rows := records[m.SkipRows], records[m.SkipRows+1:]
+
+	col := func(name string) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), name) {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("csv is missing column %q", name)
+	}
+	cols := map[string]int{}
+	for _, name := range []string{m.Date, m.ValueDate, m.Description, m.Counterparty, m.Reference, m.ID, m.Amount, m.Withdrawal, m.Deposit} {
+		i, err := col(name)
+		if err != nil {
+			return nil, err
+		}
+		cols[name] = i
+	}
+	get := func(row []string, name string) string {
+		i := cols[name]
+		if name == "" || i < 0 || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	st := &Statement{Currency: m.Currency}
+	for n, row := range rows {
+		line := m.SkipRows + n + 2
+		dateText := get(row, m.Date)
+		if dateText == "" {
+			continue
+		}
+		date, err := m.parseDate(dateText)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		txn := Transaction{
+			ID:           get(row, m.ID),
+			Date:         date,
+			Description:  get(row, m.Description),
+			Counterparty: get(row, m.Counterparty),
+			Reference:    get(row, m.Reference),
+		}
+		if v := get(row, m.ValueDate); v != "" {
+			if txn.ValueDate, err = m.parseDate(v); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+
+		if m.Amount != "" {
+			txn.Amount, err = parseDecimal(get(row, m.Amount), m.Currency, m.DecimalComma)
+		} else {
+			var out, in money.Money
+			if out, err = parseDecimal(get(row, m.Withdrawal), m.Currency, m.DecimalComma); err == nil {
+				if in, err = parseDecimal(get(row, m.Deposit), m.Currency, m.DecimalComma); err == nil {
+					txn.Amount, err = in.Abs().Sub(out.Abs())
+				}
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		st.Transactions = append(st.Transactions, txn)
+	}
+	return st, nil
+}
+
+func (m CSVMapping) parseDate(s string) (calendar.Date, error) {
+	if m.DateSystem == calendar.BS {
+		return calendar.ParseDate(s, calendar.BS)
+	}
+	layout := m.DateLayout
+	if layout == "" {
+		layout = calendar.ADLayout
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return calendar.Date{}, fmt.Errorf("invalid date %q, expected layout %s", s, layout)
+	}
+	return calendar.NewDate(t), nil
+}
+
+// parseDecimal reads a bank amount such as "1,234.56", "-50", "(75.00)" or,
+// with decimalComma, "1.234,56". An empty cell is zero.
+func parseDecimal(s, currency string, decimalComma bool) (money.Money, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	if s == "" || s == "-" {
+		return money.Zero(currency), nil
+	}
+	neg := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		neg, s = true, s[1:len(s)-1]
+	}
+	if strings.HasSuffix(s, "-") {
+		neg, s = true, strings.TrimSuffix(s, "-")
+	}
+	if decimalComma {
+		s = strings.ReplaceAll(strings.ReplaceAll(s, ".", ""), ",", ".")
+	} else {
+		s = strings.ReplaceAll(s, ",", "")
+	}
+	v, err := money.Parse(s, currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	if neg {
+		v = v.Neg()
+	}
+	return v, nil
+}
@@ -0,0 +1,132 @@
+package bankstatement
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// ParseOFX reads an OFX or QFX file, either the SGML flavour of OFX 1.x,
+// where leaf elements have no closing tags, or the XML of OFX 2.x. It returns
+// one statement per bank or credit card statement in the file.
+func ParseOFX(r io.Reader) ([]Statement, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := string(b)
+	start := strings.Index(strings.ToUpper(text), "<OFX>")
+	if start < 0 {
+		return nil, fmt.Errorf("ofx: no <OFX> element")
+	}
+
+	var (
+		statements []Statement
+		st         *Statement
+		txn        *Transaction
+		payeeName  string
+	)
+	for _, piece := range strings.Split(text[start:], "<")[1:] {
+		tag, value, _ := strings.Cut(piece, ">")
+		tag = strings.ToUpper(strings.TrimSpace(tag))
+		value = html.UnescapeString(strings.TrimSpace(value))
+		if tag == "" || tag[0] == '?' || tag[0] == '!' {
+			continue
+		}
+
+		switch tag {
+		case "STMTRS", "CCSTMTRS":
+			statements = append(statements, Statement{})
+			st = &statements[len(statements)-1]
+			continue
+		case "/STMTRS", "/CCSTMTRS":
+			st = nil
+			continue
+		case "STMTTRN":
+			if st == nil {
+				return nil, fmt.Errorf("ofx: transaction outside a statement")
+			}
+			txn, payeeName = &Transaction{}, ""
+			continue
+		case "/STMTTRN":
+			if txn == nil {
+				continue
+			}
+			if txn.Counterparty == "" {
+				txn.Counterparty = payeeName
+			}
+			if txn.Description == "" {
+				txn.Description = txn.Counterparty
+			}
+			if txn.Date.IsZero() {
+				return nil, fmt.Errorf("ofx: transaction %q has no DTPOSTED", txn.ID)
+			}
+			if txn.Amount.Currency() == "" {
+				txn.Amount = txn.Amount.WithCurrency(st.Currency)
+			}
+			st.Transactions = append(st.Transactions, *txn)
+			txn = nil
+			continue
+		}
+		if st == nil || value == "" {
+			continue
+		}
+
+		if txn == nil {
+			switch tag {
+			case "CURDEF":
+				st.Currency = value
+			case "ACCTID":
+				st.AccountNumber = value
+			}
+			continue
+		}
+
+		var err error
+		switch tag {
+		case "FITID":
+			txn.ID = value
+		case "DTPOSTED":
+			txn.Date, err = parseOFXDate(value)
+		case "DTUSER", "DTAVAIL":
+			if txn.ValueDate.IsZero() {
+				txn.ValueDate, err = parseOFXDate(value)
+			}
+		case "TRNAMT":
+			txn.Amount, err = money.Parse(strings.Replace(value, ",", ".", 1), st.Currency)
+		case "NAME":
+			// NAME appears directly in STMTTRN or inside a PAYEE aggregate.
+			payeeName = value
+		case "MEMO":
+			txn.Description = value
+		case "CHECKNUM", "REFNUM":
+			if txn.Reference == "" {
+				txn.Reference = value
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ofx: transaction %q: %w", txn.ID, err)
+		}
+	}
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("ofx: no statements found")
+	}
+	return statements, nil
+}
+
+// parseOFXDate reads the date part of YYYYMMDD[HHMMSS[.XXX]][[gmt offset:tz]].
+func parseOFXDate(s string) (calendar.Date, error) {
+	if len(s) < 8 {
+		return calendar.Date{}, fmt.Errorf("invalid OFX date %q", s)
+	}
+	t, err := time.Parse("20060102", s[:8])
+	if err != nil {
+		return calendar.Date{}, fmt.Errorf("invalid OFX date %q", s)
+	}
+	return calendar.NewDate(t), nil
+}
@@ -0,0 +1,146 @@
+package bankstatement
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// Direction restricts a rule to money received or paid out.
+type Direction string
+
+const (
+	In  Direction = "IN"
+	Out Direction = "OUT"
+)
+
+// Rule picks the contra account for statement lines. Every condition that is
+// set must hold; text conditions are case-insensitive. Amount bounds apply to
+// the absolute amount and are inclusive.
+type Rule struct {
+	Name                 string            `json:"name"`
+	DescriptionContains  string            `json:"description_contains,omitempty"`
+	DescriptionPattern   string            `json:"description_pattern,omitempty"`
+	CounterpartyContains string            `json:"counterparty_contains,omitempty"`
+	Direction            Direction         `json:"direction,omitempty"`
+	MinAmount            string            `json:"min_amount,omitempty"`
+	MaxAmount            string            `json:"max_amount,omitempty"`
+	AccountCode          string            `json:"account_code"`
+	Dimensions           map[string]string `json:"dimensions,omitempty"`
+	// Narration replaces the statement description as the voucher narration.
+	Narration string `json:"narration,omitempty"`
+}
+
+type compiledRule struct {
+	rule     Rule
+	pattern  *regexp.Regexp
+	min, max *money.Money
+}
+
+// Matcher applies rules in order; the first rule that matches wins.
+type Matcher struct {
+	rules []compiledRule
+}
+
+// NewMatcher checks and compiles rules. A nil or empty list matches nothing.
+func NewMatcher(rules []Rule) (*Matcher, error) {
+	m := &Matcher{}
+	for i, r := range rules {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if r.AccountCode == "" {
+			return nil, fmt.Errorf("bank rule %s: account code is required", name)
+		}
+		if r.Direction != "" && r.Direction != In && r.Direction != Out {
+			return nil, fmt.Errorf("bank rule %s: direction must be %s or %s, got %q", name, In, Out, r.Direction)
+		}
+
+		c := compiledRule{rule: r}
+		if r.DescriptionPattern != "" {
+			re, err := regexp.Compile("(?i)" + r.DescriptionPattern)
+			if err != nil {
+				return nil, fmt.Errorf("bank rule %s: %w", name, err)
+			}
+			c.pattern = re
+		}
+		for _, bound := range []struct {
+			text string
+			dst  **money.Money
+		}{{r.MinAmount, &c.min}, {r.MaxAmount, &c.max}} {
+			if bound.text == "" {
+				continue
+			}
+			v, err := money.Parse(bound.text, "")
+			if err != nil {
+				return nil, fmt.Errorf("bank rule %s: %w", name, err)
+			}
+			*bound.dst = &v
+		}
+		m.rules = append(m.rules, c)
+	}
+	return m, nil
+}
+
+// LoadRules reads a JSON array of rules, in matching order.
+func LoadRules(path string) ([]Rule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Match returns the first rule matching txn, or nil.
+func (m *Matcher) Match(txn Transaction) *Rule {
+	if m == nil {
+		return nil
+	}
+	for i := range m.rules {
+		if m.rules[i].matches(txn) {
+			return &m.rules[i].rule
+		}
+	}
+	return nil
+}
+
+func (c *compiledRule) matches(txn Transaction) bool {
+	r := c.rule
+	switch {
+	case r.Direction == In && txn.Amount.Sign() <= 0,
+		r.Direction == Out && txn.Amount.Sign() >= 0:
+		return false
+	case r.DescriptionContains != "" && !containsFold(txn.Description, r.DescriptionContains):
+		return false
+	case c.pattern != nil && !c.pattern.MatchString(txn.Description):
+		return false
+	case r.CounterpartyContains != "" && !containsFold(txn.Counterparty, r.CounterpartyContains):
+		return false
+	}
+
+	amount := txn.Amount.Abs()
+	if c.min != nil {
+		if cmp, err := amount.Cmp(*c.min); err != nil || cmp < 0 {
+			return false
+		}
+	}
+	if c.max != nil {
+		if cmp, err := amount.Cmp(*c.max); err != nil || cmp > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(s, sub string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(sub))
+}
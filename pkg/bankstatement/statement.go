@@ -0,0 +1,200 @@
+package bankstatement
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// Transaction is one statement line, normalised across formats. Amount is
+// signed from the account holder's side: positive for money received and
+// negative for money paid out.
+type Transaction struct {
+	// ID is the bank's identifier for the line (OFX FITID, CAMT AcctSvcrRef),
+	// if the format has one.
+	ID           string
+	Date         calendar.Date
+	ValueDate    calendar.Date
+	Amount       money.Money
+	Description  string
+	Counterparty string
+	Reference    string
+}
+
+// Statement is the list of transactions on one bank account.
+type Statement struct {
+	AccountNumber string
+	Currency      string
+	Transactions  []Transaction
+}
+
+// VoucherCreator creates journal vouchers. *journal.Service satisfies it.
+type VoucherCreator interface {
+	CreateJournalVoucher(jv journal.JournalVoucher) (*journal.JournalVoucher, error)
+}
+
+// LineResult is the outcome for one statement line. Rule is the name of the
+// matching rule, or empty when the line went to the suspense account.
+type LineResult struct {
+	Transaction Transaction
+	Rule        string
+	VoucherID   string
+	Err         error
+}
+
+// Report lists the outcome of every line of an imported statement.
+type Report struct {
+	Lines []LineResult
+}
+
+// Failed returns the number of lines that could not be imported.
+func (r *Report) Failed() int {
+	n := 0
+	for _, l := range r.Lines {
+		if l.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Unmatched returns the number of lines posted to the suspense account.
+func (r *Report) Unmatched() int {
+	n := 0
+	for _, l := range r.Lines {
+		if l.Err == nil && l.Rule == "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Importer turns statement lines into draft journal vouchers against a bank
+// account. The contra account comes from the first matching rule, or the
+// suspense account when no rule matches.
+type Importer struct {
+	vouchers VoucherCreator
+	accounts journal.AccountLister
+	matcher  *Matcher
+
+	BankAccountCode     string
+	SuspenseAccountCode string
+	// CodePrefix starts every voucher code. The bank's transaction ID follows
+	// when there is one, so a line gets the same code each time it is imported.
+	CodePrefix string
+}
+
+func NewImporter(vouchers VoucherCreator, accounts journal.AccountLister, matcher *Matcher, bankAccountCode, suspenseAccountCode string) *Importer {
+	return &Importer{
+		vouchers:            vouchers,
+		accounts:            accounts,
+		matcher:             matcher,
+		BankAccountCode:     bankAccountCode,
+		SuspenseAccountCode: suspenseAccountCode,
+		CodePrefix:          "BNK",
+	}
+}
+
+// Import creates one draft voucher per statement line. Account codes are
+// resolved with a single account listing. Problems are reported per line; the
+// returned error is only set when the import could not run at all.
+func (im *Importer) Import(ctx context.Context, st *Statement) (*Report, error) {
+	accounts := &cachedAccounts{lister: im.accounts}
+	report := &Report{Lines: make([]LineResult, len(st.Transactions))}
+	for i, txn := range st.Transactions {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		res := LineResult{Transaction: txn}
+
+		jv, rule, err := im.Voucher(ctx, st, i, accounts)
+		if rule != nil {
+			res.Rule = rule.Name
+		}
+		if err == nil {
+			var created *journal.JournalVoucher
+			if created, err = im.vouchers.CreateJournalVoucher(*jv); err == nil {
+				res.VoucherID = created.ID
+			}
+		}
+		res.Err = err
+		report.Lines[i] = res
+	}
+	return report, nil
+}
+
+// Voucher builds the draft voucher for line i of st and returns the rule that
+// chose its contra account, or nil for the suspense account.
+func (im *Importer) Voucher(ctx context.Context, st *Statement, i int, accounts journal.AccountLister) (*journal.JournalVoucher, *Rule, error) {
+	txn := st.Transactions[i]
+	if txn.Amount.IsZero() {
+		return nil, nil, fmt.Errorf("statement line has a zero amount")
+	}
+
+	contra, narration := im.SuspenseAccountCode, txn.Description
+	rule := im.matcher.Match(txn)
+	if rule != nil {
+		contra = rule.AccountCode
+		if rule.Narration != "" {
+			narration = rule.Narration
+		}
+	}
+
+	currency := txn.Amount.Currency()
+	if currency == "" {
+		currency = st.Currency
+	}
+	amount := txn.Amount.Abs().WithCurrency(currency)
+	lineNarration := strings.TrimSpace(strings.Join([]string{txn.Counterparty, txn.Reference}, " "))
+
+	b := journal.NewBuilder(accounts, im.code(txn, i), txn.Date, currency).Narration(narration)
+	// The contra line goes last so the rule's dimensions tag it.
+	if txn.Amount.Sign() > 0 {
+		b.Debit(im.BankAccountCode, amount, lineNarration).Credit(contra, amount, lineNarration)
+	} else {
+		b.Credit(im.BankAccountCode, amount, lineNarration).Debit(contra, amount, lineNarration)
+	}
+	if rule != nil {
+		for k, v := range rule.Dimensions {
+			b.Tag(k, v)
+		}
+	}
+
+	jv, err := b.Build(ctx)
+	return jv, rule, err
+}
+
+var codeUnsafe = regexp.MustCompile(`[^A-Za-z0-9-]+`)
+
+// code returns the voucher code for line i: the prefix and the bank's ID, or
+// failing that the date and line number.
+func (im *Importer) code(txn Transaction, i int) string {
+	if id := strings.Trim(codeUnsafe.ReplaceAllString(txn.ID, "-"), "-"); id != "" {
+		return im.CodePrefix + "-" + id
+	}
+	return fmt.Sprintf("%s-%s-%04d", im.CodePrefix, txn.Date.Format(calendar.AD), i+1)
+}
+
+// cachedAccounts lists accounts once and serves every later call from memory.
+type cachedAccounts struct {
+	lister   journal.AccountLister
+	accounts []account.Account
+	loaded   bool
+}
+
+func (c *cachedAccounts) ListAccounts() ([]account.Account, error) {
+	if !c.loaded {
+		accounts, err := c.lister.ListAccounts()
+		if err != nil {
+			return nil, err
+		}
+		c.accounts, c.loaded = accounts, true
+	}
+	return c.accounts, nil
+}
@@ -0,0 +1,185 @@
+package calendar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	bsMinYear = 2000
+	bsMaxYear = bsMinYear + len(bsMonthDays) - 1
+)
+
+// bsEpoch is the AD date of 2000-01-01 BS.
+var bsEpoch = time.Date(1943, time.April, 14, 0, 0, 0, 0, time.UTC)
+
+// BS month numbers, starting with Baisakh.
+const (
+	Baisakh = iota + 1
+	Jestha
+	Asar
+	Shrawan
+	Bhadra
+	Asoj
+	Kartik
+	Mangsir
+	Poush
+	Magh
+	Falgun
+	Chaitra
+)
+
+var bsMonthNames = [...]string{
+	"Baisakh", "Jestha", "Asar", "Shrawan", "Bhadra", "Asoj",
+	"Kartik", "Mangsir", "Poush", "Magh", "Falgun", "Chaitra",
+}
+
+// BSDate is a day in the Bikram Sambat calendar. Month is 1 (Baisakh) to 12 (Chaitra).
+type BSDate struct {
+	Year  int
+	Month int
+	Day   int
+}
+
+// ErrOutOfRange is returned for dates outside the years covered by the BS table.
+type ErrOutOfRange struct {
+	What string
+}
+
+func (e *ErrOutOfRange) Error() string {
+	return fmt.Sprintf("%s is outside the supported Bikram Sambat range %d-%d", e.What, bsMinYear, bsMaxYear)
+}
+
+// BSYearRange returns the first and last BS years that can be converted.
+func BSYearRange() (first, last int) {
+	return bsMinYear, bsMaxYear
+}
+
+// DaysInBSMonth returns the length of a BS month.
+func DaysInBSMonth(year, month int) (int, error) {
+	if year < bsMinYear || year > bsMaxYear {
+		return 0, &ErrOutOfRange{What: fmt.Sprintf("year %d", year)}
+	}
+	if month < 1 || month > 12 {
+		return 0, fmt.Errorf("invalid BS month %d", month)
+	}
+	return bsMonthDays[year-bsMinYear][month-1], nil
+}
+
+// BSMonthName returns the name of a BS month, e.g. "Shrawan" for 4.
+func BSMonthName(month int) string {
+	if month < 1 || month > 12 {
+		return fmt.Sprintf("Month(%d)", month)
+	}
+	return bsMonthNames[month-1]
+}
+
+// Validate checks that the date exists.
+func (d BSDate) Validate() error {
+	n, err := DaysInBSMonth(d.Year, d.Month)
+	if err != nil {
+		return err
+	}
+	if d.Day < 1 || d.Day > n {
+		return fmt.Errorf("invalid BS date %s: %s %d has %d days", d, BSMonthName(d.Month), d.Year, n)
+	}
+	return nil
+}
+
+// String formats the date as YYYY-MM-DD.
+func (d BSDate) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// LongString formats the date as e.g. "1 Shrawan 2081".
+func (d BSDate) LongString() string {
+	return fmt.Sprintf("%d %s %d", d.Day, BSMonthName(d.Month), d.Year)
+}
+
+// ParseBS parses a BS date in YYYY-MM-DD form. "/" is accepted as a separator.
+func ParseBS(s string) (BSDate, error) {
+	parts := strings.FieldsFunc(strings.TrimSpace(s), func(r rune) bool { return r == '-' || r == '/' })
+	if len(parts) != 3 {
+		return BSDate{}, fmt.Errorf("invalid BS date %q: expected YYYY-MM-DD", s)
+	}
+	var nums [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return BSDate{}, fmt.Errorf("invalid BS date %q: expected YYYY-MM-DD", s)
+		}
+		nums[i] = n
+	}
+	d := BSDate{Year: nums[0], Month: nums[1], Day: nums[2]}
+	if err := d.Validate(); err != nil {
+		return BSDate{}, err
+	}
+	return d, nil
+}
+
+// ToAD converts a BS date to midnight UTC of the same AD day.
+func (d BSDate) ToAD() (time.Time, error) {
+	if err := d.Validate(); err != nil {
+		return time.Time{}, err
+	}
+	days := d.Day - 1
+	for y := bsMinYear; y < d.Year; y++ {
+		for _, n := range bsMonthDays[y-bsMinYear] {
+			days += n
+		}
+	}
+	for m := 0; m < d.Month-1; m++ {
+		days += bsMonthDays[d.Year-bsMinYear][m]
+	}
+	return bsEpoch.AddDate(0, 0, days), nil
+}
+
+// ToBS converts the AD calendar day of t to BS. Only the year, month and day
+// of t in its own location are used.
+func ToBS(t time.Time) (BSDate, error) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(day.Sub(bsEpoch).Hours() / 24)
+	if days < 0 {
+		return BSDate{}, &ErrOutOfRange{What: day.Format("2006-01-02")}
+	}
+	for y, months := range bsMonthDays {
+		for m, n := range months {
+			if days < n {
+				return BSDate{Year: bsMinYear + y, Month: m + 1, Day: days + 1}, nil
+			}
+			days -= n
+		}
+	}
+	return BSDate{}, &ErrOutOfRange{What: day.Format("2006-01-02")}
+}
+
+// AddMonths moves the date by n BS months. The day is clamped to the length of
+// the target month, so 32 Shrawan plus one month is 31 Bhadra.
+func (d BSDate) AddMonths(n int) (BSDate, error) {
+	index := d.Year*12 + (d.Month - 1) + n
+	out := BSDate{Year: index / 12, Month: index%12 + 1, Day: d.Day}
+	days, err := DaysInBSMonth(out.Year, out.Month)
+	if err != nil {
+		return BSDate{}, err
+	}
+	if out.Day > days {
+		out.Day = days
+	}
+	return out, nil
+}
+
+// FirstOfMonth returns day 1 of the date's BS month.
+func (d BSDate) FirstOfMonth() BSDate {
+	return BSDate{Year: d.Year, Month: d.Month, Day: 1}
+}
+
+// LastOfMonth returns the last day of the date's BS month.
+func (d BSDate) LastOfMonth() (BSDate, error) {
+	n, err := DaysInBSMonth(d.Year, d.Month)
+	if err != nil {
+		return BSDate{}, err
+	}
+	return BSDate{Year: d.Year, Month: d.Month, Day: n}, nil
+}
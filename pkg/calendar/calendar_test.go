@@ -0,0 +1,136 @@
+package calendar
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func ad(s string) time.Time {
+	t, err := time.Parse(ADLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Known new-year and month-start dates.
+var conversions = []struct{ bs, ad string }{
+	{"2000-01-01", "1943-04-14"},
+	{"2050-01-01", "1993-04-13"},
+	{"2070-01-01", "2013-04-14"},
+	{"2080-01-01", "2023-04-14"},
+	{"2081-01-01", "2024-04-13"},
+	{"2081-04-01", "2024-07-16"},
+	{"2081-05-01", "2024-08-17"},
+	{"2081-09-29", "2025-01-13"},
+	{"2081-12-31", "2025-04-13"},
+	{"2082-01-01", "2025-04-14"},
+	{"2082-04-01", "2025-07-17"},
+}
+
+func TestConversions(t *testing.T) {
+	for _, c := range conversions {
+		bs, err := ParseBS(c.bs)
+		if err != nil {
+			t.Fatalf("ParseBS(%s) failed: %v", c.bs, err)
+		}
+		got, err := bs.ToAD()
+		if err != nil {
+			t.Fatalf("ToAD(%s) failed: %v", c.bs, err)
+		}
+		if got.Format(ADLayout) != c.ad {
+			t.Errorf("%s BS -> %s AD, expected %s", c.bs, got.Format(ADLayout), c.ad)
+		}
+
+		back, err := ToBS(ad(c.ad))
+		if err != nil {
+			t.Fatalf("ToBS(%s) failed: %v", c.ad, err)
+		}
+		if back.String() != c.bs {
+			t.Errorf("%s AD -> %s BS, expected %s", c.ad, back, c.bs)
+		}
+	}
+}
+
+func TestRoundTripWholeRange(t *testing.T) {
+	first, last := BSYearRange()
+	start, _ := BSDate{Year: first, Month: 1, Day: 1}.ToAD()
+	end, _ := BSDate{Year: last, Month: 12, Day: 1}.ToAD()
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		bs, err := ToBS(d)
+		if err != nil {
+			t.Fatalf("ToBS(%s) failed: %v", d.Format(ADLayout), err)
+		}
+		back, err := bs.ToAD()
+		if err != nil || !back.Equal(d) {
+			t.Fatalf("%s -> %s -> %s (%v)", d.Format(ADLayout), bs, back.Format(ADLayout), err)
+		}
+	}
+
+	if _, err := ToBS(ad("1900-01-01")); err == nil {
+		t.Fatal("expected out of range error")
+	}
+}
+
+func TestAddMonths(t *testing.T) {
+	d := BSDate{Year: 2081, Month: Shrawan, Day: 32}
+	next, err := d.AddMonths(1)
+	if err != nil {
+		t.Fatalf("AddMonths failed: %v", err)
+	}
+	if next.String() != "2081-05-31" {
+		t.Fatalf("expected 32 Shrawan + 1 month to clamp to 2081-05-31, got %s", next)
+	}
+
+	prev, _ := BSDate{Year: 2081, Month: Baisakh, Day: 15}.AddMonths(-4)
+	if prev.String() != "2080-09-15" {
+		t.Fatalf("expected 2080-09-15, got %s", prev)
+	}
+
+	if _, err := ParseBS("2081-09-30"); err == nil {
+		t.Fatal("expected Poush 2081 to have only 29 days")
+	}
+}
+
+func TestDateJSON(t *testing.T) {
+	var d Date
+	if err := json.Unmarshal([]byte(`"2024-07-16"`), &d); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	b, _ := json.Marshal(d)
+	if string(b) != `"2024-07-16"` {
+		t.Fatalf("expected AD round trip, got %s", b)
+	}
+	b, _ = json.Marshal(d.In(BS))
+	if string(b) != `"2081-04-01"` {
+		t.Fatalf("expected BS marshal, got %s", b)
+	}
+
+	bsDate := MustParseDate("2081-04-01", BS)
+	if !bsDate.Equal(d) {
+		t.Fatalf("expected BS and AD parse of the same day to be equal")
+	}
+}
+
+func TestTimestampJSON(t *testing.T) {
+	raw := `"2024-07-16T04:45:00.000Z"`
+	var ts Timestamp
+	if err := json.Unmarshal([]byte(raw), &ts); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	b, _ := json.Marshal(ts)
+	if string(b) != raw {
+		t.Fatalf("expected byte-identical round trip, got %s", b)
+	}
+
+	nst := time.FixedZone("NPT", 5*3600+45*60)
+	bs := NewTimestamp(ts.Time().In(nst)).In(BS)
+	if bs.String() != "2081-04-01T10:30:00+05:45" {
+		t.Fatalf("unexpected BS timestamp %s", bs)
+	}
+
+	if err := json.Unmarshal([]byte(`"sometime"`), &ts); err != nil || ts.Raw() != "sometime" {
+		t.Fatalf("expected unparseable text to be kept, got %v %q", err, ts.Raw())
+	}
+}
@@ -0,0 +1,240 @@
+package calendar
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// System selects the calendar a date is parsed from or formatted in.
+type System int
+
+const (
+	// AD is the Gregorian calendar, which Tigg uses on the wire.
+	AD System = iota
+	// BS is the Bikram Sambat calendar.
+	BS
+)
+
+func (s System) String() string {
+	if s == BS {
+		return "BS"
+	}
+	return "AD"
+}
+
+// ADLayout is the layout of AD dates.
+const ADLayout = "2006-01-02"
+
+// Date is a calendar day. It is stored as an AD day and remembers the system
+// it formats and marshals in, so the same value can be written as
+// "2024-07-16" (AD) or "2081-04-01" (BS). The zero value is an empty date.
+type Date struct {
+	t   time.Time
+	sys System
+}
+
+// NewDate returns the AD calendar day of t.
+func NewDate(t time.Time) Date {
+	return Date{t: time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)}
+}
+
+// FromBS returns the Date for a BS day. It formats in BS.
+func FromBS(d BSDate) (Date, error) {
+	t, err := d.ToAD()
+	if err != nil {
+		return Date{}, err
+	}
+	return Date{t: t, sys: BS}, nil
+}
+
+// ParseDate parses a YYYY-MM-DD date in the given system.
+func ParseDate(s string, sys System) (Date, error) {
+	if sys == BS {
+		bs, err := ParseBS(s)
+		if err != nil {
+			return Date{}, err
+		}
+		return FromBS(bs)
+	}
+	t, err := time.Parse(ADLayout, strings.TrimSpace(s))
+	if err != nil {
+		return Date{}, fmt.Errorf("invalid AD date %q: expected YYYY-MM-DD", s)
+	}
+	return Date{t: t}, nil
+}
+
+// MustParseDate is like ParseDate but panics on error.
+func MustParseDate(s string, sys System) Date {
+	d, err := ParseDate(s, sys)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// IsZero reports whether the date is unset.
+func (d Date) IsZero() bool { return d.t.IsZero() }
+
+// Time returns midnight UTC of the AD day.
+func (d Date) Time() time.Time { return d.t }
+
+// System returns the calendar the date formats in.
+func (d Date) System() System { return d.sys }
+
+// In returns the same day, formatting in sys.
+func (d Date) In(sys System) Date {
+	d.sys = sys
+	return d
+}
+
+// BS returns the day in the Bikram Sambat calendar.
+func (d Date) BS() (BSDate, error) { return ToBS(d.t) }
+
+// Format renders the day as YYYY-MM-DD in sys. Days outside the BS table are
+// rendered in AD.
+func (d Date) Format(sys System) string {
+	if d.IsZero() {
+		return ""
+	}
+	if sys == BS {
+		if bs, err := d.BS(); err == nil {
+			return bs.String()
+		}
+	}
+	return d.t.Format(ADLayout)
+}
+
+// String renders the day in its own system.
+func (d Date) String() string { return d.Format(d.sys) }
+
+// Before reports whether d is an earlier day than o.
+func (d Date) Before(o Date) bool { return d.t.Before(o.t) }
+
+// After reports whether d is a later day than o.
+func (d Date) After(o Date) bool { return d.t.After(o.t) }
+
+// Equal reports whether d and o are the same day, whatever their systems.
+func (d Date) Equal(o Date) bool { return d.t.Equal(o.t) }
+
+// AddDays moves the date by n days.
+func (d Date) AddDays(n int) Date {
+	d.t = d.t.AddDate(0, 0, n)
+	return d
+}
+
+// AddBSMonths moves the date by n Bikram Sambat months; see BSDate.AddMonths.
+func (d Date) AddBSMonths(n int) (Date, error) {
+	bs, err := d.BS()
+	if err != nil {
+		return Date{}, err
+	}
+	if bs, err = bs.AddMonths(n); err != nil {
+		return Date{}, err
+	}
+	out, err := FromBS(bs)
+	if err != nil {
+		return Date{}, err
+	}
+	return out.In(d.sys), nil
+}
+
+// MarshalJSON writes the date as a quoted YYYY-MM-DD string in its own system.
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads an AD date, which is what Tigg sends. Timestamps are
+// truncated to their date and an empty string leaves the zero date.
+func (d *Date) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*d = Date{}
+		return nil
+	}
+	if len(s) > len(ADLayout) {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			*d = NewDate(t)
+			return nil
+		}
+	}
+	parsed, err := ParseDate(s, AD)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Timestamp is an instant such as Account.CreatedAt. It keeps the text it was
+// decoded from, so an AD timestamp is written back byte for byte, and it can be
+// switched to BS for display. Text that does not parse is kept as-is with a
+// zero Time instead of failing the whole decode.
+type Timestamp struct {
+	t   time.Time
+	raw string
+	sys System
+}
+
+var timestampLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", ADLayout}
+
+// NewTimestamp wraps t.
+func NewTimestamp(t time.Time) Timestamp { return Timestamp{t: t} }
+
+// Time returns the instant, or the zero time if the text did not parse.
+func (ts Timestamp) Time() time.Time { return ts.t }
+
+// Raw returns the text the timestamp was decoded from.
+func (ts Timestamp) Raw() string { return ts.raw }
+
+// IsZero reports whether the timestamp is unset.
+func (ts Timestamp) IsZero() bool { return ts.t.IsZero() && ts.raw == "" }
+
+// In returns the same instant, formatting in sys.
+func (ts Timestamp) In(sys System) Timestamp {
+	ts.sys = sys
+	return ts
+}
+
+// Date returns the calendar day of the instant in its own location.
+func (ts Timestamp) Date() Date { return NewDate(ts.t).In(ts.sys) }
+
+// String renders the timestamp. AD timestamps use the original text when there
+// is one; BS timestamps are rendered as the BS date followed by the clock time
+// and offset, e.g. "2081-04-01T10:30:00+05:45".
+func (ts Timestamp) String() string {
+	if ts.sys == BS && !ts.t.IsZero() {
+		if bs, err := ToBS(ts.t); err == nil {
+			return bs.String() + ts.t.Format("T15:04:05Z07:00")
+		}
+	}
+	if ts.raw != "" || ts.t.IsZero() {
+		return ts.raw
+	}
+	return ts.t.Format(time.RFC3339)
+}
+
+// MarshalJSON writes the timestamp as a quoted string.
+func (ts Timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ts.String())
+}
+
+// UnmarshalJSON reads RFC 3339 and common SQL-style timestamps.
+func (ts *Timestamp) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*ts = Timestamp{raw: s}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			ts.t = t
+			break
+		}
+	}
+	return nil
+}
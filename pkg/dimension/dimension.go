@@ -0,0 +1,205 @@
+package dimension
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/internal/jsonfile"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+)
+
+// Common dimension names.
+const (
+	CostCenter = "cost_center"
+	Department = "department"
+	Project    = "project"
+	Branch     = "branch"
+)
+
+// Value is one allowed value of a dimension. Inactive values stay on old
+// vouchers but cannot be used on new ones.
+type Value struct {
+	Code     string `json:"code"`
+	Name     string `json:"name,omitempty"`
+	Inactive bool   `json:"inactive,omitempty"`
+}
+
+// Dimension is a named analytic axis with its managed list of values. If
+// Required is set every voucher line must carry a value for it.
+type Dimension struct {
+	Name     string  `json:"name"`
+	Required bool    `json:"required,omitempty"`
+	Values   []Value `json:"values"`
+}
+
+func (d Dimension) value(code string) (Value, bool) {
+	for _, v := range d.Values {
+		if v.Code == code {
+			return v, true
+		}
+	}
+	return Value{}, false
+}
+
+// Registry holds the dimensions vouchers are validated against. It satisfies
+// journal.DimensionChecker.
+type Registry struct {
+	mu   sync.RWMutex
+	dims map[string]Dimension
+}
+
+func NewRegistry() *Registry {
+	return &Registry{dims: map[string]Dimension{}}
+}
+
+// Define adds a dimension or replaces the one with the same name.
+func (r *Registry) Define(d Dimension) error {
+	if d.Name == "" {
+		return fmt.Errorf("dimension name is required")
+	}
+	seen := map[string]bool{}
+	for _, v := range d.Values {
+		if v.Code == "" {
+			return fmt.Errorf("dimension %s: value code is required", d.Name)
+		}
+		if seen[v.Code] {
+			return fmt.Errorf("dimension %s: duplicate value %q", d.Name, v.Code)
+		}
+		seen[v.Code] = true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d.Values = append([]Value(nil), d.Values...)
+	r.dims[d.Name] = d
+	return nil
+}
+
+// AddValue adds a value to a dimension, or updates it if the code exists.
+func (r *Registry) AddValue(name string, v Value) error {
+	if v.Code == "" {
+		return fmt.Errorf("dimension %s: value code is required", name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.dims[name]
+	if !ok {
+		return fmt.Errorf("dimension %q is not defined", name)
+	}
+	values := append([]Value(nil), d.Values...)
+	for i := range values {
+		if values[i].Code == v.Code {
+			values[i] = v
+			d.Values = values
+			r.dims[name] = d
+			return nil
+		}
+	}
+	d.Values = append(values, v)
+	r.dims[name] = d
+	return nil
+}
+
+// Deactivate marks a value as no longer usable on new vouchers.
+func (r *Registry) Deactivate(name, code string) error {
+	d, ok := r.Dimension(name)
+	if !ok {
+		return fmt.Errorf("dimension %q is not defined", name)
+	}
+	v, ok := d.value(code)
+	if !ok {
+		return fmt.Errorf("dimension %s has no value %q", name, code)
+	}
+	v.Inactive = true
+	return r.AddValue(name, v)
+}
+
+// Dimension returns the dimension with the given name.
+func (r *Registry) Dimension(name string) (Dimension, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.dims[name]
+	return d, ok
+}
+
+// Dimensions returns every dimension sorted by name.
+func (r *Registry) Dimensions() []Dimension {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sortedLocked()
+}
+
+// CheckDimensions validates the dimension tags of every line: each name must
+// be defined, each value must be an active value of its dimension and required
+// dimensions must be present. Problems are returned as journal.ValidationErrors.
+func (r *Registry) CheckDimensions(jv journal.JournalVoucher) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	dims := r.sortedLocked()
+	var errs journal.ValidationErrors
+	for i, item := range jv.Items {
+		names := make([]string, 0, len(item.Dimensions))
+		for name := range item.Dimensions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			field := fmt.Sprintf("items[%d].dimensions.%s", i, name)
+			d, ok := r.dims[name]
+			if !ok {
+				errs = append(errs, journal.FieldError{Field: field, Message: "unknown dimension"})
+				continue
+			}
+			code := item.Dimensions[name]
+			v, ok := d.value(code)
+			switch {
+			case !ok:
+				errs = append(errs, journal.FieldError{Field: field, Message: fmt.Sprintf("%q is not a valid value", code)})
+			case v.Inactive:
+				errs = append(errs, journal.FieldError{Field: field, Message: fmt.Sprintf("%q is inactive", code)})
+			}
+		}
+
+		for _, d := range dims {
+			if d.Required && item.Dimensions[d.Name] == "" {
+				errs = append(errs, journal.FieldError{Field: fmt.Sprintf("items[%d].dimensions.%s", i, d.Name), Message: "is required"})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (r *Registry) sortedLocked() []Dimension {
+	out := make([]Dimension, 0, len(r.dims))
+	for _, d := range r.dims {
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// LoadFile reads a registry saved with SaveFile. A missing file gives an
+// empty registry.
+func LoadFile(path string) (*Registry, error) {
+	r := NewRegistry()
+	var dims []Dimension
+	if err := jsonfile.Read(path, &dims); err != nil {
+		return nil, err
+	}
+	for _, d := range dims {
+		if err := r.Define(d); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// SaveFile writes the registry as a JSON list of dimensions, in name order.
+func (r *Registry) SaveFile(path string) error {
+	return jsonfile.Write(path, r.Dimensions())
+}
@@ -0,0 +1,111 @@
+package dimension
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+func newRegistry(t *testing.T) *Registry {
+	t.Helper()
+	r := NewRegistry()
+	if err := r.Define(Dimension{Name: CostCenter, Required: true, Values: []Value{{Code: "KTM"}, {Code: "PKR"}}}); err != nil {
+		t.Fatalf("Define failed: %v", err)
+	}
+	if err := r.Define(Dimension{Name: Project, Values: []Value{{Code: "ERP"}}}); err != nil {
+		t.Fatalf("Define failed: %v", err)
+	}
+	return r
+}
+
+func item(txnType, amount string, tags map[string]string) journal.JournalVoucherItem {
+	return journal.JournalVoucherItem{AccountID: "acc", Amount: money.MustParse(amount, "NPR"), TxnType: txnType, Dimensions: tags}
+}
+
+func TestCheckDimensions(t *testing.T) {
+	r := newRegistry(t)
+	if err := r.Deactivate(CostCenter, "PKR"); err != nil {
+		t.Fatalf("Deactivate failed: %v", err)
+	}
+
+	jv := journal.JournalVoucher{Items: []journal.JournalVoucherItem{
+		item("DEBIT", "10", map[string]string{CostCenter: "KTM", Project: "ERP"}),
+		item("DEBIT", "10", map[string]string{CostCenter: "PKR"}),
+		item("CREDIT", "10", map[string]string{CostCenter: "BRT", "region": "east"}),
+		item("CREDIT", "10", nil),
+	}}
+
+	var verrs journal.ValidationErrors
+	if err := r.CheckDimensions(jv); !errors.As(err, &verrs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	want := []string{
+		"items[1].dimensions.cost_center",
+		"items[2].dimensions.cost_center",
+		"items[2].dimensions.region",
+		"items[3].dimensions.cost_center",
+	}
+	got := make([]string, len(verrs))
+	for i, fe := range verrs {
+		got[i] = fe.Field
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected fields %v, got %v", want, got)
+	}
+}
+
+func TestRegistryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dimensions.json")
+	r := newRegistry(t)
+	if err := r.AddValue(Project, Value{Code: "WEB", Name: "Website"}); err != nil {
+		t.Fatalf("AddValue failed: %v", err)
+	}
+	if err := r.SaveFile(path); err != nil {
+		t.Fatalf("SaveFile failed: %v", err)
+	}
+
+	loaded, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	d, ok := loaded.Dimension(Project)
+	if !ok || len(d.Values) != 2 || d.Values[1].Name != "Website" {
+		t.Fatalf("unexpected project dimension %+v", d)
+	}
+	if cc, _ := loaded.Dimension(CostCenter); !cc.Required {
+		t.Fatal("expected cost center to stay required")
+	}
+}
+
+func TestFilterAndGroup(t *testing.T) {
+	vouchers := []journal.JournalVoucher{
+		{Code: "JV-1", Items: []journal.JournalVoucherItem{
+			item("DEBIT", "10", map[string]string{CostCenter: "KTM"}),
+			item("CREDIT", "10", nil),
+		}},
+		{Code: "JV-2", Items: []journal.JournalVoucherItem{
+			item("DEBIT", "20", map[string]string{CostCenter: "PKR"}),
+			item("CREDIT", "20", map[string]string{CostCenter: "KTM"}),
+		}},
+	}
+
+	filtered := Filter{CostCenter: "KTM"}.Apply(vouchers)
+	if len(filtered) != 2 || len(filtered[0].Items) != 1 || len(filtered[1].Items) != 1 || filtered[1].Items[0].TxnType != "CREDIT" {
+		t.Fatalf("unexpected filter result %+v", filtered)
+	}
+	if len(vouchers[0].Items) != 2 {
+		t.Fatal("expected Apply to leave its input alone")
+	}
+
+	groups := GroupBy(vouchers, CostCenter)
+	if strings.Join(Keys(groups), ",") != ",KTM,PKR" {
+		t.Fatalf("unexpected groups %v", Keys(groups))
+	}
+	if l := groups["PKR"][0]; l.Voucher.Code != "JV-2" || l.Index != 0 {
+		t.Fatalf("unexpected line %+v", l)
+	}
+}
@@ -0,0 +1,83 @@
+package dimension
+
+import (
+	"sort"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+)
+
+// Filter selects voucher lines by dimension value. A line matches when it
+// carries every listed value; the empty filter matches every line.
+type Filter map[string]string
+
+// Matches reports whether the line carries every value in f.
+func (f Filter) Matches(item journal.JournalVoucherItem) bool {
+	for name, value := range f {
+		if item.Dimensions[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns copies of the vouchers holding only their matching lines.
+// Vouchers without a matching line are dropped. Note that filtered vouchers no
+// longer balance.
+func (f Filter) Apply(vouchers []journal.JournalVoucher) []journal.JournalVoucher {
+	if len(f) == 0 {
+		return vouchers
+	}
+	var out []journal.JournalVoucher
+	for _, jv := range vouchers {
+		var items []journal.JournalVoucherItem
+		for _, item := range jv.Items {
+			if f.Matches(item) {
+				items = append(items, item)
+			}
+		}
+		if len(items) > 0 {
+			jv.Items = items
+			out = append(out, jv)
+		}
+	}
+	return out
+}
+
+// Line is one voucher line together with the voucher it belongs to.
+type Line struct {
+	Voucher *journal.JournalVoucher
+	Index   int
+	Item    journal.JournalVoucherItem
+}
+
+// Lines flattens vouchers into their lines, in order.
+func Lines(vouchers []journal.JournalVoucher) []Line {
+	var out []Line
+	for i := range vouchers {
+		for j, item := range vouchers[i].Items {
+			out = append(out, Line{Voucher: &vouchers[i], Index: j, Item: item})
+		}
+	}
+	return out
+}
+
+// GroupBy groups the lines of vouchers by their value of the named dimension.
+// Untagged lines are grouped under "".
+func GroupBy(vouchers []journal.JournalVoucher, name string) map[string][]Line {
+	groups := map[string][]Line{}
+	for _, l := range Lines(vouchers) {
+		value := l.Item.Dimensions[name]
+		groups[value] = append(groups[value], l)
+	}
+	return groups
+}
+
+// Keys returns the group keys of GroupBy's result in sorted order.
+func Keys(groups map[string][]Line) []string {
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
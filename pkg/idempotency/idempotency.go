@@ -0,0 +1,201 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tiggerrors "github.com/rohankarmacharya/TigIntegration/pkg/errors"
+)
+
+// Header is the HTTP header the key is sent in, so the API can deduplicate
+// as well if it supports it.
+const Header = "Idempotency-Key"
+
+// Status is the recorded outcome of a keyed call.
+type Status string
+
+const (
+	// StatusPending is written before the call is sent. A record left pending
+	// means the outcome is unknown, e.g. after a timeout.
+	StatusPending Status = "PENDING"
+	// StatusSucceeded records a call that completed; Result holds its response.
+	StatusSucceeded Status = "SUCCEEDED"
+	// StatusFailed records a call the API rejected, so nothing was created.
+	StatusFailed Status = "FAILED"
+)
+
+// Record is what the store keeps per key and operation.
+type Record struct {
+	Key         string          `json:"key"`
+	Operation   string          `json:"operation"`
+	RequestHash string          `json:"request_hash"`
+	Status      Status          `json:"status"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// ErrKeyReused is returned when a key is used again for a different request.
+var ErrKeyReused = fmt.Errorf("idempotency key was already used for a different request")
+
+// Key pairs an idempotency key with the store it is recorded in. The zero Key
+// turns idempotency off. Records are kept per key and operation, so a helper
+// that creates and then posts a voucher can run both steps under one key.
+type Key struct {
+	Store Store
+	Value string
+}
+
+// Enabled reports whether calls made with k are deduplicated.
+func (k Key) Enabled() bool {
+	return k.Value != ""
+}
+
+// For derives the key of one call from k and the resource it acts on, such as
+// a voucher's code for a create or its ID for a status change. A service
+// holding one key can then make many different calls, while a retry of the
+// same call still maps to the same record. The zero Key stays disabled.
+func (k Key) For(resource ...string) Key {
+	if !k.Enabled() {
+		return k
+	}
+	return Key{Store: k.Store, Value: k.Value + "/" + strings.Join(resource, "/")}
+}
+
+// HashRequest returns a stable hash of an operation and its payload.
+func HashRequest(operation string, payload interface{}) (string, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(operation+"\n"), b...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Do runs call at most once per key:
+//
+//   - a key that already succeeded returns the recorded result without
+//     calling anything;
+//   - a key whose earlier attempt has an unknown outcome first runs lookup,
+//     which should find the resource by a natural key such as its code, and
+//     only calls again if lookup finds nothing (lookup may be nil);
+//   - a key whose earlier attempt was rejected by the API is retried.
+//
+// Reusing a key for the same operation with a different payload fails with
+// ErrKeyReused. Concurrent calls with the same key and operation run one at a
+// time within a process, so the second sees the first's outcome; processes
+// sharing a store are not coordinated.
+func Do[T any](k Key, operation string, payload interface{}, call func() (*T, error), lookup func() (*T, error)) (*T, error) {
+	if !k.Enabled() {
+		return call()
+	}
+	if k.Store == nil {
+		return nil, fmt.Errorf("idempotency key %q given without a store", k.Value)
+	}
+
+	hash, err := HashRequest(operation, payload)
+	if err != nil {
+		return nil, err
+	}
+	key := k.Value + "/" + operation
+	defer keyLocks.lock(key)()
+
+	rec, err := k.Store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if rec != nil && rec.RequestHash != hash {
+		return nil, fmt.Errorf("%w: %s", ErrKeyReused, k.Value)
+	}
+
+	if rec != nil && rec.Status == StatusSucceeded {
+		var out T
+		if err := json.Unmarshal(rec.Result, &out); err != nil {
+			return nil, err
+		}
+		return &out, nil
+	}
+
+	if rec != nil && rec.Status == StatusPending && lookup != nil {
+		found, err := lookup()
+		if err != nil {
+			return nil, err
+		}
+		if found != nil {
+			return found, succeed(k.Store, key, operation, hash, found)
+		}
+	}
+
+	if err := k.Store.Put(Record{Key: key, Operation: operation, RequestHash: hash, Status: StatusPending, UpdatedAt: time.Now()}); err != nil {
+		return nil, err
+	}
+	out, err := call()
+	if err != nil {
+		if definite(err) {
+			if perr := k.Store.Put(Record{Key: key, Operation: operation, RequestHash: hash, Status: StatusFailed, Error: err.Error(), UpdatedAt: time.Now()}); perr != nil {
+				return nil, perr
+			}
+		}
+		return nil, err
+	}
+	return out, succeed(k.Store, key, operation, hash, out)
+}
+
+// keyLocks serializes Do per key. Entries are dropped once nobody holds or
+// waits for them.
+var keyLocks = &lockSet{locks: map[string]*keyLock{}}
+
+type lockSet struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks key and returns the unlock function.
+func (s *lockSet) lock(key string) func() {
+	s.mu.Lock()
+	l, ok := s.locks[key]
+	if !ok {
+		l = &keyLock{}
+		s.locks[key] = l
+	}
+	l.refs++
+	s.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		s.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(s.locks, key)
+		}
+		s.mu.Unlock()
+	}
+}
+
+func succeed(store Store, key, operation, hash string, result interface{}) error {
+	b, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return store.Put(Record{Key: key, Operation: operation, RequestHash: hash, Status: StatusSucceeded, Result: b, UpdatedAt: time.Now()})
+}
+
+// definite reports whether err means the API rejected the call, so nothing was
+// written. Transport errors and 5xx responses leave the outcome unknown. Local
+// errors raised before sending are treated the same way, which is harmless:
+// the retry finds nothing and calls again.
+func definite(err error) bool {
+	var te *tiggerrors.TiggError
+	return errors.As(err, &te) && te.StatusCode < 500
+}
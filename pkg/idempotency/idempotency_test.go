@@ -0,0 +1,132 @@
+package idempotency
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tiggerrors "github.com/rohankarmacharya/TigIntegration/pkg/errors"
+)
+
+type resource struct {
+	ID   string `json:"id"`
+	Code string `json:"code"`
+}
+
+func TestDoReplaysAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.json")
+	calls := 0
+	create := func() (*resource, error) {
+		calls++
+		return &resource{ID: "r-1", Code: "A"}, nil
+	}
+
+	k := Key{Store: NewFileStore(path), Value: "k1"}
+	if _, err := Do(k, "resource.create", resource{Code: "A"}, create, nil); err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+
+	k = Key{Store: NewFileStore(path), Value: "k1"}
+	got, err := Do(k, "resource.create", resource{Code: "A"}, create, nil)
+	if err != nil || got.ID != "r-1" || calls != 1 {
+		t.Fatalf("expected replay of r-1 with one call, got %+v %v after %d calls", got, err, calls)
+	}
+
+	// The same key may be used for a different operation, e.g. create then post.
+	if _, err := Do(k, "resource.post", "r-1", create, nil); err != nil || calls != 2 {
+		t.Fatalf("expected a separate record per operation, got %v after %d calls", err, calls)
+	}
+}
+
+func TestDoRetriesRejectedCalls(t *testing.T) {
+	k := Key{Store: NewMemoryStore(), Value: "k2"}
+	rejected := &tiggerrors.TiggError{StatusCode: 422, Message: "code taken"}
+	lookups := 0
+	lookup := func() (*resource, error) {
+		lookups++
+		return nil, nil
+	}
+
+	if _, err := Do(k, "resource.create", "A", func() (*resource, error) { return nil, rejected }, lookup); !errors.Is(err, rejected) {
+		t.Fatalf("expected the API error, got %v", err)
+	}
+	rec, _ := k.Store.Get("k2/resource.create")
+	if rec == nil || rec.Status != StatusFailed || rec.Error == "" {
+		t.Fatalf("expected a failed record, got %+v", rec)
+	}
+
+	got, err := Do(k, "resource.create", "A", func() (*resource, error) { return &resource{ID: "r-2"}, nil }, lookup)
+	if err != nil || got.ID != "r-2" {
+		t.Fatalf("expected the retry to run, got %+v %v", got, err)
+	}
+	if lookups != 0 {
+		t.Fatalf("expected no lookup after a definite failure, got %d", lookups)
+	}
+}
+
+func TestDoWithoutKey(t *testing.T) {
+	calls := 0
+	create := func() (*resource, error) {
+		calls++
+		return &resource{}, nil
+	}
+	Do(Key{}, "resource.create", nil, create, nil)
+	Do(Key{}, "resource.create", nil, create, nil)
+	if calls != 2 {
+		t.Fatalf("expected calls without a key to always run, got %d", calls)
+	}
+
+	if _, err := Do(Key{Value: "k3"}, "resource.create", nil, create, nil); err == nil {
+		t.Fatal("expected a key without a store to fail")
+	}
+}
+
+func TestDoConcurrentCallsRunOnce(t *testing.T) {
+	k := Key{Store: NewMemoryStore(), Value: "k4"}
+	var calls int32
+	create := func() (*resource, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return &resource{ID: "r-4"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := Do(k, "resource.create", "A", create, nil)
+			if err != nil || got.ID != "r-4" {
+				t.Errorf("expected r-4, got %+v %v", got, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("expected one call, got %d", calls)
+	}
+	if len(keyLocks.locks) != 0 {
+		t.Fatalf("expected key locks to be released, got %d", len(keyLocks.locks))
+	}
+}
+
+func TestKeyFor(t *testing.T) {
+	store := NewMemoryStore()
+	k := Key{Store: store, Value: "batch"}
+	calls := 0
+	call := func() (*string, error) { calls++; s := "ok"; return &s, nil }
+	for _, code := range []string{"JV-1", "JV-2", "JV-1"} {
+		if _, err := Do(k.For(code), "create", code, call, nil); err != nil {
+			t.Fatalf("Do %s failed: %v", code, err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("expected one call per resource, got %d", calls)
+	}
+	if (Key{}).For("JV-1").Enabled() {
+		t.Fatal("expected the zero key to stay disabled")
+	}
+}
@@ -0,0 +1,85 @@
+package idempotency
+
+import (
+	"sync"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/internal/jsonfile"
+)
+
+// Store records the outcome of keyed calls.
+type Store interface {
+	Get(key string) (*Record, error)
+	Put(r Record) error
+}
+
+// MemoryStore keeps records in memory, which protects retries within one
+// process. Use FileStore to survive restarts.
+type MemoryStore struct {
+	mu   sync.Mutex
+	data map[string]Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: map[string]Record{}}
+}
+
+func (s *MemoryStore) Get(key string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.data[key]
+	if !ok {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+func (s *MemoryStore) Put(r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[r.Key] = r
+	return nil
+}
+
+// FileStore persists records in a JSON file keyed by idempotency key, so a
+// retried call after a restart still finds the first outcome.
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (s *FileStore) load() (map[string]Record, error) {
+	data := map[string]Record{}
+	if err := jsonfile.Read(s.path, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *FileStore) Get(key string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	r, ok := data[key]
+	if !ok {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+func (s *FileStore) Put(r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := s.load()
+	if err != nil {
+		return err
+	}
+	data[r.Key] = r
+	return jsonfile.Write(s.path, data)
+}
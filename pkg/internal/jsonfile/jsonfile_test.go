@@ -0,0 +1,40 @@
+package jsonfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+
+	data := map[string]int{"kept": 1}
+	if err := Read(path, &data); err != nil || data["kept"] != 1 {
+		t.Fatalf("expected a missing file to leave data alone, got %v %v", data, err)
+	}
+
+	if err := Write(path, map[string]int{"a": 1}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := Write(path, map[string]int{"a": 2, "b": 3}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var got map[string]int
+	if err := Read(path, &got); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(got) != 2 || got["a"] != 2 || got["b"] != 3 {
+		t.Fatalf("unexpected data %v", got)
+	}
+	if matches, _ := filepath.Glob(path + ".tmp-*"); len(matches) != 0 {
+		t.Fatalf("expected no temporary files left, got %v", matches)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Read(path, &got); err == nil {
+		t.Fatal("expected a corrupt file to be reported")
+	}
+}
@@ -0,0 +1,226 @@
+package journal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// AccountLister lists the chart of accounts. *account.Service satisfies it.
+type AccountLister interface {
+	ListAccounts() ([]account.Account, error)
+}
+
+type builderLine struct {
+	code       string
+	amount     money.Money
+	txnType    string
+	narration  string
+	dimensions map[string]string
+}
+
+// Builder assembles a JournalVoucher from account codes. Codes are resolved to
+// account IDs with a single ListAccounts call when Build is called.
+//
+//	jv, err := journal.NewBuilder(accounts, "JV-0001", date, "NPR").
+//		Debit("EX0001", rent, "Shrawan rent").
+//		Credit("BA0001", rent, "").
+//		Build(ctx)
+type Builder struct {
+	accounts AccountLister
+	voucher  JournalVoucher
+	lines    []builderLine
+
+	roundingCode  string
+	roundingLimit money.Money
+
+	baseCurrency string
+	rate         money.Rate
+	fxCode       string
+}
+
+// NewBuilder starts a voucher with the given code, date and currency.
+func NewBuilder(accounts AccountLister, code string, date calendar.Date, currency string) *Builder {
+	return &Builder{
+		accounts: accounts,
+		voucher: JournalVoucher{
+			Code:         code,
+			Date:         date,
+			CurrencyCode: currency,
+		},
+	}
+}
+
+// Narration sets the voucher-level narration.
+func (b *Builder) Narration(narration string) *Builder {
+	b.voucher.Narration = narration
+	return b
+}
+
+// Debit adds a debit line against the account with the given code.
+func (b *Builder) Debit(code string, amount money.Money, narration string) *Builder {
+	return b.line(code, amount, TxnTypeDebit, narration)
+}
+
+// Credit adds a credit line against the account with the given code.
+func (b *Builder) Credit(code string, amount money.Money, narration string) *Builder {
+	return b.line(code, amount, TxnTypeCredit, narration)
+}
+
+func (b *Builder) line(code string, amount money.Money, txnType, narration string) *Builder {
+	if amount.Currency() == "" {
+		amount = amount.WithCurrency(b.voucher.CurrencyCode)
+	}
+	b.lines = append(b.lines, builderLine{code: code, amount: amount, txnType: txnType, narration: narration})
+	return b
+}
+
+// Tag sets a dimension value on the line added last. It does nothing if no
+// line has been added yet.
+func (b *Builder) Tag(dimension, value string) *Builder {
+	if len(b.lines) == 0 {
+		return b
+	}
+	l := &b.lines[len(b.lines)-1]
+	tags := make(map[string]string, len(l.dimensions)+1)
+	for k, v := range l.dimensions {
+		tags[k] = v
+	}
+	tags[dimension] = value
+	l.dimensions = tags
+	return b
+}
+
+// AutoBalance posts any difference between debits and credits to the rounding
+// account with the given code, as long as the difference does not exceed limit.
+// Larger differences are left alone and fail validation.
+func (b *Builder) AutoBalance(code string, limit money.Money) *Builder {
+	b.roundingCode = code
+	b.roundingLimit = limit
+	return b
+}
+
+// ExchangeRate makes the voucher a foreign-currency voucher against
+// baseCurrency. Base amounts are rounded half-even and any rounding difference
+// is posted to the account with code fxRoundingCode. See ApplyExchangeRate.
+func (b *Builder) ExchangeRate(baseCurrency string, rate money.Rate, fxRoundingCode string) *Builder {
+	b.baseCurrency = baseCurrency
+	b.rate = rate
+	b.fxCode = fxRoundingCode
+	return b
+}
+
+// Build resolves account codes, applies auto-balancing and validates the result.
+// Unknown and inactive accounts are reported together with any other problems
+// as ValidationErrors.
+func (b *Builder) Build(ctx context.Context) (*JournalVoucher, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	lines := append([]builderLine(nil), b.lines...)
+	if b.roundingCode != "" {
+		line, err := b.roundingLine(lines)
+		if err != nil {
+			return nil, err
+		}
+		if line != nil {
+			lines = append(lines, *line)
+		}
+	}
+
+	accounts, err := b.accounts.ListAccounts()
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	byCode := make(map[string]*account.Account, len(accounts))
+	for i := range accounts {
+		byCode[accounts[i].Code] = &accounts[i]
+	}
+
+	jv := b.voucher
+	jv.Items = make([]JournalVoucherItem, 0, len(lines))
+	for _, l := range lines {
+		jv.Items = append(jv.Items, JournalVoucherItem{
+			AccountCode: l.code,
+			Amount:      l.amount,
+			TxnType:     l.txnType,
+			Narration:   l.narration,
+			Dimensions:  l.dimensions,
+		})
+	}
+	if b.baseCurrency != "" {
+		if err := jv.ApplyExchangeRate(b.baseCurrency, b.rate, money.RoundHalfEven, b.fxCode); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs ValidationErrors
+	for i := range jv.Items {
+		item := &jv.Items[i]
+		acc, ok := byCode[item.AccountCode]
+		switch {
+		case !ok:
+			errs = append(errs, FieldError{Field: fmt.Sprintf("items[%d].account", i), Message: fmt.Sprintf("account with code %q not found", item.AccountCode)})
+		case acc.Inactive:
+			errs = append(errs, FieldError{Field: fmt.Sprintf("items[%d].account", i), Message: fmt.Sprintf("account %q is inactive", item.AccountCode)})
+		default:
+			item.AccountID = acc.ID
+			item.AccountCode = ""
+		}
+	}
+
+	if err := jv.Validate(); err != nil {
+		verrs, ok := err.(ValidationErrors)
+		if !ok {
+			return nil, err
+		}
+		errs = append(errs, verrs...)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return &jv, nil
+}
+
+// roundingLine returns the line that balances lines, or nil if they already balance.
+func (b *Builder) roundingLine(lines []builderLine) (*builderLine, error) {
+	diff := money.Zero(b.voucher.CurrencyCode)
+	for _, l := range lines {
+		var err error
+		switch l.txnType {
+		case TxnTypeDebit:
+			diff, err = diff.Add(l.amount)
+		case TxnTypeCredit:
+			diff, err = diff.Sub(l.amount)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if diff.IsZero() {
+		return nil, nil
+	}
+
+	c, err := diff.Abs().Cmp(b.roundingLimit)
+	if err != nil {
+		return nil, err
+	}
+	if c > 0 {
+		return nil, nil
+	}
+
+	line := builderLine{code: b.roundingCode, amount: diff.Abs(), narration: "Rounding adjustment"}
+	if diff.Sign() > 0 {
+		line.txnType = TxnTypeCredit
+	} else {
+		line.txnType = TxnTypeDebit
+	}
+	return &line, nil
+}
@@ -0,0 +1,57 @@
+package journal
+
+import "github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+
+// PeriodChecker decides whether vouchers may be dated on a day. *period.Locker
+// satisfies it.
+type PeriodChecker interface {
+	CheckDate(d calendar.Date, override bool) error
+}
+
+// WithPeriodLock returns a copy of the service that refuses to create, update,
+// post or void vouchers dated in a period pc reports as closed. An update is
+// checked against both the stored and the new date.
+func (s *Service) WithPeriodLock(pc PeriodChecker) *Service {
+	out := *s
+	out.periods = pc
+	return &out
+}
+
+// WithPeriodOverride returns a copy of the service that lets vouchers through
+// soft-closed periods. Hard-closed periods still reject them.
+func (s *Service) WithPeriodOverride() *Service {
+	out := *s
+	out.periodOverride = true
+	return &out
+}
+
+// DimensionChecker validates the dimension tags of voucher lines.
+// *dimension.Registry satisfies it.
+type DimensionChecker interface {
+	CheckDimensions(jv JournalVoucher) error
+}
+
+// WithDimensionCheck returns a copy of the service that validates line
+// dimensions with dc before creating or updating vouchers.
+func (s *Service) WithDimensionCheck(dc DimensionChecker) *Service {
+	out := *s
+	out.dimensions = dc
+	return &out
+}
+
+// checkPeriod returns the PeriodChecker's error for the voucher date. Missing
+// dates are left to Validate.
+func (s *Service) checkPeriod(jv JournalVoucher) error {
+	if s.periods == nil || jv.Date.IsZero() {
+		return nil
+	}
+	return s.periods.CheckDate(jv.Date, s.periodOverride)
+}
+
+// checkDimensions returns the DimensionChecker's error for the voucher lines.
+func (s *Service) checkDimensions(jv JournalVoucher) error {
+	if s.dimensions == nil {
+		return nil
+	}
+	return s.dimensions.CheckDimensions(jv)
+}
@@ -0,0 +1,67 @@
+package journal
+
+import (
+	"fmt"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// FXRoundingNarration is the narration of lines added by ApplyExchangeRate.
+const FXRoundingNarration = "FX rounding"
+
+// ApplyExchangeRate turns the voucher into a foreign-currency voucher against
+// baseCurrency: it records the rate and sets every item's BaseAmount, rounded
+// with mode. Rounding can leave the base amounts out of balance by a few minor
+// units; the difference is posted to fxAccountCode as a line with a zero
+// transaction amount. Lines added by an earlier call are replaced.
+func (jv *JournalVoucher) ApplyExchangeRate(baseCurrency string, rate money.Rate, mode money.RoundingMode, fxAccountCode string) error {
+	if rate.IsZero() {
+		return fmt.Errorf("exchange rate is required")
+	}
+	jv.BaseCurrencyCode = baseCurrency
+	jv.ExchangeRate = &rate
+
+	items := make([]JournalVoucherItem, 0, len(jv.Items)+1)
+	diff := money.Zero(baseCurrency)
+	for _, item := range jv.Items {
+		if item.Amount.IsZero() && item.Narration == FXRoundingNarration {
+			continue
+		}
+		base := rate.Convert(item.Amount, baseCurrency, mode)
+		item.BaseAmount = &base
+
+		var err error
+		switch item.TxnType {
+		case TxnTypeDebit:
+			diff, err = diff.Add(base)
+		case TxnTypeCredit:
+			diff, err = diff.Sub(base)
+		}
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+	}
+	jv.Items = items
+
+	if diff.IsZero() {
+		return nil
+	}
+	if fxAccountCode == "" {
+		return fmt.Errorf("base amounts differ by %s %s and no FX rounding account is configured", baseCurrency, diff.Abs())
+	}
+
+	adjustment := diff.Abs()
+	line := JournalVoucherItem{
+		AccountCode: fxAccountCode,
+		Amount:      money.Zero(jv.CurrencyCode),
+		BaseAmount:  &adjustment,
+		TxnType:     TxnTypeDebit,
+		Narration:   FXRoundingNarration,
+	}
+	if diff.Sign() > 0 {
+		line.TxnType = TxnTypeCredit
+	}
+	jv.Items = append(jv.Items, line)
+	return nil
+}
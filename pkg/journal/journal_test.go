@@ -0,0 +1,805 @@
+package journal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/client"
+	"github.com/rohankarmacharya/TigIntegration/pkg/idempotency"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+	"github.com/rohankarmacharya/TigIntegration/pkg/period"
+)
+
+const testSecret = "test-secret"
+
+// fakeTigg is an in-memory stand-in for the Tigg journal voucher endpoints.
+type fakeTigg struct {
+	mu       sync.Mutex
+	vouchers map[string]JournalVoucher
+	order    []string
+	calls    int
+
+	// keys records the Idempotency-Key header of every POST.
+	keys []string
+	// lostResponses makes the next POSTs succeed but answer 504, as a
+	// gateway timeout would.
+	lostResponses int
+}
+
+func newFakeTigg() *fakeTigg {
+	return &fakeTigg{vouchers: map[string]JournalVoucher{}}
+}
+
+func (f *fakeTigg) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+
+	path := strings.TrimPrefix(r.URL.Path, "/journal-vouchers")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case r.Method == "GET" && path == "":
+		list := make([]JournalVoucher, 0, len(f.order))
+		for _, id := range f.order {
+			list = append(list, f.vouchers[id])
+		}
+		writeData(w, list)
+	case r.Method == "GET" && len(parts) == 1:
+		jv, ok := f.vouchers[parts[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "journal voucher not found")
+			return
+		}
+		writeData(w, jv)
+	case r.Method == "PATCH" && len(parts) == 2:
+		jv, ok := f.vouchers[parts[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "journal voucher not found")
+			return
+		}
+		switch parts[1] {
+		case "post":
+			jv.VoucherStatus = StatusPosted
+		case "void":
+			jv.VoucherStatus = StatusVoided
+		}
+		f.vouchers[jv.ID] = jv
+		writeData(w, map[string]string{"message": "ok"})
+	case r.Method == "POST":
+		var jv JournalVoucher
+		if err := decodeSigned(r, &jv); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if path == "" {
+			jv.ID = fmt.Sprintf("jv-%d", len(f.order)+1)
+			jv.VoucherStatus = StatusDraft
+			f.order = append(f.order, jv.ID)
+		} else {
+			jv.ID = parts[0]
+			jv.VoucherStatus = f.vouchers[jv.ID].VoucherStatus
+		}
+		f.vouchers[jv.ID] = jv
+		f.keys = append(f.keys, r.Header.Get("Idempotency-Key"))
+		if f.lostResponses > 0 {
+			f.lostResponses--
+			writeError(w, http.StatusGatewayTimeout, "gateway timeout")
+			return
+		}
+		writeData(w, jv)
+	default:
+		writeError(w, http.StatusNotFound, "route not found")
+	}
+}
+
+// decodeSigned verifies the signature scheme used by signPayload and decodes the body.
+func decodeSigned(r *http.Request, v interface{}) error {
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return err
+	}
+	signature, _ := body["signature"].(string)
+	delete(body, "signature")
+	unsigned, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write([]byte(base64.StdEncoding.EncodeToString(unsigned)))
+	if hex.EncodeToString(mac.Sum(nil)) != signature {
+		return fmt.Errorf("Invalid signature")
+	}
+	return json.Unmarshal(unsigned, v)
+}
+
+func writeData(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": msg})
+}
+
+func newTestService(t *testing.T) (*Service, *fakeTigg) {
+	fake := newFakeTigg()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	cl := client.New(client.Config{
+		ClientKey: "test-key",
+		SecretKey: testSecret,
+		Namespace: "test",
+		BaseURL:   srv.URL,
+	})
+	return NewService(cl), fake
+}
+
+func sampleVoucher() JournalVoucher {
+	return JournalVoucher{
+		Code:         "JV-0001",
+		Date:         calendar.MustParseDate("2024-07-16", calendar.AD),
+		CurrencyCode: "NPR",
+		Narration:    "Office rent",
+		Items: []JournalVoucherItem{
+			{AccountCode: "EX0001", Amount: money.MustParse("25000.00", "NPR"), TxnType: TxnTypeDebit},
+			{AccountCode: "BA0001", Amount: money.MustParse("25000.00", "NPR"), TxnType: TxnTypeCredit},
+		},
+	}
+}
+
+func TestJournalVoucherCRUD(t *testing.T) {
+	svc, _ := newTestService(t)
+
+	created, err := svc.CreateJournalVoucher(sampleVoucher())
+	if err != nil {
+		t.Fatalf("CreateJournalVoucher failed: %v", err)
+	}
+	if created.ID == "" {
+		t.Fatal("expected non-empty ID for created journal voucher")
+	}
+	if created.VoucherStatus != StatusDraft {
+		t.Fatalf("expected created voucher to be %s, got %s", StatusDraft, created.VoucherStatus)
+	}
+
+	fetched, err := svc.GetJournalVoucherByID(created.ID)
+	if err != nil {
+		t.Fatalf("GetJournalVoucherByID failed: %v", err)
+	}
+	if fetched.Code != "JV-0001" || len(fetched.Items) != 2 {
+		t.Fatalf("GetJournalVoucherByID returned unexpected voucher: %+v", fetched)
+	}
+
+	fetched.Narration = "Office rent for Shrawan"
+	updated, err := svc.UpdateJournalVoucher(created.ID, *fetched)
+	if err != nil {
+		t.Fatalf("UpdateJournalVoucher failed: %v", err)
+	}
+	if updated.ID != created.ID {
+		t.Fatalf("Update created a new record! Expected ID %s, got %s", created.ID, updated.ID)
+	}
+	if updated.Narration != "Office rent for Shrawan" {
+		t.Fatalf("expected narration to be updated, got %q", updated.Narration)
+	}
+
+	list, err := svc.ListJournalVouchers()
+	if err != nil {
+		t.Fatalf("ListJournalVouchers failed: %v", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 voucher in list, got %d", len(list))
+	}
+}
+
+func TestEachJournalVoucher(t *testing.T) {
+	svc, _ := newTestService(t)
+	for _, code := range []string{"JV-0001", "JV-0002", "JV-0003"} {
+		jv := sampleVoucher()
+		jv.Code = code
+		if _, err := svc.CreateJournalVoucher(jv); err != nil {
+			t.Fatalf("CreateJournalVoucher failed: %v", err)
+		}
+	}
+
+	var codes []string
+	err := svc.EachJournalVoucher(func(jv JournalVoucher) error {
+		codes = append(codes, jv.Code)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachJournalVoucher failed: %v", err)
+	}
+	if strings.Join(codes, ",") != "JV-0001,JV-0002,JV-0003" {
+		t.Fatalf("unexpected vouchers %v", codes)
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	err = svc.EachJournalVoucher(func(JournalVoucher) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Fatalf("expected iteration to stop after one voucher, got %v after %d", err, n)
+	}
+}
+
+func TestUpdateJournalVoucherRejectsNonDraft(t *testing.T) {
+	svc, fake := newTestService(t)
+
+	jv := sampleVoucher()
+	jv.VoucherStatus = StatusPosted
+	_, err := svc.UpdateJournalVoucher("jv-1", jv)
+	var te *TransitionError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected *TransitionError, got %v", err)
+	}
+	if fake.calls != 0 {
+		t.Fatalf("expected no HTTP calls, got %d", fake.calls)
+	}
+}
+
+func TestGetJournalVoucherNotFound(t *testing.T) {
+	svc, _ := newTestService(t)
+
+	if _, err := svc.GetJournalVoucherByID("missing"); err == nil {
+		t.Fatal("expected error for missing voucher")
+	}
+}
+
+func TestVoucherLifecycle(t *testing.T) {
+	svc, fake := newTestService(t)
+
+	created, err := svc.CreateJournalVoucher(sampleVoucher())
+	if err != nil {
+		t.Fatalf("CreateJournalVoucher failed: %v", err)
+	}
+
+	posted, err := svc.PostVoucher(*created)
+	if err != nil {
+		t.Fatalf("PostVoucher failed: %v", err)
+	}
+	if posted.VoucherStatus != StatusPosted {
+		t.Fatalf("expected %s, got %s", StatusPosted, posted.VoucherStatus)
+	}
+
+	calls := fake.calls
+	var te *TransitionError
+	if _, err := svc.UpdateJournalVoucher(posted.ID, *posted); !errors.As(err, &te) {
+		t.Fatalf("expected *TransitionError editing a posted voucher, got %v", err)
+	}
+	if _, err := svc.PostVoucher(*posted); !errors.As(err, &te) {
+		t.Fatalf("expected *TransitionError re-posting, got %v", err)
+	}
+	if fake.calls != calls {
+		t.Fatalf("illegal transition reached the API")
+	}
+
+	voided, err := svc.VoidVoucher(*posted)
+	if err != nil {
+		t.Fatalf("VoidVoucher failed: %v", err)
+	}
+	if voided.VoucherStatus != StatusVoided {
+		t.Fatalf("expected %s, got %s", StatusVoided, voided.VoucherStatus)
+	}
+
+	calls = fake.calls
+	if _, err := svc.PostVoucher(*voided); !errors.As(err, &te) {
+		t.Fatalf("expected *TransitionError posting a voided voucher, got %v", err)
+	}
+	if te.From != StatusVoided || te.To != StatusPosted {
+		t.Fatalf("unexpected transition error: %v", te)
+	}
+	if fake.calls != calls {
+		t.Fatalf("illegal transition reached the API")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := sampleVoucher().Validate(); err != nil {
+		t.Fatalf("expected sample voucher to be valid, got %v", err)
+	}
+
+	jv := JournalVoucher{
+		Code:         "JV-0002",
+		CurrencyCode: "NPR",
+		Items: []JournalVoucherItem{
+			{AccountCode: "EX0001", Amount: money.MustParse("150.00", "NPR"), TxnType: TxnTypeDebit},
+			{AccountID: "a-1", AccountCode: "BA0001", Amount: money.MustParse("100.00", "NPR"), TxnType: TxnTypeCredit},
+			{AccountCode: "BA0002", Amount: money.MustParse("10.005", "NPR"), TxnType: TxnTypeCredit},
+			{AccountCode: "BA0003", Amount: money.MustParse("-5", "NPR"), TxnType: "CR"},
+		},
+	}
+
+	err := jv.Validate()
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+
+	want := []string{"date", "items[1].account", "items[2].amount", "items[3].amount", "items[3].txn_type", "items"}
+	got := make([]string, len(verrs))
+	for i, fe := range verrs {
+		got[i] = fe.Field
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected fields %v, got %v", want, got)
+	}
+}
+
+func TestCreateJournalVoucherValidatesLocally(t *testing.T) {
+	svc, fake := newTestService(t)
+
+	jv := sampleVoucher()
+	jv.Items[1].Amount = money.MustParse("24000.00", "NPR")
+	if _, err := svc.CreateJournalVoucher(jv); err == nil {
+		t.Fatal("expected unbalanced voucher to be rejected")
+	}
+	if fake.calls != 0 {
+		t.Fatalf("expected no HTTP calls, got %d", fake.calls)
+	}
+}
+
+type stubAccounts struct {
+	accounts []account.Account
+	calls    int
+}
+
+func (s *stubAccounts) ListAccounts() ([]account.Account, error) {
+	s.calls++
+	return s.accounts, nil
+}
+
+func newStubAccounts() *stubAccounts {
+	return &stubAccounts{accounts: []account.Account{
+		{ID: "acc-rent", Code: "EX0001", Name: "Rent"},
+		{ID: "acc-bank", Code: "BA0001", Name: "NIC Asia Bank"},
+		{ID: "acc-round", Code: "RO0001", Name: "Rounding Off"},
+		{ID: "acc-old", Code: "BA0099", Name: "Closed Bank", Inactive: true},
+	}}
+}
+
+func TestBuilder(t *testing.T) {
+	accounts := newStubAccounts()
+
+	jv, err := NewBuilder(accounts, "JV-0003", calendar.MustParseDate("2024-07-16", calendar.AD), "NPR").
+		Narration("Shrawan rent").
+		Debit("EX0001", money.MustParse("25000.00", ""), "Rent").
+		Credit("BA0001", money.MustParse("24999.99", ""), "").
+		AutoBalance("RO0001", money.MustParse("1.00", "NPR")).
+		Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if accounts.calls != 1 {
+		t.Fatalf("expected a single ListAccounts call, got %d", accounts.calls)
+	}
+	if len(jv.Items) != 3 {
+		t.Fatalf("expected rounding line to be added, got %d items", len(jv.Items))
+	}
+	last := jv.Items[2]
+	if last.AccountID != "acc-round" || last.TxnType != TxnTypeCredit || last.Amount.String() != "0.01" {
+		t.Fatalf("unexpected rounding line: %+v", last)
+	}
+	if jv.Items[0].AccountID != "acc-rent" || jv.Items[0].AccountCode != "" {
+		t.Fatalf("expected account code to be resolved to an ID: %+v", jv.Items[0])
+	}
+}
+
+func TestBuilderRejectsInactiveAndUnknownAccounts(t *testing.T) {
+	_, err := NewBuilder(newStubAccounts(), "JV-0004", calendar.MustParseDate("2024-07-16", calendar.AD), "NPR").
+		Debit("EX0404", money.MustParse("10", ""), "").
+		Credit("BA0099", money.MustParse("10", ""), "").
+		Build(context.Background())
+
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) || len(verrs) != 2 {
+		t.Fatalf("expected two account errors, got %v", err)
+	}
+}
+
+func formatDates(dates []time.Time) string {
+	s := make([]string, len(dates))
+	for i, d := range dates {
+		s[i] = d.Format(DateLayout)
+	}
+	return strings.Join(s, ",")
+}
+
+func TestRecurrenceOccurrences(t *testing.T) {
+	start := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	upTo := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		schedule Schedule
+		want     string
+	}{
+		{"monthly on day 31", Schedule{Kind: ScheduleMonthlyOnDay, Day: 31},
+			"2024-01-31,2024-02-29,2024-03-31,2024-04-30,2024-05-31,2024-06-30"},
+		{"monthly on day 10 skips before start", Schedule{Kind: ScheduleMonthlyOnDay, Day: 10},
+			"2024-02-10,2024-03-10,2024-04-10,2024-05-10,2024-06-10"},
+		{"last business day with two-day weekend", Schedule{Kind: ScheduleLastBusinessDay, Weekend: []time.Weekday{time.Saturday, time.Sunday}},
+			"2024-01-31,2024-02-29,2024-03-29,2024-04-30,2024-05-31,2024-06-28"},
+		{"quarterly", Schedule{Kind: ScheduleQuarterly, Day: 20},
+			"2024-01-20,2024-04-20"},
+		{"every six weeks", Schedule{Kind: ScheduleInterval, Every: 6, Unit: UnitWeek},
+			"2024-01-15,2024-02-26,2024-04-08,2024-05-20"},
+	}
+	for _, c := range cases {
+		rec := Recurrence{ID: "r", Schedule: c.schedule, Start: start}
+		dates, err := rec.Occurrences(upTo)
+		if err != nil {
+			t.Fatalf("%s: Occurrences failed: %v", c.name, err)
+		}
+		if got := formatDates(dates); got != c.want {
+			t.Errorf("%s: got %s, expected %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestRunnerIsIdempotent(t *testing.T) {
+	svc, fake := newTestService(t)
+	store := NewMemoryOccurrenceStore()
+	runner := NewRunner(svc, store)
+
+	template := sampleVoucher()
+	template.Code = "JV-RENT"
+	rec := Recurrence{
+		ID:       "rent",
+		Template: template,
+		Schedule: Schedule{Kind: ScheduleMonthlyOnDay, Day: 1},
+		Start:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		AutoPost: true,
+	}
+
+	// Simulate a crash after the February voucher was created but before it
+	// was recorded: the store only knows it as pending.
+	feb := template
+	feb.Code = "JV-RENT-20240201"
+	feb.Date = calendar.MustParseDate("2024-02-01", calendar.AD)
+	if _, err := svc.CreateJournalVoucher(feb); err != nil {
+		t.Fatalf("CreateJournalVoucher failed: %v", err)
+	}
+	_ = store.PutOccurrence(Occurrence{RecurrenceID: "rent", Date: "2024-02-01", Code: feb.Code, Status: OccurrencePending})
+
+	upTo := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	changed, err := runner.Run(rec, upTo)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(changed) != 3 {
+		t.Fatalf("expected 3 occurrences to change, got %d", len(changed))
+	}
+	if len(fake.order) != 3 {
+		t.Fatalf("expected 3 vouchers in total, got %d", len(fake.order))
+	}
+	for _, id := range fake.order {
+		if fake.vouchers[id].VoucherStatus != StatusPosted {
+			t.Fatalf("expected voucher %s to be posted", id)
+		}
+	}
+
+	changed, err = runner.Run(rec, upTo)
+	if err != nil {
+		t.Fatalf("second Run failed: %v", err)
+	}
+	if len(changed) != 0 || len(fake.order) != 3 {
+		t.Fatalf("second run was not idempotent: %d changed, %d vouchers", len(changed), len(fake.order))
+	}
+}
+
+func TestFileOccurrenceStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "occurrences.json")
+	store := NewFileOccurrenceStore(path)
+
+	occ := Occurrence{RecurrenceID: "rent", Date: "2024-01-01", Code: "JV-RENT-20240101", VoucherID: "jv-1", Status: OccurrenceCreated}
+	if err := store.PutOccurrence(occ); err != nil {
+		t.Fatalf("PutOccurrence failed: %v", err)
+	}
+
+	got, err := NewFileOccurrenceStore(path).GetOccurrence("rent", "2024-01-01")
+	if err != nil {
+		t.Fatalf("GetOccurrence failed: %v", err)
+	}
+	if got == nil || *got != occ {
+		t.Fatalf("expected %+v, got %+v", occ, got)
+	}
+}
+
+func createPosted(t *testing.T, svc *Service, jv JournalVoucher) *JournalVoucher {
+	t.Helper()
+	created, err := svc.CreateJournalVoucher(jv)
+	if err != nil {
+		t.Fatalf("CreateJournalVoucher failed: %v", err)
+	}
+	posted, err := svc.PostVoucher(*created)
+	if err != nil {
+		t.Fatalf("PostVoucher failed: %v", err)
+	}
+	return posted
+}
+
+func TestReverseVoucher(t *testing.T) {
+	svc, fake := newTestService(t)
+	orig := createPosted(t, svc, sampleVoucher())
+
+	rev, err := svc.ReverseVoucher(orig.ID, time.Date(2024, 8, 16, 0, 0, 0, 0, time.UTC), true)
+	if err != nil {
+		t.Fatalf("ReverseVoucher failed: %v", err)
+	}
+	if rev.Code != "REV-JV-0001" || rev.Date.String() != "2024-08-16" || rev.VoucherStatus != StatusPosted {
+		t.Fatalf("unexpected reversal: %+v", rev)
+	}
+	for i, item := range rev.Items {
+		if item.TxnType == orig.Items[i].TxnType || !item.Amount.Equal(orig.Items[i].Amount) {
+			t.Fatalf("item %d is not a mirror image: %+v vs %+v", i, item, orig.Items[i])
+		}
+	}
+
+	again, err := svc.ReverseVoucher(orig.ID, time.Date(2024, 8, 17, 0, 0, 0, 0, time.UTC), true)
+	if err != nil || again.ID != rev.ID || len(fake.order) != 2 {
+		t.Fatalf("expected the existing reversal back, got %+v, %v with %d vouchers", again, err, len(fake.order))
+	}
+
+	if _, err := svc.ReverseVoucher(rev.ID+"-missing", time.Now(), false); err == nil {
+		t.Fatal("expected error reversing a missing voucher")
+	}
+}
+
+func TestReverseAccruals(t *testing.T) {
+	svc, fake := newTestService(t)
+
+	accrual := sampleVoucher()
+	accrual.Code = "JV-ACC-1"
+	accrual.Date = calendar.MustParseDate("2024-07-31", calendar.AD)
+	accrual.Narration = "Salary payable #accrual"
+	createPosted(t, svc, accrual)
+
+	other := sampleVoucher()
+	other.Code = "JV-0002"
+	other.Date = calendar.MustParseDate("2024-07-31", calendar.AD)
+	createPosted(t, svc, other)
+
+	outside := accrual
+	outside.Code = "JV-ACC-0"
+	outside.Date = calendar.MustParseDate("2024-06-30", calendar.AD)
+	createPosted(t, svc, outside)
+
+	locker := period.NewLocker(period.CalendarYear, period.Monthly, period.NewMemoryStore())
+	july, err := locker.PeriodOf(accrual.Date)
+	if err != nil {
+		t.Fatalf("PeriodOf failed: %v", err)
+	}
+	if _, err := svc.ReverseAccruals(july, false); err == nil {
+		t.Fatal("expected reversing without a period lock to fail")
+	}
+	locked := svc.WithPeriodLock(locker)
+	if _, err := locked.ReverseAccruals(july, false); err == nil || !strings.Contains(err.Error(), "is open") {
+		t.Fatalf("expected an open period to be refused, got %v", err)
+	}
+	if len(fake.order) != 3 {
+		t.Fatalf("expected no reversals in an open period, got %v", fake.order)
+	}
+
+	if _, err := locker.SoftClose(july.Start); err != nil {
+		t.Fatalf("SoftClose failed: %v", err)
+	}
+	created, err := locked.ReverseAccruals(july, false)
+	if err != nil {
+		t.Fatalf("ReverseAccruals failed: %v", err)
+	}
+	if len(created) != 1 || created[0].Code != "REV-JV-ACC-1" || created[0].Date.String() != "2024-08-01" {
+		t.Fatalf("unexpected reversals: %+v", created)
+	}
+
+	created, err = locked.ReverseAccruals(july, false)
+	if err != nil {
+		t.Fatalf("second ReverseAccruals failed: %v", err)
+	}
+	if len(created) != 0 || len(fake.order) != 4 {
+		t.Fatalf("expected second run to be a no-op, got %d new and %d total", len(created), len(fake.order))
+	}
+}
+
+func TestMultiCurrencyVoucher(t *testing.T) {
+	accounts := newStubAccounts()
+	accounts.accounts = append(accounts.accounts, account.Account{ID: "acc-fx", Code: "FX0001", Name: "FX Rounding"})
+
+	cents := money.MustParse("0.01", "")
+	jv, err := NewBuilder(accounts, "JV-USD-1", calendar.MustParseDate("2024-07-16", calendar.AD), "USD").
+		Debit("EX0001", money.MustParse("0.03", ""), "").
+		Credit("BA0001", cents, "").
+		Credit("BA0001", cents, "").
+		Credit("BA0001", cents, "").
+		ExchangeRate("NPR", money.MustParseRate("133.245"), "FX0001").
+		Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if jv.BaseCurrencyCode != "NPR" || jv.ExchangeRate.String() != "133.245" {
+		t.Fatalf("unexpected rate fields: %s %v", jv.BaseCurrencyCode, jv.ExchangeRate)
+	}
+	if got := jv.Items[0].BaseAmount.String(); got != "4.00" {
+		t.Fatalf("expected base amount 4.00, got %s", got)
+	}
+	fx := jv.Items[len(jv.Items)-1]
+	if fx.AccountID != "acc-fx" || fx.TxnType != TxnTypeCredit || fx.BaseAmount.String() != "0.01" || !fx.Amount.IsZero() {
+		t.Fatalf("unexpected FX rounding line: %+v", fx)
+	}
+
+	// Tampering with a base amount must break the base-currency balance.
+	tampered := *jv
+	tampered.Items = append([]JournalVoucherItem(nil), jv.Items...)
+	bumped := money.MustParse("4.01", "NPR")
+	tampered.Items[0].BaseAmount = &bumped
+	err = tampered.Validate()
+	if err == nil || !strings.Contains(err.Error(), "total base debits") {
+		t.Fatalf("expected base currency imbalance, got %v", err)
+	}
+}
+
+func TestPeriodLock(t *testing.T) {
+	svc, fake := newTestService(t)
+	draft, err := svc.CreateJournalVoucher(sampleVoucher())
+	if err != nil {
+		t.Fatalf("CreateJournalVoucher failed: %v", err)
+	}
+
+	locker := period.NewLocker(period.NepaliFiscalYear, period.Monthly, period.NewMemoryStore())
+	if _, err := locker.SoftClose(draft.Date); err != nil {
+		t.Fatalf("SoftClose failed: %v", err)
+	}
+	locked := svc.WithPeriodLock(locker)
+
+	calls := fake.calls
+	var closed *period.ClosedError
+	if _, err := locked.CreateJournalVoucher(sampleVoucher()); !errors.As(err, &closed) {
+		t.Fatalf("expected create in a closed period to fail, got %v", err)
+	}
+	if _, err := locked.PostVoucher(*draft); !errors.As(err, &closed) {
+		t.Fatalf("expected post in a closed period to fail, got %v", err)
+	}
+	if fake.calls != calls {
+		t.Fatalf("expected no HTTP calls for closed periods, got %d", fake.calls-calls)
+	}
+
+	// Updates may neither move a voucher into a closed period nor out of one.
+	open := sampleVoucher()
+	open.Date = draft.Date.AddDays(45)
+	openDraft, err := locked.CreateJournalVoucher(open)
+	if err != nil {
+		t.Fatalf("CreateJournalVoucher in an open period failed: %v", err)
+	}
+	moved := *openDraft
+	moved.Date = draft.Date
+	if _, err := locked.UpdateJournalVoucher(openDraft.ID, moved); !errors.As(err, &closed) {
+		t.Fatalf("expected update into a closed period to fail, got %v", err)
+	}
+	moved = *draft
+	moved.Date = open.Date
+	if _, err := locked.UpdateJournalVoucher(draft.ID, moved); !errors.As(err, &closed) {
+		t.Fatalf("expected update out of a closed period to fail, got %v", err)
+	}
+
+	posted, err := locked.WithPeriodOverride().PostVoucher(*draft)
+	if err != nil {
+		t.Fatalf("expected override to post in a soft-closed period, got %v", err)
+	}
+
+	if _, err := locker.HardClose(draft.Date); err != nil {
+		t.Fatalf("HardClose failed: %v", err)
+	}
+	if _, err := locked.WithPeriodOverride().VoidVoucher(*posted); !errors.As(err, &closed) || closed.Status != period.HardClosed {
+		t.Fatalf("expected void in a hard-closed period to fail, got %v", err)
+	}
+}
+
+type rejectDimensions struct{}
+
+func (rejectDimensions) CheckDimensions(jv JournalVoucher) error {
+	for i, item := range jv.Items {
+		if item.Dimensions["cost_center"] != "KTM" {
+			return ValidationErrors{{Field: fmt.Sprintf("items[%d].dimensions.cost_center", i), Message: "is required"}}
+		}
+	}
+	return nil
+}
+
+func TestBuilderTagsAndDimensionCheck(t *testing.T) {
+	jv, err := NewBuilder(newStubAccounts(), "JV-0005", calendar.MustParseDate("2024-07-16", calendar.AD), "NPR").
+		Debit("EX0001", money.MustParse("100", "NPR"), "").Tag("cost_center", "KTM").
+		Credit("BA0001", money.MustParse("100", "NPR"), "").
+		Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if jv.Items[0].Dimensions["cost_center"] != "KTM" || jv.Items[1].Dimensions != nil {
+		t.Fatalf("unexpected dimensions %v / %v", jv.Items[0].Dimensions, jv.Items[1].Dimensions)
+	}
+
+	svc, fake := newTestService(t)
+	if _, err := svc.WithDimensionCheck(rejectDimensions{}).CreateJournalVoucher(*jv); err == nil || !strings.Contains(err.Error(), "items[1].dimensions") {
+		t.Fatalf("expected dimension error, got %v", err)
+	}
+	if fake.calls != 0 {
+		t.Fatalf("expected no HTTP calls, got %d", fake.calls)
+	}
+}
+
+func TestIdempotentCreate(t *testing.T) {
+	svc, fake := newTestService(t)
+	store := idempotency.NewMemoryStore()
+
+	// The first attempt reaches Tigg but its response is lost.
+	fake.lostResponses = 1
+	keyed := svc.WithIdempotencyKey(store, "import-2024-07-16-1")
+	if _, err := keyed.CreateJournalVoucher(sampleVoucher()); err == nil {
+		t.Fatal("expected the lost response to surface as an error")
+	}
+
+	// The retry finds the voucher by code instead of creating a second one.
+	jv, err := keyed.CreateJournalVoucher(sampleVoucher())
+	if err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	if len(fake.order) != 1 || jv.ID != fake.order[0] {
+		t.Fatalf("expected exactly one voucher, got %v (returned %s)", fake.order, jv.ID)
+	}
+
+	// Later retries replay the recorded result without any request.
+	calls := fake.calls
+	again, err := keyed.CreateJournalVoucher(sampleVoucher())
+	if err != nil || again.ID != jv.ID || fake.calls != calls {
+		t.Fatalf("expected a replay without HTTP calls, got %v %+v after %d calls", err, again, fake.calls-calls)
+	}
+
+	changed := sampleVoucher()
+	changed.Narration = "something else"
+	if _, err := keyed.CreateJournalVoucher(changed); !errors.Is(err, idempotency.ErrKeyReused) {
+		t.Fatalf("expected ErrKeyReused, got %v", err)
+	}
+
+	if fake.keys[0] != "import-2024-07-16-1/JV-0001" {
+		t.Fatalf("expected the key and code to be sent as a header, got %q", fake.keys[0])
+	}
+}
+
+func TestIdempotentBatch(t *testing.T) {
+	svc, fake := newTestService(t)
+	keyed := svc.WithIdempotencyKey(idempotency.NewMemoryStore(), "batch-1")
+
+	// One keyed service creates and posts several different vouchers.
+	second := sampleVoucher()
+	second.Code, second.Narration = "JV-0002", "Office rent, August"
+	for _, jv := range []JournalVoucher{sampleVoucher(), second} {
+		created, err := keyed.CreateJournalVoucher(jv)
+		if err != nil {
+			t.Fatalf("create %s failed: %v", jv.Code, err)
+		}
+		if _, err := keyed.PostVoucher(*created); err != nil {
+			t.Fatalf("post %s failed: %v", jv.Code, err)
+		}
+	}
+	if len(fake.order) != 2 {
+		t.Fatalf("expected two vouchers, got %v", fake.order)
+	}
+}
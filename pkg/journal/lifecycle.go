@@ -0,0 +1,48 @@
+package journal
+
+import "fmt"
+
+// transitions lists the legal status changes. DRAFT -> DRAFT is an edit.
+var transitions = map[VoucherStatus][]VoucherStatus{
+	StatusDraft:  {StatusDraft, StatusPosted},
+	StatusPosted: {StatusVoided},
+	StatusVoided: {},
+}
+
+// TransitionError is returned when a voucher is moved to a status that is not
+// reachable from its current one. It is raised before any request is sent.
+type TransitionError struct {
+	VoucherID string
+	From      VoucherStatus
+	To        VoucherStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("journal voucher %s: illegal status transition %s -> %s", e.VoucherID, e.From, e.To)
+}
+
+// CanTransition reports whether a voucher in status from may move to status to.
+// An empty status is treated as DRAFT, as that is what new vouchers start as.
+func CanTransition(from, to VoucherStatus) bool {
+	if from == "" {
+		from = StatusDraft
+	}
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTransition returns a *TransitionError if jv cannot move to status to.
+func checkTransition(jv JournalVoucher, to VoucherStatus) error {
+	if CanTransition(jv.VoucherStatus, to) {
+		return nil
+	}
+	from := jv.VoucherStatus
+	if from == "" {
+		from = StatusDraft
+	}
+	return &TransitionError{VoucherID: jv.ID, From: from, To: to}
+}
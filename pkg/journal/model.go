@@ -1,29 +1,44 @@
 package journal
 
+import (
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// VoucherStatus is the lifecycle state of a journal voucher.
 type VoucherStatus string
 
 const (
-	statusDraft  VoucherStatus = "DRAFT"
-	statusPosted VoucherStatus = "POSTED"
-	statusVoided VoucherStatus = "VOIDED"
+	StatusDraft  VoucherStatus = "DRAFT"
+	StatusPosted VoucherStatus = "POSTED"
+	StatusVoided VoucherStatus = "VOIDED"
 )
 
+// JournalVoucher is a double-entry voucher. Foreign-currency vouchers also
+// carry BaseCurrencyCode and ExchangeRate (base units per unit of CurrencyCode),
+// and every item then carries its BaseAmount.
 type JournalVoucher struct {
-	ID            string               `json:"id,omitempty"`
-	Code          string               `json:"code"`
-	Date          string               `json:"date"`
-	CurrencyCode  string               `json:"currency_code"`
-	VoucherStatus VoucherStatus        `json:"status,omitempty"`
-	Narration     string               `json:"narration,omitempty"`
-	Items         []JournalVoucherItem `json:"items"`
-	CreatedAt     string               `json:"created_at,omitempty"`
-	UpdatedAt     string               `json:"updated_at,omitempty"`
+	ID               string               `json:"id,omitempty"`
+	Code             string               `json:"code"`
+	Date             calendar.Date        `json:"date"`
+	CurrencyCode     string               `json:"currency_code"`
+	BaseCurrencyCode string               `json:"base_currency_code,omitempty"`
+	ExchangeRate     *money.Rate          `json:"exchange_rate,omitempty"`
+	VoucherStatus    VoucherStatus        `json:"status,omitempty"`
+	Narration        string               `json:"narration,omitempty"`
+	Items            []JournalVoucherItem `json:"items"`
+	CreatedAt        string               `json:"created_at,omitempty"`
+	UpdatedAt        string               `json:"updated_at,omitempty"`
 }
 
+// JournalVoucherItem is one line of a voucher. Dimensions tags the line with
+// analytic values keyed by dimension name, e.g. {"cost_center": "KTM"}.
 type JournalVoucherItem struct {
-	AccountID   string `json:"account_id,omitempty"`
-	AccountCode string `json:"account_code,omitempty"`
-	Amount      string `json:"amount"`
-	TxnType     string `json:"txn_type"`
-	Narration   string `json:"narration,omitempty"`
+	AccountID   string            `json:"account_id,omitempty"`
+	AccountCode string            `json:"account_code,omitempty"`
+	Amount      money.Money       `json:"amount"`
+	BaseAmount  *money.Money      `json:"base_amount,omitempty"`
+	TxnType     string            `json:"txn_type"`
+	Narration   string            `json:"narration,omitempty"`
+	Dimensions  map[string]string `json:"dimensions,omitempty"`
 }
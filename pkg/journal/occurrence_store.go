@@ -0,0 +1,109 @@
+package journal
+
+import (
+	"sync"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/internal/jsonfile"
+)
+
+// OccurrenceStatus records how far a recurrence occurrence got.
+type OccurrenceStatus string
+
+const (
+	// OccurrencePending is written before the voucher is created, so a crash
+	// between creating and recording it can be detected and reconciled.
+	OccurrencePending OccurrenceStatus = "PENDING"
+	OccurrenceCreated OccurrenceStatus = "CREATED"
+	OccurrencePosted  OccurrenceStatus = "POSTED"
+)
+
+// Occurrence is the stored state of one scheduled voucher.
+type Occurrence struct {
+	RecurrenceID string           `json:"recurrence_id"`
+	Date         string           `json:"date"`
+	Code         string           `json:"code"`
+	VoucherID    string           `json:"voucher_id,omitempty"`
+	Status       OccurrenceStatus `json:"status"`
+}
+
+// OccurrenceStore remembers which occurrences were already generated.
+type OccurrenceStore interface {
+	GetOccurrence(recurrenceID, date string) (*Occurrence, error)
+	PutOccurrence(o Occurrence) error
+}
+
+func occurrenceKey(recurrenceID, date string) string {
+	return recurrenceID + "/" + date
+}
+
+// MemoryOccurrenceStore keeps occurrences in memory. It is useful for tests and
+// one-off runs; use FileOccurrenceStore to survive restarts.
+type MemoryOccurrenceStore struct {
+	mu   sync.Mutex
+	data map[string]Occurrence
+}
+
+func NewMemoryOccurrenceStore() *MemoryOccurrenceStore {
+	return &MemoryOccurrenceStore{data: map[string]Occurrence{}}
+}
+
+func (s *MemoryOccurrenceStore) GetOccurrence(recurrenceID, date string) (*Occurrence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.data[occurrenceKey(recurrenceID, date)]
+	if !ok {
+		return nil, nil
+	}
+	return &o, nil
+}
+
+func (s *MemoryOccurrenceStore) PutOccurrence(o Occurrence) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[occurrenceKey(o.RecurrenceID, o.Date)] = o
+	return nil
+}
+
+// FileOccurrenceStore persists occurrences in a JSON file keyed by recurrence
+// and date, so a restarted scheduler sees what it already created.
+type FileOccurrenceStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileOccurrenceStore(path string) *FileOccurrenceStore {
+	return &FileOccurrenceStore{path: path}
+}
+
+func (s *FileOccurrenceStore) load() (map[string]Occurrence, error) {
+	data := map[string]Occurrence{}
+	if err := jsonfile.Read(s.path, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *FileOccurrenceStore) GetOccurrence(recurrenceID, date string) (*Occurrence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	o, ok := data[occurrenceKey(recurrenceID, date)]
+	if !ok {
+		return nil, nil
+	}
+	return &o, nil
+}
+
+func (s *FileOccurrenceStore) PutOccurrence(o Occurrence) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := s.load()
+	if err != nil {
+		return err
+	}
+	data[occurrenceKey(o.RecurrenceID, o.Date)] = o
+	return jsonfile.Write(s.path, data)
+}
@@ -0,0 +1,164 @@
+package journal
+
+import (
+	"fmt"
+	"time"
+)
+
+// ScheduleKind selects how a Recurrence picks its occurrence dates.
+type ScheduleKind string
+
+const (
+	// ScheduleMonthlyOnDay occurs on Schedule.Day of every month. Days past the
+	// end of a short month fall on its last day.
+	ScheduleMonthlyOnDay ScheduleKind = "MONTHLY_ON_DAY"
+	// ScheduleLastBusinessDay occurs on the last non-weekend day of every month.
+	ScheduleLastBusinessDay ScheduleKind = "LAST_BUSINESS_DAY"
+	// ScheduleQuarterly occurs on Schedule.Day every third month, counting from
+	// the month of Recurrence.Start.
+	ScheduleQuarterly ScheduleKind = "QUARTERLY"
+	// ScheduleInterval occurs every Schedule.Every units from Recurrence.Start.
+	ScheduleInterval ScheduleKind = "INTERVAL"
+)
+
+// IntervalUnit is the step used by ScheduleInterval.
+type IntervalUnit string
+
+const (
+	UnitDay   IntervalUnit = "DAY"
+	UnitWeek  IntervalUnit = "WEEK"
+	UnitMonth IntervalUnit = "MONTH"
+)
+
+// Schedule is the rule attached to a Recurrence.
+type Schedule struct {
+	Kind  ScheduleKind `json:"kind"`
+	Day   int          `json:"day,omitempty"`
+	Every int          `json:"every,omitempty"`
+	Unit  IntervalUnit `json:"unit,omitempty"`
+
+	// Weekend lists non-business days for ScheduleLastBusinessDay.
+	// Defaults to Saturday, the weekly holiday in Nepal.
+	Weekend []time.Weekday `json:"weekend,omitempty"`
+}
+
+// Recurrence attaches a Schedule to a voucher template. Each occurrence is
+// created from Template with its Date set to the occurrence date and its Code
+// suffixed with the date, so occurrences can be found again by code.
+type Recurrence struct {
+	ID       string         `json:"id"`
+	Template JournalVoucher `json:"template"`
+	Schedule Schedule       `json:"schedule"`
+	Start    time.Time      `json:"start"`
+	End      *time.Time     `json:"end,omitempty"`
+	AutoPost bool           `json:"auto_post"`
+}
+
+// Occurrences returns the scheduled dates between Start and upTo inclusive.
+func (r Recurrence) Occurrences(upTo time.Time) ([]time.Time, error) {
+	if err := r.Schedule.validate(); err != nil {
+		return nil, fmt.Errorf("recurrence %s: %w", r.ID, err)
+	}
+
+	start := truncateDay(r.Start)
+	last := truncateDay(upTo)
+	if r.End != nil && truncateDay(*r.End).Before(last) {
+		last = truncateDay(*r.End)
+	}
+
+	var dates []time.Time
+	for i := 0; ; i++ {
+		d := r.Schedule.nth(start, i)
+		if d.After(last) {
+			break
+		}
+		if !d.Before(start) {
+			dates = append(dates, d)
+		}
+	}
+	return dates, nil
+}
+
+// OccurrenceCode is the voucher code used for the occurrence on date.
+func (r Recurrence) OccurrenceCode(date time.Time) string {
+	return fmt.Sprintf("%s-%s", r.Template.Code, date.Format("20060102"))
+}
+
+func (s Schedule) validate() error {
+	switch s.Kind {
+	case ScheduleMonthlyOnDay, ScheduleQuarterly:
+		if s.Day < 1 || s.Day > 31 {
+			return fmt.Errorf("schedule day must be between 1 and 31, got %d", s.Day)
+		}
+	case ScheduleLastBusinessDay:
+		if len(s.Weekend) >= 7 {
+			return fmt.Errorf("schedule weekend covers every day")
+		}
+	case ScheduleInterval:
+		if s.Every < 1 {
+			return fmt.Errorf("schedule interval must be positive, got %d", s.Every)
+		}
+		switch s.Unit {
+		case UnitDay, UnitWeek, UnitMonth:
+		default:
+			return fmt.Errorf("unknown interval unit %q", s.Unit)
+		}
+	default:
+		return fmt.Errorf("unknown schedule kind %q", s.Kind)
+	}
+	return nil
+}
+
+// nth returns the i-th candidate date counted from start. Candidates are
+// increasing in i; the first may fall before start and is then skipped.
+func (s Schedule) nth(start time.Time, i int) time.Time {
+	switch s.Kind {
+	case ScheduleMonthlyOnDay:
+		return dayOfMonth(start.Year(), start.Month()+time.Month(i), s.Day)
+	case ScheduleQuarterly:
+		return dayOfMonth(start.Year(), start.Month()+time.Month(3*i), s.Day)
+	case ScheduleLastBusinessDay:
+		d := dayOfMonth(start.Year(), start.Month()+time.Month(i), 31)
+		for s.isWeekend(d.Weekday()) {
+			d = d.AddDate(0, 0, -1)
+		}
+		return d
+	default:
+		switch s.Unit {
+		case UnitWeek:
+			return start.AddDate(0, 0, 7*s.Every*i)
+		case UnitMonth:
+			return dayOfMonth(start.Year(), start.Month()+time.Month(s.Every*i), start.Day())
+		default:
+			return start.AddDate(0, 0, s.Every*i)
+		}
+	}
+}
+
+func (s Schedule) isWeekend(d time.Weekday) bool {
+	weekend := s.Weekend
+	if len(weekend) == 0 {
+		weekend = []time.Weekday{time.Saturday}
+	}
+	for _, w := range weekend {
+		if w == d {
+			return true
+		}
+	}
+	return false
+}
+
+// dayOfMonth returns the given day of a month, clamped to the month's last day.
+// month may be out of range and is normalised like time.Date does.
+func dayOfMonth(year int, month time.Month, day int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
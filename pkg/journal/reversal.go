@@ -0,0 +1,137 @@
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/period"
+)
+
+const (
+	// ReversalPrefix is prepended to the original code to form the reversal's code.
+	ReversalPrefix = "REV-"
+	// AccrualTag marks a voucher as an accrual when it appears in its narration.
+	AccrualTag = "#accrual"
+)
+
+// IsAccrual reports whether the voucher narration carries AccrualTag.
+func IsAccrual(jv JournalVoucher) bool {
+	return strings.Contains(strings.ToLower(jv.Narration), AccrualTag)
+}
+
+// ReversalOf returns the mirror image of a voucher dated reversalDate: every
+// item's TxnType is swapped and the code and narration point back to the original.
+func ReversalOf(jv JournalVoucher, reversalDate time.Time) JournalVoucher {
+	rev := JournalVoucher{
+		Code:             ReversalPrefix + jv.Code,
+		Date:             calendar.NewDate(reversalDate),
+		CurrencyCode:     jv.CurrencyCode,
+		BaseCurrencyCode: jv.BaseCurrencyCode,
+		ExchangeRate:     jv.ExchangeRate,
+		Narration:        fmt.Sprintf("Reversal of %s", jv.Code),
+		Items:            make([]JournalVoucherItem, len(jv.Items)),
+	}
+	for i, item := range jv.Items {
+		switch item.TxnType {
+		case TxnTypeDebit:
+			item.TxnType = TxnTypeCredit
+		case TxnTypeCredit:
+			item.TxnType = TxnTypeDebit
+		}
+		rev.Items[i] = item
+	}
+	return rev
+}
+
+// ReverseVoucher fetches a posted voucher and creates its reversal dated
+// reversalDate. If post is true the reversal is posted as well. A voucher that
+// already has a reversal is not reversed again: the existing reversal is
+// returned, and posted if post is true and it is still a draft.
+func (s *Service) ReverseVoucher(id string, reversalDate time.Time, post bool) (*JournalVoucher, error) {
+	jv, err := s.GetJournalVoucherByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if jv.VoucherStatus != StatusPosted {
+		return nil, fmt.Errorf("journal voucher %s is %s; only posted vouchers can be reversed", id, jv.VoucherStatus)
+	}
+	list, err := s.ListJournalVouchers()
+	if err != nil {
+		return nil, err
+	}
+	if rev, ok := reversals(list)[jv.Code]; ok {
+		if post && rev.VoucherStatus == StatusDraft {
+			return s.PostVoucher(rev)
+		}
+		return &rev, nil
+	}
+	return s.createReversal(*jv, reversalDate, post)
+}
+
+// reversals maps the codes of reversed vouchers to their reversals, leaving
+// out voided ones.
+func reversals(list []JournalVoucher) map[string]JournalVoucher {
+	out := make(map[string]JournalVoucher)
+	for _, jv := range list {
+		if strings.HasPrefix(jv.Code, ReversalPrefix) && jv.VoucherStatus != StatusVoided {
+			out[strings.TrimPrefix(jv.Code, ReversalPrefix)] = jv
+		}
+	}
+	return out
+}
+
+func (s *Service) createReversal(jv JournalVoucher, reversalDate time.Time, post bool) (*JournalVoucher, error) {
+	rev, err := s.CreateJournalVoucher(ReversalOf(jv, reversalDate))
+	if err != nil {
+		return nil, err
+	}
+	if !post {
+		return rev, nil
+	}
+	return s.PostVoucher(*rev)
+}
+
+// ReverseAccruals reverses every posted accrual (see IsAccrual) dated within
+// the closed period p on the first day after it. The service needs a period
+// lock (see WithPeriodLock) that reports p as closed; an open period is
+// refused, as its accruals may still change. Vouchers that already have a
+// reversal are skipped, so the call can safely be repeated.
+func (s *Service) ReverseAccruals(p period.Period, post bool) ([]JournalVoucher, error) {
+	if s.periods == nil {
+		return nil, fmt.Errorf("reversing accruals needs a period lock to check that %s is closed", p.Key())
+	}
+	for _, d := range []calendar.Date{p.Start, p.End} {
+		err := s.periods.CheckDate(d, false)
+		var closed *period.ClosedError
+		if err == nil {
+			return nil, fmt.Errorf("period %s is open; accruals are reversed once it is closed", p.Key())
+		}
+		if !errors.As(err, &closed) {
+			return nil, err
+		}
+	}
+
+	list, err := s.ListJournalVouchers()
+	if err != nil {
+		return nil, err
+	}
+	reversed := reversals(list)
+	reversalDate := p.End.AddDays(1).Time()
+
+	var created []JournalVoucher
+	for _, jv := range list {
+		if _, ok := reversed[jv.Code]; ok || jv.VoucherStatus != StatusPosted || !IsAccrual(jv) || !p.Contains(jv.Date) {
+			continue
+		}
+
+		rev, err := s.createReversal(jv, reversalDate, post)
+		if err != nil {
+			return created, fmt.Errorf("reversing journal voucher %s: %w", jv.Code, err)
+		}
+		created = append(created, *rev)
+	}
+	return created, nil
+}
@@ -0,0 +1,117 @@
+package journal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+)
+
+// Runner generates the due vouchers of recurrences and records each
+// occurrence in an OccurrenceStore, so running it again never duplicates work.
+type Runner struct {
+	service *Service
+	store   OccurrenceStore
+}
+
+func NewRunner(svc *Service, store OccurrenceStore) *Runner {
+	return &Runner{service: svc, store: store}
+}
+
+// Run creates, and posts if rec.AutoPost is set, every occurrence of rec due
+// on or before upTo. It returns the occurrences that changed in this run.
+//
+// Before a voucher is created its occurrence is stored as PENDING. If a later
+// run finds a PENDING occurrence it first looks for a voucher with the
+// occurrence code, so a crash between creating and recording never causes a
+// second voucher to be created.
+func (r *Runner) Run(rec Recurrence, upTo time.Time) ([]Occurrence, error) {
+	dates, err := rec.Occurrences(upTo)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing map[string]JournalVoucher
+	lookup := func(code string) (*JournalVoucher, error) {
+		if existing == nil {
+			list, err := r.service.ListJournalVouchers()
+			if err != nil {
+				return nil, err
+			}
+			existing = make(map[string]JournalVoucher, len(list))
+			for _, jv := range list {
+				existing[jv.Code] = jv
+			}
+		}
+		if jv, ok := existing[code]; ok {
+			return &jv, nil
+		}
+		return nil, nil
+	}
+
+	var changed []Occurrence
+	for _, date := range dates {
+		day := date.Format(DateLayout)
+		occ, err := r.store.GetOccurrence(rec.ID, day)
+		if err != nil {
+			return changed, err
+		}
+		if occ == nil {
+			occ = &Occurrence{RecurrenceID: rec.ID, Date: day, Code: rec.OccurrenceCode(date), Status: OccurrencePending}
+			if err := r.store.PutOccurrence(*occ); err != nil {
+				return changed, err
+			}
+		}
+
+		before := occ.Status
+		if err := r.advance(rec, date, occ, lookup); err != nil {
+			return changed, fmt.Errorf("recurrence %s occurrence %s: %w", rec.ID, day, err)
+		}
+		if occ.Status != before || before == OccurrencePending {
+			changed = append(changed, *occ)
+		}
+	}
+	return changed, nil
+}
+
+func (r *Runner) advance(rec Recurrence, date time.Time, occ *Occurrence, lookup func(string) (*JournalVoucher, error)) error {
+	if occ.Status == OccurrencePending {
+		jv, err := lookup(occ.Code)
+		if err != nil {
+			return err
+		}
+		if jv == nil {
+			template := rec.Template
+			template.Code = occ.Code
+			template.Date = calendar.NewDate(date)
+			if jv, err = r.service.CreateJournalVoucher(template); err != nil {
+				return err
+			}
+		}
+		occ.VoucherID = jv.ID
+		occ.Status = OccurrenceCreated
+		if jv.VoucherStatus == StatusPosted {
+			occ.Status = OccurrencePosted
+		}
+		if err := r.store.PutOccurrence(*occ); err != nil {
+			return err
+		}
+	}
+
+	if occ.Status == OccurrenceCreated && rec.AutoPost {
+		jv, err := r.service.GetJournalVoucherByID(occ.VoucherID)
+		if err != nil {
+			return err
+		}
+		if jv.VoucherStatus != StatusPosted {
+			if _, err := r.service.PostVoucher(*jv); err != nil {
+				return err
+			}
+		}
+		occ.Status = OccurrencePosted
+		if err := r.store.PutOccurrence(*occ); err != nil {
+			return err
+		}
+	}
+	return nil
+}
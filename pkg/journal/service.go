@@ -1,11 +1,342 @@
 package journal
 
-import "github.com/rohankarmacharya/TigIntegration/pkg/client"
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/client"
+	"github.com/rohankarmacharya/TigIntegration/pkg/errors"
+	"github.com/rohankarmacharya/TigIntegration/pkg/idempotency"
+)
 
 type Service struct {
-	client *client.TiggClient
+	client         *client.TiggClient
+	periods        PeriodChecker
+	periodOverride bool
+	dimensions     DimensionChecker
+	idem           idempotency.Key
 }
 
 func NewService(c *client.TiggClient) *Service {
 	return &Service{client: c}
 }
+
+// WithIdempotencyKey returns a copy of the service whose mutating calls are
+// recorded in store under key and the voucher they act on: its code for a
+// create, its ID otherwise. Retrying a call on the copy returns the first
+// result instead of repeating it; see idempotency.Do.
+func (s *Service) WithIdempotencyKey(store idempotency.Store, key string) *Service {
+	return s.withKey(idempotency.Key{Store: store, Value: key})
+}
+
+// withKey returns a copy of the service sending k as its idempotency key.
+func (s *Service) withKey(k idempotency.Key) *Service {
+	out := *s
+	out.idem = k
+	return &out
+}
+
+type journalVoucherListResponse struct {
+	Data []JournalVoucher `json:"data"`
+}
+
+type journalVoucherResponse struct {
+	Data JournalVoucher `json:"data"`
+}
+
+// signPayload merges timestamp and nonce into the payload, signs the base64
+// encoded JSON with the client secret and builds the request.
+func (s *Service) signPayload(method, url string, payload interface{}) (*http.Request, error) {
+	timestampMs := time.Now().UnixMilli()
+	nonce := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	finalPayload := make(map[string]interface{})
+
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, &finalPayload); err != nil {
+			return nil, err
+		}
+	}
+	finalPayload["timestamp"] = timestampMs
+	finalPayload["nonce"] = nonce
+
+	unsignedJSON, err := json.Marshal(finalPayload)
+	if err != nil {
+		return nil, errors.ErrInvalidPayLoad
+	}
+
+	payloadString := base64.StdEncoding.EncodeToString(unsignedJSON)
+
+	mac := hmac.New(sha256.New, []byte(s.client.SecretKey))
+	mac.Write([]byte(payloadString))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	finalPayload["signature"] = signature
+
+	signedJSON, err := json.Marshal(finalPayload)
+	if err != nil {
+		return nil, errors.ErrInvalidPayLoad
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(signedJSON))
+	if err != nil {
+		return nil, err
+	}
+
+	s.client.AddHeaders(req)
+	if s.idem.Enabled() {
+		req.Header.Set(idempotency.Header, s.idem.Value)
+	}
+	req.Header.Set("X-Nonce", nonce)
+	req.Header.Set("X-Timestamp", fmt.Sprintf("%d", timestampMs))
+
+	return req, nil
+}
+
+// ListJournalVouchers sends GET /journal-vouchers request to Tigg
+func (s *Service) ListJournalVouchers() ([]JournalVoucher, error) {
+	url := fmt.Sprintf("%s/journal-vouchers", s.client.BaseURL)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.client.AddHeaders(req)
+
+	resp, err := s.client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, errors.NewTiggError(resp)
+	}
+
+	var res journalVoucherListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+// GetJournalVoucherByID sends GET /journal-vouchers/{id} request to Tigg
+func (s *Service) GetJournalVoucherByID(id string) (*JournalVoucher, error) {
+	url := fmt.Sprintf("%s/journal-vouchers/%s", s.client.BaseURL, id)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.client.AddHeaders(req)
+
+	resp, err := s.client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, errors.NewTiggError(resp)
+	}
+
+	var res journalVoucherResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	return &res.Data, nil
+}
+
+// CreateJournalVoucher sends POST /journal-vouchers request to Tigg.
+// New vouchers are always created as drafts. The voucher is validated locally
+// first and rejected if its date is in a closed period (see WithPeriodLock).
+// With an idempotency key, a retry after an unknown outcome first looks the
+// voucher up by code.
+func (s *Service) CreateJournalVoucher(jv JournalVoucher) (*JournalVoucher, error) {
+	jv.ID = ""
+	jv.VoucherStatus = ""
+	jv.Date = jv.Date.In(calendar.AD)
+	if err := jv.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkDimensions(jv); err != nil {
+		return nil, err
+	}
+	if err := s.checkPeriod(jv); err != nil {
+		return nil, err
+	}
+	k := s.idem.For(jv.Code)
+	return idempotency.Do(k, "journal_voucher.create", jv, func() (*JournalVoucher, error) {
+		return s.withKey(k).createJournalVoucher(jv)
+	}, func() (*JournalVoucher, error) {
+		return s.findJournalVoucherByCode(jv.Code)
+	})
+}
+
+func (s *Service) createJournalVoucher(jv JournalVoucher) (*JournalVoucher, error) {
+	url := fmt.Sprintf("%s/journal-vouchers", s.client.BaseURL)
+
+	req, err := s.signPayload("POST", url, jv)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, errors.NewTiggError(resp)
+	}
+
+	var res journalVoucherResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	return &res.Data, nil
+}
+
+// UpdateJournalVoucher sends POST /journal-vouchers/{id} request to Tigg to update a draft voucher.
+func (s *Service) UpdateJournalVoucher(id string, jv JournalVoucher) (*JournalVoucher, error) {
+	if id == "" {
+		return nil, fmt.Errorf("id is required for update to prevent duplicate creation")
+	}
+	jv.ID = id
+	jv.Date = jv.Date.In(calendar.AD)
+	if err := checkTransition(jv, StatusDraft); err != nil {
+		return nil, err
+	}
+	if err := jv.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkDimensions(jv); err != nil {
+		return nil, err
+	}
+	if err := s.checkPeriod(jv); err != nil {
+		return nil, err
+	}
+	if s.periods != nil {
+		// Moving a voucher out of a closed period changes that period too.
+		stored, err := s.GetJournalVoucherByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkPeriod(*stored); err != nil {
+			return nil, err
+		}
+	}
+	k := s.idem.For(id)
+	return idempotency.Do(k, "journal_voucher.update", jv, func() (*JournalVoucher, error) {
+		return s.withKey(k).updateJournalVoucher(id, jv)
+	}, nil)
+}
+
+func (s *Service) updateJournalVoucher(id string, jv JournalVoucher) (*JournalVoucher, error) {
+	url := fmt.Sprintf("%s/journal-vouchers/%s", s.client.BaseURL, id)
+
+	req, err := s.signPayload("POST", url, jv)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, errors.NewTiggError(resp)
+	}
+
+	return s.GetJournalVoucherByID(id)
+}
+
+// PostVoucher sends PATCH /journal-vouchers/{id}/post request to Tigg.
+// Only DRAFT vouchers can be posted; anything else fails with a *TransitionError
+// before the request is sent.
+func (s *Service) PostVoucher(jv JournalVoucher) (*JournalVoucher, error) {
+	return s.changeStatus(jv, StatusPosted, "post")
+}
+
+// VoidVoucher sends PATCH /journal-vouchers/{id}/void request to Tigg.
+// Only POSTED vouchers can be voided; anything else fails with a *TransitionError
+// before the request is sent.
+func (s *Service) VoidVoucher(jv JournalVoucher) (*JournalVoucher, error) {
+	return s.changeStatus(jv, StatusVoided, "void")
+}
+
+func (s *Service) changeStatus(jv JournalVoucher, to VoucherStatus, action string) (*JournalVoucher, error) {
+	if jv.ID == "" {
+		return nil, fmt.Errorf("id is required to %s a journal voucher", action)
+	}
+	if err := checkTransition(jv, to); err != nil {
+		return nil, err
+	}
+	if err := s.checkPeriod(jv); err != nil {
+		return nil, err
+	}
+	k := s.idem.For(jv.ID)
+	return idempotency.Do(k, "journal_voucher."+action, jv.ID, func() (*JournalVoucher, error) {
+		return s.withKey(k).sendStatus(jv, action)
+	}, func() (*JournalVoucher, error) {
+		current, err := s.GetJournalVoucherByID(jv.ID)
+		if err != nil || current.VoucherStatus != to {
+			return nil, err
+		}
+		return current, nil
+	})
+}
+
+func (s *Service) sendStatus(jv JournalVoucher, action string) (*JournalVoucher, error) {
+	url := fmt.Sprintf("%s/journal-vouchers/%s/%s", s.client.BaseURL, jv.ID, action)
+
+	req, err := s.signPayload("PATCH", url, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, errors.NewTiggError(resp)
+	}
+
+	// API returns success status but not the object, so we fetch it
+	return s.GetJournalVoucherByID(jv.ID)
+}
+
+// findJournalVoucherByCode lists vouchers and returns the one with the given
+// code, or nil if there is none.
+func (s *Service) findJournalVoucherByCode(code string) (*JournalVoucher, error) {
+	list, err := s.ListJournalVouchers()
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if list[i].Code == code {
+			return &list[i], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,86 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/errors"
+)
+
+// EachJournalVoucher sends GET /journal-vouchers request to Tigg and calls fn
+// for every voucher as it is decoded, so the full list is never held in
+// memory. Returning an error from fn stops the iteration with that error.
+func (s *Service) EachJournalVoucher(fn func(JournalVoucher) error) error {
+	url := fmt.Sprintf("%s/journal-vouchers", s.client.BaseURL)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	s.client.AddHeaders(req)
+
+	resp, err := s.client.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return errors.NewTiggError(resp)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if key, _ := tok.(string); key != "data" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+
+		tok, err = dec.Token()
+		if err != nil {
+			return err
+		}
+		if tok == nil {
+			continue
+		}
+		if d, ok := tok.(json.Delim); !ok || d != '[' {
+			return fmt.Errorf("unexpected %v in journal voucher list, expected [", tok)
+		}
+		for dec.More() {
+			var jv JournalVoucher
+			if err := dec.Decode(&jv); err != nil {
+				return err
+			}
+			if err := fn(jv); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != want {
+		return fmt.Errorf("unexpected %v in journal voucher list, expected %v", tok, want)
+	}
+	return nil
+}
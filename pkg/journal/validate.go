@@ -0,0 +1,142 @@
+package journal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// Transaction types accepted on a JournalVoucherItem.
+const (
+	TxnTypeDebit  = "DEBIT"
+	TxnTypeCredit = "CREDIT"
+)
+
+// DateLayout is the AD date format used in occurrence keys and voucher codes.
+const DateLayout = calendar.ADLayout
+
+// FieldError is a single validation problem addressed by a JSON-style path such
+// as "items[2].amount".
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors collects every problem found in a voucher.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return fmt.Sprintf("invalid journal voucher: %s", strings.Join(msgs, "; "))
+}
+
+// Validate checks the voucher locally so that malformed entries fail before a
+// network round trip. It returns nil or a ValidationErrors listing every problem.
+func (jv JournalVoucher) Validate() error {
+	var errs ValidationErrors
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if strings.TrimSpace(jv.Code) == "" {
+		add("code", "is required")
+	}
+	if jv.Date.IsZero() {
+		add("date", "is required")
+	}
+	if strings.TrimSpace(jv.CurrencyCode) == "" {
+		add("currency_code", "is required")
+	}
+	if len(jv.Items) < 2 {
+		add("items", "must contain at least two lines")
+	}
+
+	// checkAmount reports whether m is a positive amount in currency that fits
+	// the currency's precision, recording a problem under field otherwise.
+	checkAmount := func(field string, m money.Money, currency string) bool {
+		precision := money.Precision(currency)
+		switch {
+		case m.Currency() != "" && m.Currency() != currency:
+			add(field, "currency %s does not match %s", m.Currency(), currency)
+		case m.Sign() <= 0:
+			add(field, "must be greater than zero, got %s", m)
+		case !m.Equal(m.Round(precision, money.RoundHalfEven)):
+			add(field, "%s has more than %d decimal places", m, precision)
+		default:
+			return true
+		}
+		return false
+	}
+
+	multiCurrency := jv.ExchangeRate != nil || jv.BaseCurrencyCode != ""
+	if multiCurrency {
+		if strings.TrimSpace(jv.BaseCurrencyCode) == "" {
+			add("base_currency_code", "is required when exchange_rate is set")
+		}
+		if jv.ExchangeRate == nil || jv.ExchangeRate.IsZero() {
+			add("exchange_rate", "is required when base_currency_code is set")
+		}
+	}
+
+	debits, credits := money.Zero(jv.CurrencyCode), money.Zero(jv.CurrencyCode)
+	baseDebits, baseCredits := money.Zero(jv.BaseCurrencyCode), money.Zero(jv.BaseCurrencyCode)
+	for i, item := range jv.Items {
+		prefix := fmt.Sprintf("items[%d]", i)
+
+		if (item.AccountID == "") == (item.AccountCode == "") {
+			add(prefix+".account", "exactly one of account_id or account_code must be set")
+		}
+
+		// An FX rounding line carries only a base amount.
+		fxLine := multiCurrency && item.Amount.IsZero() && item.BaseAmount != nil
+		amountOK := fxLine || checkAmount(prefix+".amount", item.Amount, jv.CurrencyCode)
+
+		baseOK := false
+		if multiCurrency {
+			if item.BaseAmount == nil {
+				add(prefix+".base_amount", "is required on foreign-currency vouchers")
+			} else {
+				baseOK = checkAmount(prefix+".base_amount", *item.BaseAmount, jv.BaseCurrencyCode)
+			}
+		}
+
+		var total, baseTotal *money.Money
+		switch item.TxnType {
+		case TxnTypeDebit:
+			total, baseTotal = &debits, &baseDebits
+		case TxnTypeCredit:
+			total, baseTotal = &credits, &baseCredits
+		default:
+			add(prefix+".txn_type", "must be %s or %s, got %q", TxnTypeDebit, TxnTypeCredit, item.TxnType)
+			continue
+		}
+		if amountOK {
+			*total, _ = total.Add(item.Amount.WithCurrency(jv.CurrencyCode))
+		}
+		if baseOK {
+			*baseTotal, _ = baseTotal.Add(item.BaseAmount.WithCurrency(jv.BaseCurrencyCode))
+		}
+	}
+
+	if !debits.Equal(credits) {
+		add("items", "total debits %s do not equal total credits %s", debits, credits)
+	}
+	if multiCurrency && !baseDebits.Equal(baseCredits) {
+		add("items", "total base debits %s %s do not equal total base credits %s %s",
+			jv.BaseCurrencyCode, baseDebits, jv.BaseCurrencyCode, baseCredits)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
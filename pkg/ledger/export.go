@@ -0,0 +1,240 @@
+package ledger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/accountgroup"
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// AccountLister lists accounts. *account.Service satisfies it.
+type AccountLister interface {
+	ListAccounts() ([]account.Account, error)
+}
+
+// GroupLister lists account groups. *accountgroup.Service satisfies it.
+type GroupLister interface {
+	ListAccountGroups() ([]accountgroup.AccountGroup, error)
+}
+
+// VoucherStreamer calls fn for every journal voucher. *journal.Service
+// satisfies it.
+type VoucherStreamer interface {
+	EachJournalVoucher(fn func(journal.JournalVoucher) error) error
+}
+
+// defaultOpenDate is used for beancount open directives when no OpenDate is set.
+var defaultOpenDate = calendar.MustParseDate("1970-01-01", calendar.AD)
+
+// Exporter writes a Tigg namespace as a ledger-cli or beancount journal.
+// Accounts and groups are loaded up front; vouchers are written one at a time
+// as they are streamed, so a year of vouchers is never held in memory.
+//
+// Posted vouchers are written as cleared ("*") and drafts as pending ("!");
+// voided vouchers are skipped. Foreign-currency vouchers are posted at their
+// base amounts, which always balance, with the transaction amount kept as
+// metadata.
+type Exporter struct {
+	Format   Format
+	Accounts AccountLister
+	Groups   GroupLister
+	Vouchers VoucherStreamer
+
+	// OpenDate dates the beancount open directives. Defaults to 1970-01-01 so
+	// that backdated vouchers never precede their account's opening.
+	OpenDate calendar.Date
+}
+
+// Export writes the account declarations followed by every voucher to w.
+func (e *Exporter) Export(w io.Writer) error {
+	if e.Format != LedgerCLI && e.Format != Beancount {
+		return fmt.Errorf("unknown export format %q", e.Format)
+	}
+
+	accounts, err := e.Accounts.ListAccounts()
+	if err != nil {
+		return fmt.Errorf("list accounts: %w", err)
+	}
+	groups, err := e.Groups.ListAccountGroups()
+	if err != nil {
+		return fmt.Errorf("list account groups: %w", err)
+	}
+	names, err := NewNames(accounts, groups, e.Format)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := e.writeAccounts(bw, accounts, names); err != nil {
+		return err
+	}
+	err = e.Vouchers.EachJournalVoucher(func(jv journal.JournalVoucher) error {
+		if jv.VoucherStatus == journal.StatusVoided {
+			return nil
+		}
+		if err := e.writeVoucher(bw, jv, names); err != nil {
+			return fmt.Errorf("voucher %s: %w", jv.Code, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (e *Exporter) writeAccounts(w *bufio.Writer, accounts []account.Account, names *Names) error {
+	sorted := append([]account.Account(nil), accounts...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Code < sorted[j].Code })
+
+	openDate := e.OpenDate
+	if openDate.IsZero() {
+		openDate = defaultOpenDate
+	}
+
+	for _, acc := range sorted {
+		name, _ := names.Lookup(acc.ID, acc.Code)
+		meta := [][2]string{{"code", acc.Code}, {"tigg-id", acc.ID}, {"description", acc.Description}}
+		if acc.Inactive {
+			meta = append(meta, [2]string{"inactive", "true"})
+		}
+
+		if e.Format == Beancount {
+			fmt.Fprintf(w, "%s open %s\n", openDate.Format(calendar.AD), name)
+		} else {
+			fmt.Fprintf(w, "account %s\n", name)
+		}
+		e.writeMeta(w, "  ", meta)
+	}
+	_, err := w.WriteString("\n")
+	return err
+}
+
+func (e *Exporter) writeVoucher(w *bufio.Writer, jv journal.JournalVoucher, names *Names) error {
+	flag := "*"
+	if jv.VoucherStatus != journal.StatusPosted {
+		flag = "!"
+	}
+	date := jv.Date.Format(calendar.AD)
+	narration := oneLine(jv.Narration)
+
+	if e.Format == Beancount {
+		fmt.Fprintf(w, "%s %s %s\n", date, flag, quote(narration))
+	} else {
+		fmt.Fprintf(w, "%s %s (%s) %s\n", date, flag, jv.Code, narration)
+	}
+	meta := [][2]string{{"code", jv.Code}, {"tigg-id", jv.ID}}
+	if jv.BaseCurrencyCode != "" && jv.BaseCurrencyCode != jv.CurrencyCode && jv.ExchangeRate != nil {
+		meta = append(meta, [2]string{"exchange-rate", jv.ExchangeRate.String() + " " + jv.BaseCurrencyCode + "/" + jv.CurrencyCode})
+	}
+	e.writeMeta(w, "  ", meta)
+
+	for _, item := range jv.Items {
+		name, ok := names.Lookup(item.AccountID, item.AccountCode)
+		if !ok {
+			return fmt.Errorf("unknown account %q", item.AccountCode+item.AccountID)
+		}
+
+		amount, currency := item.Amount, jv.CurrencyCode
+		var itemMeta [][2]string
+		if item.BaseAmount != nil && jv.BaseCurrencyCode != "" && jv.BaseCurrencyCode != jv.CurrencyCode {
+			itemMeta = append(itemMeta, [2]string{"fx-amount", signed(item.Amount, item.TxnType).String() + " " + jv.CurrencyCode})
+			amount, currency = *item.BaseAmount, jv.BaseCurrencyCode
+		}
+		if currency == "" {
+			currency = amount.Currency()
+		}
+		if currency == "" {
+			return fmt.Errorf("account %s: amount has no currency", name)
+		}
+		amount = signed(amount, item.TxnType)
+
+		fmt.Fprintf(w, "  %s  %s %s\n", name, amount.String(), commodity(currency, e.Format))
+
+		itemMeta = append(itemMeta, [2]string{"narration", item.Narration})
+		keys := make([]string, 0, len(item.Dimensions))
+		for k := range item.Dimensions {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			itemMeta = append(itemMeta, [2]string{k, item.Dimensions[k]})
+		}
+		e.writeMeta(w, "    ", itemMeta)
+	}
+	_, err := w.WriteString("\n")
+	return err
+}
+
+// writeMeta writes key/value metadata: "; key: value" comments for ledger-cli,
+// which it reads as tags, and `key: "value"` lines for beancount. Empty values
+// are left out.
+func (e *Exporter) writeMeta(w *bufio.Writer, indent string, meta [][2]string) {
+	for _, kv := range meta {
+		value := oneLine(kv[1])
+		if value == "" {
+			continue
+		}
+		if e.Format == Beancount {
+			fmt.Fprintf(w, "%s%s: %s\n", indent, metaKey(kv[0]), quote(value))
+		} else {
+			fmt.Fprintf(w, "%s; %s: %s\n", indent, strings.ReplaceAll(kv[0], ":", "-"), value)
+		}
+	}
+}
+
+// signed returns the amount negated for credits.
+func signed(m money.Money, txnType string) money.Money {
+	if txnType == journal.TxnTypeCredit {
+		return m.Neg()
+	}
+	return m
+}
+
+// commodity returns the currency as a commodity name. Beancount commodities
+// are upper case; ledger-cli quotes anything that is not plain letters.
+func commodity(currency string, format Format) string {
+	if format == Beancount {
+		return strings.ToUpper(currency)
+	}
+	for _, r := range currency {
+		if !('A' <= r && r <= 'Z' || 'a' <= r && r <= 'z') {
+			return `"` + currency + `"`
+		}
+	}
+	return currency
+}
+
+// metaKey turns a key into a beancount metadata key, which must start with a
+// lower-case letter followed by letters, digits, dashes or underscores.
+func metaKey(key string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(key) {
+		switch {
+		case 'a' <= r && r <= 'z', b.Len() > 0 && ('0' <= r && r <= '9' || r == '-' || r == '_'):
+			b.WriteRune(r)
+		case b.Len() > 0:
+			b.WriteByte('-')
+		}
+	}
+	if b.Len() == 0 {
+		return "x"
+	}
+	return b.String()
+}
+
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
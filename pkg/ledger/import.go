@@ -0,0 +1,533 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/accountgroup"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// AccountService lists and creates accounts. *account.Service satisfies it.
+type AccountService interface {
+	AccountLister
+	CreateAccount(acc account.Account) (*account.Account, error)
+}
+
+// GroupService lists and creates account groups. *accountgroup.Service
+// satisfies it.
+type GroupService interface {
+	GroupLister
+	CreateAccountGroup(req accountgroup.CreateAccountGroupRequest) (*accountgroup.AccountGroup, error)
+}
+
+// VoucherCreator creates journal vouchers. *journal.Service satisfies it.
+type VoucherCreator interface {
+	CreateJournalVoucher(jv journal.JournalVoucher) (*journal.JournalVoucher, error)
+}
+
+// Posting and account metadata keys with a meaning of their own. Any other
+// posting metadata becomes a voucher line dimension.
+const (
+	MetaCode        = "code"
+	MetaDescription = "description"
+	MetaNarration   = "narration"
+	metaFXAmount    = "fx-amount"
+	metaTiggID      = "tigg-id"
+)
+
+// PlannedGroup is an account group the import will create.
+type PlannedGroup struct {
+	Path string
+	Name string
+	// Parent is the path of the parent group. ParentID is set when the parent
+	// already exists in Tigg, otherwise the parent is planned before this group.
+	Parent   string
+	ParentID string
+}
+
+// PlannedAccount is an account the import will create.
+type PlannedAccount struct {
+	Path        string
+	Name        string
+	Code        string
+	Description string
+	// Group is the path of the parent group; GroupID is set when it already
+	// exists in Tigg.
+	Group   string
+	GroupID string
+}
+
+// Plan is what an import would write. It is built without touching Tigg, so
+// it can be printed and reviewed as a dry run before Apply.
+type Plan struct {
+	Groups   []PlannedGroup
+	Accounts []PlannedAccount
+	Vouchers []journal.JournalVoucher
+	// Problems lists everything that keeps the journal from being imported,
+	// prefixed with the journal line. Apply refuses a plan with problems.
+	Problems []string
+}
+
+// WriteTo prints the plan for review.
+func (p *Plan) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Account groups to create: %d\n", len(p.Groups))
+	for _, g := range p.Groups {
+		fmt.Fprintf(&b, "  + %s\n", g.Path)
+	}
+	fmt.Fprintf(&b, "Accounts to create: %d\n", len(p.Accounts))
+	for _, a := range p.Accounts {
+		fmt.Fprintf(&b, "  + %-10s %s\n", a.Code, a.Path)
+	}
+	fmt.Fprintf(&b, "Journal vouchers to create: %d\n", len(p.Vouchers))
+	for _, jv := range p.Vouchers {
+		var debits []money.Money
+		for _, item := range jv.Items {
+			if item.TxnType == journal.TxnTypeDebit {
+				debits = append(debits, item.Amount)
+			}
+		}
+		total, _ := money.Sum(debits...)
+		fmt.Fprintf(&b, "  + %-12s %s %15s %s  %s\n", jv.Code, jv.Date, total, jv.CurrencyCode, jv.Narration)
+	}
+	if len(p.Problems) > 0 {
+		fmt.Fprintf(&b, "Problems: %d\n", len(p.Problems))
+		for _, msg := range p.Problems {
+			fmt.Fprintf(&b, "  ! %s\n", msg)
+		}
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// VoucherResult is the outcome of creating one planned voucher.
+type VoucherResult struct {
+	Code string
+	ID   string
+	Err  error
+}
+
+// Result records what Apply created.
+type Result struct {
+	GroupIDs   map[string]string
+	AccountIDs map[string]string
+	Vouchers   []VoucherResult
+}
+
+// Failed returns the number of vouchers that could not be created.
+func (r *Result) Failed() int {
+	n := 0
+	for _, v := range r.Vouchers {
+		if v.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Importer moves a parsed ledger-cli or beancount journal into Tigg. Account
+// names are matched against existing accounts the way Exporter names them, so
+// an exported journal imports back without creating anything but vouchers.
+// Missing groups and accounts are created under the top-level group of their
+// root (Assets, Liabilities, ...). Vouchers are always created as drafts.
+type Importer struct {
+	accounts AccountService
+	groups   GroupService
+	vouchers VoucherCreator
+
+	// DefaultCurrency is used for amounts written without a commodity.
+	DefaultCurrency string
+	// Commodities renames journal commodities to currency codes, e.g. "$" to "USD".
+	Commodities map[string]string
+	// VoucherPrefix prefixes the code of vouchers whose transaction has none;
+	// the journal line number follows, so re-planning the same file gives the
+	// same codes.
+	VoucherPrefix string
+	// AccountCodePrefixes gives the code prefix of new accounts per root.
+	// Codes continue from the highest existing code with the same prefix.
+	AccountCodePrefixes map[string]string
+}
+
+func NewImporter(accounts AccountService, groups GroupService, vouchers VoucherCreator) *Importer {
+	return &Importer{
+		accounts:        accounts,
+		groups:          groups,
+		vouchers:        vouchers,
+		DefaultCurrency: "NPR",
+		VoucherPrefix:   "IMP",
+		AccountCodePrefixes: map[string]string{
+			RootAssets:      "AS",
+			RootLiabilities: "LI",
+			RootEquity:      "EQ",
+			RootIncome:      "IN",
+			RootExpenses:    "EX",
+		},
+	}
+}
+
+// planner holds the Tigg state a plan is built against.
+type planner struct {
+	im     *Importer
+	format Format
+	plan   *Plan
+
+	accountByName map[string]account.Account
+	accountByCode map[string]account.Account
+	// groupIDs maps a lower-cased path to an existing group ID, planned maps
+	// it to the index of a planned group.
+	groupIDs map[string]string
+	planned  map[string]int
+	codes    map[string]bool
+	// codeOf is the Tigg code of every journal account name.
+	codeOf map[string]string
+}
+
+// Plan lists existing accounts and groups and works out what importing j
+// would create. Nothing is written.
+func (im *Importer) Plan(j *Journal) (*Plan, error) {
+	accounts, err := im.accounts.ListAccounts()
+	if err != nil {
+		return nil, fmt.Errorf("list accounts: %w", err)
+	}
+	groups, err := im.groups.ListAccountGroups()
+	if err != nil {
+		return nil, fmt.Errorf("list account groups: %w", err)
+	}
+	names, err := NewNames(accounts, groups, j.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	pl := &planner{
+		im:            im,
+		format:        j.Format,
+		plan:          &Plan{},
+		accountByName: map[string]account.Account{},
+		accountByCode: map[string]account.Account{},
+		groupIDs:      map[string]string{},
+		planned:       map[string]int{},
+		codes:         map[string]bool{},
+		codeOf:        map[string]string{},
+	}
+	for _, acc := range accounts {
+		name, _ := names.Lookup(acc.ID, acc.Code)
+		pl.accountByName[strings.ToLower(name)] = acc
+		pl.accountByCode[acc.Code] = acc
+		pl.codes[acc.Code] = true
+	}
+	pl.indexGroups(groups)
+
+	decls := map[string]AccountDecl{}
+	var order []string
+	for _, d := range j.Accounts {
+		if _, ok := decls[d.Name]; !ok {
+			order = append(order, d.Name)
+		}
+		decls[d.Name] = d
+	}
+	for _, txn := range j.Transactions {
+		for _, p := range txn.Postings {
+			if _, ok := decls[p.Account]; !ok {
+				decls[p.Account] = AccountDecl{Line: txn.Line, Name: p.Account}
+				order = append(order, p.Account)
+			}
+		}
+	}
+	for _, name := range order {
+		if err := pl.resolveAccount(decls[name]); err != nil {
+			pl.problem(decls[name].Line, err)
+		}
+	}
+
+	for _, txn := range j.Transactions {
+		jv, err := pl.voucher(txn)
+		if err != nil {
+			pl.problem(txn.Line, err)
+			continue
+		}
+		pl.plan.Vouchers = append(pl.plan.Vouchers, *jv)
+	}
+	return pl.plan, nil
+}
+
+func (pl *planner) problem(line int, err error) {
+	pl.plan.Problems = append(pl.plan.Problems, fmt.Sprintf("line %d: %v", line, err))
+}
+
+// indexGroups keys every existing group by its path. Each root maps to the
+// top-level group named after it, or failing that the first top-level group
+// of that class.
+func (pl *planner) indexGroups(groups []accountgroup.AccountGroup) {
+	groupByID := make(map[string]accountgroup.AccountGroup, len(groups))
+	for _, g := range groups {
+		groupByID[g.ID] = g
+	}
+	sorted := append([]accountgroup.AccountGroup(nil), groups...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	for _, g := range sorted {
+		root, err := account.NormalizeClass(g.AccountClassName)
+		if err != nil {
+			continue
+		}
+		key := pathKey(append([]string{root}, groupChain(g.ID, groupByID, root, pl.format)...), pl.format)
+		if _, ok := pl.groupIDs[key]; !ok {
+			pl.groupIDs[key] = g.ID
+		}
+	}
+	for _, g := range sorted {
+		root, err := account.NormalizeClass(g.AccountClassName)
+		if err != nil || (g.ParentGroupID != nil && *g.ParentGroupID != "") {
+			continue
+		}
+		if key := strings.ToLower(root); pl.groupIDs[key] == "" {
+			pl.groupIDs[key] = g.ID
+		}
+	}
+}
+
+// resolveAccount matches a journal account to a Tigg account by its code
+// metadata or its name, planning the account and its groups when neither
+// matches.
+func (pl *planner) resolveAccount(d AccountDecl) error {
+	parts := strings.Split(d.Name, ":")
+	root, err := account.NormalizeClass(parts[0])
+	if err != nil {
+		return err
+	}
+	parts[0] = root
+	if len(parts) < 2 {
+		return fmt.Errorf("account %q has no name below its root", d.Name)
+	}
+
+	if code := d.Meta[MetaCode]; code != "" {
+		if _, ok := pl.accountByCode[code]; ok {
+			pl.codeOf[d.Name] = code
+			return nil
+		}
+	}
+	if acc, ok := pl.accountByName[pathKey(parts, pl.format)]; ok {
+		pl.codeOf[d.Name] = acc.Code
+		return nil
+	}
+
+	groupPath, groupID, err := pl.ensureGroups(parts[:len(parts)-1])
+	if err != nil {
+		return err
+	}
+	code := d.Meta[MetaCode]
+	if code == "" || pl.codes[code] {
+		code = pl.nextCode(root)
+	}
+	pl.codes[code] = true
+	pl.codeOf[d.Name] = code
+	pl.plan.Accounts = append(pl.plan.Accounts, PlannedAccount{
+		Path:        strings.Join(parts, ":"),
+		Name:        pl.displayName(parts[len(parts)-1]),
+		Code:        code,
+		Description: d.Meta[MetaDescription],
+		Group:       groupPath,
+		GroupID:     groupID,
+	})
+	return nil
+}
+
+// ensureGroups plans every missing group along parts, which starts at the
+// root, and returns the path and, if it already exists, the ID of the last one.
+func (pl *planner) ensureGroups(parts []string) (string, string, error) {
+	rootKey := strings.ToLower(parts[0])
+	parentID, ok := pl.groupIDs[rootKey]
+	if !ok {
+		return "", "", fmt.Errorf("no top-level account group for %s", parts[0])
+	}
+	parentPath := parts[0]
+	for i := 1; i < len(parts); i++ {
+		path := strings.Join(parts[:i+1], ":")
+		key := pathKey(parts[:i+1], pl.format)
+		if id, ok := pl.groupIDs[key]; ok {
+			parentPath, parentID = path, id
+			continue
+		}
+		if idx, ok := pl.planned[key]; ok {
+			// Paths differing only in case or spelling share one group, known
+			// by the path it was first planned under.
+			path = pl.plan.Groups[idx].Path
+		} else {
+			pl.planned[key] = len(pl.plan.Groups)
+			pl.plan.Groups = append(pl.plan.Groups, PlannedGroup{
+				Path:     path,
+				Name:     pl.displayName(parts[i]),
+				Parent:   parentPath,
+				ParentID: parentID,
+			})
+		}
+		parentPath, parentID = path, ""
+	}
+	return parentPath, parentID, nil
+}
+
+// nextCode returns the next free account code for a root, e.g. EX0042.
+func (pl *planner) nextCode(root string) string {
+	prefix := pl.im.AccountCodePrefixes[root]
+	highest := 0
+	for code := range pl.codes {
+		if n, err := strconv.Atoi(strings.TrimPrefix(code, prefix)); err == nil && strings.HasPrefix(code, prefix) && n > highest {
+			highest = n
+		}
+	}
+	return fmt.Sprintf("%s%04d", prefix, highest+1)
+}
+
+// displayName undoes beancount's dashes so new groups and accounts get
+// readable names.
+func (pl *planner) displayName(part string) string {
+	if pl.format == Beancount {
+		return strings.ReplaceAll(part, "-", " ")
+	}
+	return part
+}
+
+// voucher turns a transaction into a draft journal voucher. Postings with a
+// price are booked at the price, so every line is in one currency.
+func (pl *planner) voucher(txn Transaction) (*journal.JournalVoucher, error) {
+	code := txn.Code
+	if code == "" {
+		code = txn.Meta[MetaCode]
+	}
+	if code == "" {
+		code = fmt.Sprintf("%s-%06d", pl.im.VoucherPrefix, txn.Line)
+	}
+
+	var narration []string
+	for _, s := range []string{txn.Payee, txn.Narration} {
+		if s = strings.TrimSpace(s); s != "" {
+			narration = append(narration, s)
+		}
+	}
+
+	jv := &journal.JournalVoucher{Code: code, Date: txn.Date, Narration: strings.Join(narration, " - ")}
+	for _, p := range txn.Postings {
+		weight := p.Weight()
+		currency := pl.currency(weight.Currency())
+		switch {
+		case jv.CurrencyCode == "":
+			jv.CurrencyCode = currency
+		case jv.CurrencyCode != currency:
+			return nil, fmt.Errorf("transaction mixes %s and %s without a price", jv.CurrencyCode, currency)
+		}
+		if weight.IsZero() {
+			continue
+		}
+
+		item := journal.JournalVoucherItem{
+			AccountCode: pl.codeOf[p.Account],
+			Amount:      weight.Abs().WithCurrency(currency),
+			TxnType:     journal.TxnTypeDebit,
+			Narration:   p.Meta[MetaNarration],
+		}
+		if weight.Sign() < 0 {
+			item.TxnType = journal.TxnTypeCredit
+		}
+		for k, v := range p.Meta {
+			switch k {
+			case MetaNarration, metaFXAmount, metaTiggID:
+				continue
+			}
+			if item.Dimensions == nil {
+				item.Dimensions = map[string]string{}
+			}
+			item.Dimensions[k] = v
+		}
+		jv.Items = append(jv.Items, item)
+	}
+
+	if err := jv.Validate(); err != nil {
+		return nil, err
+	}
+	return jv, nil
+}
+
+func (pl *planner) currency(commodity string) string {
+	if c, ok := pl.im.Commodities[commodity]; ok {
+		return c
+	}
+	if commodity == "" {
+		return pl.im.DefaultCurrency
+	}
+	return commodity
+}
+
+// pathKey is the lower-cased, format-normalised key of an account path.
+func pathKey(parts []string, format Format) string {
+	out := make([]string, len(parts))
+	for i, p := range parts {
+		out[i] = strings.ToLower(component(p, format))
+	}
+	return strings.Join(out, ":")
+}
+
+// Apply creates the planned groups, accounts and vouchers in that order.
+// Group and account failures stop the import, since later steps depend on
+// them; voucher failures are recorded per voucher and the rest still run.
+func (im *Importer) Apply(ctx context.Context, p *Plan) (*Result, error) {
+	if len(p.Problems) > 0 {
+		return nil, fmt.Errorf("import plan has %d problems, starting with %s", len(p.Problems), p.Problems[0])
+	}
+
+	res := &Result{GroupIDs: map[string]string{}, AccountIDs: map[string]string{}}
+	for _, g := range p.Groups {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		parentID := g.ParentID
+		if parentID == "" {
+			parentID = res.GroupIDs[g.Parent]
+		}
+		if parentID == "" {
+			return res, fmt.Errorf("create account group %s: parent %s is not planned", g.Path, g.Parent)
+		}
+		created, err := im.groups.CreateAccountGroup(accountgroup.CreateAccountGroupRequest{Name: g.Name, ParentGroupID: &parentID})
+		if err != nil {
+			return res, fmt.Errorf("create account group %s: %w", g.Path, err)
+		}
+		res.GroupIDs[g.Path] = created.ID
+	}
+
+	for _, a := range p.Accounts {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		groupID := a.GroupID
+		if groupID == "" {
+			groupID = res.GroupIDs[a.Group]
+		}
+		if groupID == "" {
+			return res, fmt.Errorf("create account %s: group %s is not planned", a.Path, a.Group)
+		}
+		created, err := im.accounts.CreateAccount(account.Account{Name: a.Name, Code: a.Code, Description: a.Description, ParentGroupID: &groupID})
+		if err != nil {
+			return res, fmt.Errorf("create account %s: %w", a.Path, err)
+		}
+		res.AccountIDs[a.Code] = created.ID
+	}
+
+	for _, jv := range p.Vouchers {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		created, err := im.vouchers.CreateJournalVoucher(jv)
+		if err != nil {
+			res.Vouchers = append(res.Vouchers, VoucherResult{Code: jv.Code, Err: err})
+			continue
+		}
+		res.Vouchers = append(res.Vouchers, VoucherResult{Code: jv.Code, ID: created.ID})
+	}
+	return res, nil
+}
@@ -0,0 +1,365 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/accountgroup"
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+func strPtr(s string) *string { return &s }
+
+type stubBooks struct {
+	accounts []account.Account
+	groups   []accountgroup.AccountGroup
+	vouchers []journal.JournalVoucher
+}
+
+func (b *stubBooks) ListAccounts() ([]account.Account, error) { return b.accounts, nil }
+
+func (b *stubBooks) ListAccountGroups() ([]accountgroup.AccountGroup, error) { return b.groups, nil }
+
+func (b *stubBooks) CreateAccount(acc account.Account) (*account.Account, error) {
+	acc.ID = fmt.Sprintf("acc-%d", len(b.accounts)+1)
+	b.accounts = append(b.accounts, acc)
+	return &acc, nil
+}
+
+func (b *stubBooks) CreateAccountGroup(req accountgroup.CreateAccountGroupRequest) (*accountgroup.AccountGroup, error) {
+	g := accountgroup.AccountGroup{ID: fmt.Sprintf("g-%d", len(b.groups)+1), Name: req.Name, ParentGroupID: req.ParentGroupID}
+	b.groups = append(b.groups, g)
+	return &g, nil
+}
+
+func (b *stubBooks) CreateJournalVoucher(jv journal.JournalVoucher) (*journal.JournalVoucher, error) {
+	jv.ID = fmt.Sprintf("jv-%d", len(b.vouchers)+1)
+	jv.VoucherStatus = journal.StatusDraft
+	b.vouchers = append(b.vouchers, jv)
+	return &jv, nil
+}
+
+func (b *stubBooks) EachJournalVoucher(fn func(journal.JournalVoucher) error) error {
+	for _, jv := range b.vouchers {
+		if err := fn(jv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sampleBooks() *stubBooks {
+	return &stubBooks{
+		groups: []accountgroup.AccountGroup{
+			{ID: "g-exp", Name: "Expenses", AccountClassName: "Expense"},
+			{ID: "g-opex", Name: "Operating Expenses", AccountClassName: "Expense", ParentGroupID: strPtr("g-exp")},
+			{ID: "g-assets", Name: "Assets", AccountClassName: "Assets"},
+			{ID: "g-bank", Name: "Bank Accounts", AccountClassName: "Assets", ParentGroupID: strPtr("g-assets")},
+		},
+		accounts: []account.Account{
+			{ID: "acc-rent", Code: "EX0001", Name: "Office Rent", AccountClassName: "Expense", PrimaryGroupID: "g-exp", ParentGroupID: strPtr("g-opex")},
+			{ID: "acc-bank", Code: "BA0001", Name: "NIC Asia (Current)", AccountClassName: "Assets", PrimaryGroupID: "g-assets", ParentGroupID: strPtr("g-bank")},
+		},
+		vouchers: []journal.JournalVoucher{
+			{
+				ID: "jv-1", Code: "JV-0001", Date: calendar.MustParseDate("2024-07-16", calendar.AD),
+				CurrencyCode: "NPR", VoucherStatus: journal.StatusPosted, Narration: "Rent for \"July\"",
+				Items: []journal.JournalVoucherItem{
+					{AccountCode: "EX0001", Amount: money.MustParse("25000.00", "NPR"), TxnType: journal.TxnTypeDebit, Dimensions: map[string]string{"cost_center": "KTM"}},
+					{AccountID: "acc-bank", Amount: money.MustParse("25000.00", "NPR"), TxnType: journal.TxnTypeCredit},
+				},
+			},
+			{
+				ID: "jv-2", Code: "JV-0002", Date: calendar.MustParseDate("2024-07-17", calendar.AD),
+				CurrencyCode: "NPR", VoucherStatus: journal.StatusVoided,
+				Items: []journal.JournalVoucherItem{
+					{AccountCode: "EX0001", Amount: money.MustParse("1.00", "NPR"), TxnType: journal.TxnTypeDebit},
+					{AccountCode: "BA0001", Amount: money.MustParse("1.00", "NPR"), TxnType: journal.TxnTypeCredit},
+				},
+			},
+		},
+	}
+}
+
+func TestNames(t *testing.T) {
+	books := sampleBooks()
+	ledgerNames, err := NewNames(books.accounts, books.groups, LedgerCLI)
+	if err != nil {
+		t.Fatalf("NewNames failed: %v", err)
+	}
+	if got, _ := ledgerNames.Lookup("acc-rent", ""); got != "Expenses:Operating Expenses:Office Rent" {
+		t.Fatalf("unexpected ledger name %q", got)
+	}
+
+	beanNames, err := NewNames(books.accounts, books.groups, Beancount)
+	if err != nil {
+		t.Fatalf("NewNames failed: %v", err)
+	}
+	if got, _ := beanNames.Lookup("", "BA0001"); got != "Assets:Bank-Accounts:NIC-Asia-Current" {
+		t.Fatalf("unexpected beancount name %q", got)
+	}
+
+	books.accounts[0].AccountClassName = "Suspense"
+	if _, err := NewNames(books.accounts, books.groups, LedgerCLI); err == nil {
+		t.Fatal("expected an unknown account class to be rejected")
+	}
+}
+
+func TestExportLedgerCLI(t *testing.T) {
+	books := sampleBooks()
+	var out strings.Builder
+	exp := &Exporter{Format: LedgerCLI, Accounts: books, Groups: books, Vouchers: books}
+	if err := exp.Export(&out); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	want := `account Assets:Bank Accounts:NIC Asia (Current)
+  ; code: BA0001
+  ; tigg-id: acc-bank
+account Expenses:Operating Expenses:Office Rent
+  ; code: EX0001
+  ; tigg-id: acc-rent
+
+2024-07-16 * (JV-0001) Rent for "July"
+  ; code: JV-0001
+  ; tigg-id: jv-1
+  Expenses:Operating Expenses:Office Rent  25000.00 NPR
+    ; cost_center: KTM
+  Assets:Bank Accounts:NIC Asia (Current)  -25000.00 NPR
+
+`
+	if out.String() != want {
+		t.Fatalf("unexpected ledger output:\n%s", out.String())
+	}
+}
+
+func TestExportBeancount(t *testing.T) {
+	books := sampleBooks()
+	books.vouchers = append(books.vouchers, journal.JournalVoucher{
+		ID: "jv-3", Code: "JV-USD-1", Date: calendar.MustParseDate("2024-07-18", calendar.AD),
+		CurrencyCode: "USD", BaseCurrencyCode: "NPR", ExchangeRate: rate("133.5"), VoucherStatus: journal.StatusDraft,
+		Items: []journal.JournalVoucherItem{
+			{AccountCode: "EX0001", Amount: money.MustParse("10.00", "USD"), BaseAmount: amount("1335.00", "NPR"), TxnType: journal.TxnTypeDebit},
+			{AccountCode: "BA0001", Amount: money.MustParse("10.00", "USD"), BaseAmount: amount("1335.00", "NPR"), TxnType: journal.TxnTypeCredit},
+		},
+	})
+
+	var out strings.Builder
+	exp := &Exporter{Format: Beancount, Accounts: books, Groups: books, Vouchers: books}
+	if err := exp.Export(&out); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"1970-01-01 open Assets:Bank-Accounts:NIC-Asia-Current\n  code: \"BA0001\"\n",
+		"2024-07-16 * \"Rent for \\\"July\\\"\"\n  code: \"JV-0001\"\n",
+		"  Expenses:Operating-Expenses:Office-Rent  25000.00 NPR\n    cost_center: \"KTM\"\n",
+		"2024-07-18 ! \"\"\n",
+		"  exchange-rate: \"133.5 NPR/USD\"\n",
+		"  Assets:Bank-Accounts:NIC-Asia-Current  -1335.00 NPR\n    fx-amount: \"-10.00 USD\"\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "JV-0002") {
+		t.Fatalf("expected voided voucher to be skipped, got:\n%s", out.String())
+	}
+}
+
+func TestExportUnknownAccount(t *testing.T) {
+	books := sampleBooks()
+	books.vouchers[0].Items[0].AccountCode = "XX9999"
+	exp := &Exporter{Format: LedgerCLI, Accounts: books, Groups: books, Vouchers: books}
+	err := exp.Export(&strings.Builder{})
+	if err == nil || !strings.Contains(err.Error(), "JV-0001") {
+		t.Fatalf("expected unknown account error naming the voucher, got %v", err)
+	}
+}
+
+func TestParseLedgerCLI(t *testing.T) {
+	src := `; opening comment
+account Expenses:Travel
+  ; code: EX0500
+
+2024/7/20 * (T-1) Flight to Pokhara  ; trip: PKR
+    Expenses:Travel        USD 100.00 @ 133.50 NPR
+    ; cost_center: PKR
+    Assets:Cash
+
+P 2024/07/20 USD 133.50 NPR
+`
+	j, err := Parse(strings.NewReader(src), LedgerCLI)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(j.Accounts) != 1 || j.Accounts[0].Meta["code"] != "EX0500" {
+		t.Fatalf("unexpected accounts %+v", j.Accounts)
+	}
+	if len(j.Transactions) != 1 {
+		t.Fatalf("expected 1 transaction, got %d", len(j.Transactions))
+	}
+	txn := j.Transactions[0]
+	if txn.Code != "T-1" || txn.Payee != "Flight to Pokhara" || txn.Meta["trip"] != "PKR" || txn.Date.String() != "2024-07-20" {
+		t.Fatalf("unexpected transaction %+v", txn)
+	}
+	if got := txn.Postings[0].Weight(); got.String() != "13350.00" || got.Currency() != "NPR" {
+		t.Fatalf("unexpected priced weight %s %s", got, got.Currency())
+	}
+	if txn.Postings[0].Meta["cost_center"] != "PKR" {
+		t.Fatalf("expected posting metadata, got %v", txn.Postings[0].Meta)
+	}
+	if got := txn.Postings[1].Amount; got.String() != "-13350.00" || got.Currency() != "NPR" {
+		t.Fatalf("expected elided amount -13350.00 NPR, got %s %s", got, got.Currency())
+	}
+
+	_, err = Parse(strings.NewReader("2024-01-01 Bad\n  Assets:Cash\n  Expenses:Rent\n"), LedgerCLI)
+	if err == nil || !strings.Contains(err.Error(), "line 1:") {
+		t.Fatalf("expected two elided amounts to be rejected with the line, got %v", err)
+	}
+}
+
+func TestImportRoundTrip(t *testing.T) {
+	books := sampleBooks()
+	var out strings.Builder
+	exp := &Exporter{Format: Beancount, Accounts: books, Groups: books, Vouchers: books}
+	if err := exp.Export(&out); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	j, err := Parse(strings.NewReader(out.String()), Beancount)
+	if err != nil {
+		t.Fatalf("Parse failed: %v\n%s", err, out.String())
+	}
+	plan, err := NewImporter(books, books, books).Plan(j)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if len(plan.Groups) != 0 || len(plan.Accounts) != 0 || len(plan.Problems) != 0 {
+		t.Fatalf("expected exported books to match existing accounts, got %+v", plan)
+	}
+	if len(plan.Vouchers) != 1 {
+		t.Fatalf("expected 1 voucher, got %d", len(plan.Vouchers))
+	}
+	jv := plan.Vouchers[0]
+	if jv.Code != "JV-0001" || jv.Items[0].AccountCode != "EX0001" || jv.Items[0].Dimensions["cost_center"] != "KTM" || jv.Items[1].TxnType != journal.TxnTypeCredit {
+		t.Fatalf("unexpected voucher %+v", jv)
+	}
+}
+
+func TestImportCreatesMissingAccounts(t *testing.T) {
+	books := sampleBooks()
+	books.vouchers = nil
+	src := `option "operating_currency" "NPR"
+2024-01-01 open Expenses:Operating-Expenses:Utilities:Electricity
+  description: "NEA bills"
+
+2024-07-20 * "NEA" "Electricity for Asar"
+  Expenses:Operating-Expenses:Utilities:Electricity  4500.00 NPR
+    narration: "Meter 1"
+  Assets:Bank-Accounts:NIC-Asia-Current
+
+2024-07-21 * "Mixed"
+  Expenses:Operating-Expenses:Office-Rent  10.00 USD
+  Assets:Bank-Accounts:NIC-Asia-Current  -1335.00 NPR
+`
+	j, err := Parse(strings.NewReader(src), Beancount)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	im := NewImporter(books, books, books)
+	plan, err := im.Plan(j)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+
+	if len(plan.Groups) != 1 || plan.Groups[0].Name != "Utilities" || plan.Groups[0].ParentID != "g-opex" {
+		t.Fatalf("unexpected planned groups %+v", plan.Groups)
+	}
+	if len(plan.Accounts) != 1 || plan.Accounts[0].Code != "EX0002" || plan.Accounts[0].Description != "NEA bills" {
+		t.Fatalf("unexpected planned accounts %+v", plan.Accounts)
+	}
+	if len(plan.Problems) != 1 || !strings.Contains(plan.Problems[0], "line 10:") {
+		t.Fatalf("expected the mixed-currency transaction to be a problem, got %v", plan.Problems)
+	}
+
+	var printed strings.Builder
+	if _, err := plan.WriteTo(&printed); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	for _, want := range []string{"+ Expenses:Operating-Expenses:Utilities\n", "+ EX0002", "IMP-000005", "! line 10:"} {
+		if !strings.Contains(printed.String(), want) {
+			t.Fatalf("expected plan to mention %q, got:\n%s", want, printed.String())
+		}
+	}
+
+	if _, err := im.Apply(context.Background(), plan); err == nil {
+		t.Fatal("expected a plan with problems to be refused")
+	}
+	plan.Problems = nil
+	res, err := im.Apply(context.Background(), plan)
+	if err != nil || res.Failed() != 0 {
+		t.Fatalf("Apply failed: %v %+v", err, res)
+	}
+	created := books.accounts[len(books.accounts)-1]
+	if created.Name != "Electricity" || *created.ParentGroupID != res.GroupIDs["Expenses:Operating-Expenses:Utilities"] {
+		t.Fatalf("unexpected created account %+v", created)
+	}
+	if len(books.vouchers) != 1 || books.vouchers[0].Items[0].AccountCode != "EX0002" || books.vouchers[0].Items[0].Narration != "Meter 1" {
+		t.Fatalf("unexpected created vouchers %+v", books.vouchers)
+	}
+	if books.vouchers[0].Narration != "NEA - Electricity for Asar" {
+		t.Fatalf("unexpected narration %q", books.vouchers[0].Narration)
+	}
+}
+
+func TestImportGroupsDifferingInCase(t *testing.T) {
+	books := sampleBooks()
+	books.vouchers = nil
+	src := `2024-07-20 * Rent
+    Expenses:Branch Office:Rent  100.00 NPR
+    Assets:Bank Accounts:NIC Asia (Current)
+
+2024-07-21 * Water
+    expenses:branch office:Water  20.00 NPR
+    Assets:Bank Accounts:NIC Asia (Current)
+`
+	j, err := Parse(strings.NewReader(src), LedgerCLI)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	im := NewImporter(books, books, books)
+	plan, err := im.Plan(j)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if len(plan.Groups) != 1 || len(plan.Accounts) != 2 || plan.Accounts[1].Group != plan.Groups[0].Path {
+		t.Fatalf("expected one shared group, got %+v %+v", plan.Groups, plan.Accounts)
+	}
+
+	res, err := im.Apply(context.Background(), plan)
+	if err != nil || res.Failed() != 0 {
+		t.Fatalf("Apply failed: %v %+v", err, res)
+	}
+	groupID := res.GroupIDs[plan.Groups[0].Path]
+	for _, acc := range books.accounts[len(books.accounts)-2:] {
+		if acc.ParentGroupID == nil || *acc.ParentGroupID != groupID {
+			t.Fatalf("expected %s under group %s, got %v", acc.Name, groupID, acc.ParentGroupID)
+		}
+	}
+}
+
+func rate(s string) *money.Rate {
+	r := money.MustParseRate(s)
+	return &r
+}
+
+func amount(s, currency string) *money.Money {
+	m := money.MustParse(s, currency)
+	return &m
+}
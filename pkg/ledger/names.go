@@ -0,0 +1,149 @@
+package ledger
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/accountgroup"
+)
+
+// Format is a plain-text accounting format.
+type Format string
+
+const (
+	LedgerCLI Format = "ledger"
+	Beancount Format = "beancount"
+)
+
+// Beancount's five root account types, one per account class.
+const (
+	RootAssets      = account.ClassAssets
+	RootLiabilities = account.ClassLiabilities
+	RootEquity      = account.ClassEquity
+	RootIncome      = account.ClassIncome
+	RootExpenses    = account.ClassExpenses
+)
+
+// Names maps Tigg accounts to hierarchical account names such as
+// "Expenses:Operating Expenses:Rent", built from the account class and the
+// AccountGroup parent chain.
+type Names struct {
+	byID   map[string]string
+	byCode map[string]string
+}
+
+// NewNames builds the account names for a format. Beancount names are
+// sanitised to its character rules. Accounts whose names collide get their
+// code appended.
+func NewNames(accounts []account.Account, groups []accountgroup.AccountGroup, format Format) (*Names, error) {
+	groupByID := make(map[string]accountgroup.AccountGroup, len(groups))
+	for _, g := range groups {
+		groupByID[g.ID] = g
+	}
+
+	n := &Names{byID: map[string]string{}, byCode: map[string]string{}}
+	used := map[string]bool{}
+	for _, acc := range accounts {
+		root, err := account.NormalizeClass(acc.AccountClassName)
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %w", acc.Code, err)
+		}
+
+		groupID := acc.PrimaryGroupID
+		if acc.ParentGroupID != nil && *acc.ParentGroupID != "" {
+			groupID = *acc.ParentGroupID
+		}
+		chain := groupChain(groupID, groupByID, root, format)
+
+		parts := []string{root}
+		for _, p := range append(chain, acc.Name) {
+			parts = append(parts, component(p, format))
+		}
+		name := strings.Join(parts, ":")
+		if used[name] {
+			sep := " "
+			if format == Beancount {
+				sep = "-"
+			}
+			name += sep + component(acc.Code, format)
+		}
+		used[name] = true
+
+		n.byID[acc.ID] = name
+		n.byCode[acc.Code] = name
+	}
+	return n, nil
+}
+
+// groupChain returns the group names from the top of the tree down to
+// groupID. The top group is dropped when it only repeats the root, as in
+// "Assets" under Assets.
+func groupChain(groupID string, groupByID map[string]accountgroup.AccountGroup, root string, format Format) []string {
+	var chain []string
+	seen := map[string]bool{}
+	for groupID != "" && !seen[groupID] {
+		seen[groupID] = true
+		g, ok := groupByID[groupID]
+		if !ok {
+			break
+		}
+		chain = append([]string{g.Name}, chain...)
+		groupID = ""
+		if g.ParentGroupID != nil {
+			groupID = *g.ParentGroupID
+		}
+	}
+	if len(chain) > 0 && strings.EqualFold(component(chain[0], format), root) {
+		chain = chain[1:]
+	}
+	return chain
+}
+
+// Lookup returns the name of the account with the given ID, or failing that code.
+func (n *Names) Lookup(id, code string) (string, bool) {
+	if name, ok := n.byID[id]; ok && id != "" {
+		return name, true
+	}
+	name, ok := n.byCode[code]
+	return name, ok && code != ""
+}
+
+// component turns a group or account name into one account name component.
+// ledger-cli accepts almost anything but treats two spaces as the end of the
+// account name; beancount wants a capital letter or digit followed by letters,
+// digits and dashes.
+func component(s string, format Format) string {
+	s = strings.TrimSpace(s)
+	if format != Beancount {
+		s = strings.Join(strings.Fields(s), " ")
+		return strings.ReplaceAll(s, ":", "-")
+	}
+
+	var b strings.Builder
+	dash := false
+	for _, r := range s {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	out := strings.TrimRight(b.String(), "-")
+	if out == "" {
+		return "X"
+	}
+	first := rune(out[0])
+	if unicode.IsLower(first) {
+		return string(unicode.ToUpper(first)) + out[1:]
+	}
+	if !unicode.IsUpper(first) && !unicode.IsDigit(first) {
+		return "X" + out
+	}
+	return out
+}
@@ -0,0 +1,458 @@
+package ledger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// Journal is a parsed ledger-cli or beancount file.
+type Journal struct {
+	Format       Format
+	Accounts     []AccountDecl
+	Transactions []Transaction
+}
+
+// AccountDecl is an "account" (ledger-cli) or "open" (beancount) directive.
+type AccountDecl struct {
+	Line int
+	Name string
+	Meta map[string]string
+}
+
+// Transaction is one dated entry with its postings.
+type Transaction struct {
+	Line      int
+	Date      calendar.Date
+	Flag      string
+	Code      string
+	Payee     string
+	Narration string
+	Meta      map[string]string
+	Postings  []Posting
+}
+
+// Posting is one line of a transaction. Amount is signed, positive for debits.
+// Price is the total cost in another commodity when the posting was written
+// with "@", "@@" or a {cost}, and has the same sign as Amount.
+type Posting struct {
+	Account string
+	Amount  money.Money
+	Price   *money.Money
+	Meta    map[string]string
+
+	elided bool
+}
+
+// Weight is the amount the posting contributes to the transaction's balance:
+// its price if it has one, otherwise its amount.
+func (p Posting) Weight() money.Money {
+	if p.Price != nil {
+		return *p.Price
+	}
+	return p.Amount
+}
+
+// ParseFile parses a journal file, taking the format from its extension:
+// ".beancount" and ".bean" are beancount, anything else ledger-cli.
+func ParseFile(path string) (*Journal, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	format := LedgerCLI
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".beancount", ".bean":
+		format = Beancount
+	}
+	return Parse(f, format)
+}
+
+var (
+	ledgerTxnHeader = regexp.MustCompile(`^(\d{4}[-/.]\d{1,2}[-/.]\d{1,2})(?:=\S+)?\s*([*!])?\s*(?:\(([^)]*)\))?\s*(.*)$`)
+	beanDirective   = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})\s+(\S+)\s*(.*)$`)
+	metaPattern     = regexp.MustCompile(`^([A-Za-z][\w-]*):{1,2}\s+(.*)$`)
+	amountPattern   = regexp.MustCompile(`^([-+]?)\s*("[^"]+"|[^-+0-9\s".,;@{}]+)?\s*([-+]?[0-9][0-9,]*(?:\.[0-9]+)?)\s*("[^"]+"|[^-+0-9\s".,;@{}]+)?$`)
+	beanString      = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+)
+
+// Parse reads a ledger-cli or beancount journal. Only the subset needed to
+// move books into Tigg is understood: account declarations, transactions with
+// their metadata, prices and costs, and one elided amount per transaction.
+// Other directives are skipped; those that would silently change balances,
+// such as automated transactions and beancount pad, are rejected.
+func Parse(r io.Reader, format Format) (*Journal, error) {
+	p := &parser{format: format, j: &Journal{Format: format}}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		p.line++
+		if err := p.parseLine(strings.TrimRight(sc.Text(), " \t\r")); err != nil {
+			return nil, lineError(p.line, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if err := p.finish(); err != nil {
+		return nil, err
+	}
+	return p.j, nil
+}
+
+// ParseError reports the line of a journal that could not be parsed.
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+func lineError(line int, err error) error {
+	if _, ok := err.(*ParseError); ok {
+		return err
+	}
+	return &ParseError{Line: line, Err: err}
+}
+
+type parser struct {
+	format Format
+	j      *Journal
+	line   int
+
+	txn  *Transaction
+	decl *AccountDecl
+	// skipping is set inside a directive whose indented lines are ignored.
+	skipping bool
+}
+
+func (p *parser) parseLine(line string) error {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	if line[0] == ' ' || line[0] == '\t' {
+		return p.parseIndented(strings.TrimSpace(line))
+	}
+
+	if err := p.finish(); err != nil {
+		return err
+	}
+	if strings.ContainsRune(";#%|*", rune(line[0])) {
+		return nil
+	}
+	if p.format == Beancount {
+		return p.parseBeanDirective(line)
+	}
+	return p.parseLedgerDirective(line)
+}
+
+func (p *parser) parseLedgerDirective(line string) error {
+	if m := ledgerTxnHeader.FindStringSubmatch(line); m != nil {
+		date, err := parseDate(m[1])
+		if err != nil {
+			return err
+		}
+		payee, comment := cutComment(m[4])
+		p.txn = &Transaction{Line: p.line, Date: date, Flag: m[2], Code: m[3], Payee: payee, Meta: map[string]string{}}
+		addMeta(p.txn.Meta, comment)
+		return nil
+	}
+
+	word, rest, _ := strings.Cut(line, " ")
+	switch word {
+	case "account":
+		name, _ := cutComment(rest)
+		p.decl = &AccountDecl{Line: p.line, Name: strings.TrimSpace(name), Meta: map[string]string{}}
+	case "=", "~":
+		return fmt.Errorf("automated and periodic transactions are not supported")
+	case "include":
+		return fmt.Errorf("include is not supported, concatenate the files first")
+	default:
+		p.skipping = true
+	}
+	return nil
+}
+
+func (p *parser) parseBeanDirective(line string) error {
+	m := beanDirective.FindStringSubmatch(line)
+	if m == nil {
+		if strings.HasPrefix(line, "include ") {
+			return fmt.Errorf("include is not supported, concatenate the files first")
+		}
+		// option, plugin, pushtag and the like.
+		p.skipping = true
+		return nil
+	}
+	date, err := parseDate(m[1])
+	if err != nil {
+		return err
+	}
+
+	body, _ := cutComment(m[3])
+	switch kind := m[2]; kind {
+	case "*", "!", "txn":
+		flag := kind
+		if kind == "txn" {
+			flag = "*"
+		}
+		p.txn = &Transaction{Line: p.line, Date: date, Flag: flag, Meta: map[string]string{}}
+		var strs []string
+		for _, s := range beanString.FindAllStringSubmatch(body, -1) {
+			strs = append(strs, unquote(s[1]))
+		}
+		switch len(strs) {
+		case 0:
+		case 1:
+			p.txn.Narration = strs[0]
+		default:
+			p.txn.Payee, p.txn.Narration = strs[0], strs[1]
+		}
+	case "open":
+		fields := strings.Fields(body)
+		if len(fields) == 0 {
+			return fmt.Errorf("open directive without an account")
+		}
+		p.decl = &AccountDecl{Line: p.line, Name: fields[0], Meta: map[string]string{}}
+	case "pad":
+		return fmt.Errorf("pad directives are not supported, write the padding transaction out")
+	default:
+		p.skipping = true
+	}
+	return nil
+}
+
+func (p *parser) parseIndented(line string) error {
+	switch {
+	case p.skipping:
+		return nil
+	case p.decl != nil:
+		if p.format == Beancount {
+			addBeanMeta(p.decl.Meta, line)
+		} else if strings.HasPrefix(line, ";") {
+			addMeta(p.decl.Meta, line[1:])
+		}
+		return nil
+	case p.txn == nil:
+		return fmt.Errorf("indented line outside a transaction")
+	}
+
+	meta := p.txn.Meta
+	if n := len(p.txn.Postings); n > 0 {
+		meta = p.txn.Postings[n-1].Meta
+	}
+	if strings.HasPrefix(line, ";") {
+		addMeta(meta, line[1:])
+		return nil
+	}
+	if p.format == Beancount && metaPattern.MatchString(line) {
+		addBeanMeta(meta, line)
+		return nil
+	}
+
+	body, comment := cutComment(line)
+	posting, err := p.parsePosting(body)
+	if err != nil {
+		return err
+	}
+	addMeta(posting.Meta, comment)
+	p.txn.Postings = append(p.txn.Postings, posting)
+	return nil
+}
+
+// parsePosting parses "[flag] Account  [amount [{cost}] [@ price | @@ total]]".
+// An elided amount is left zero and filled in by finish.
+func (p *parser) parsePosting(s string) (Posting, error) {
+	if len(s) > 1 && (s[0] == '*' || s[0] == '!') && (s[1] == ' ' || s[1] == '\t') {
+		s = strings.TrimSpace(s[1:])
+	}
+	if strings.HasPrefix(s, "(") || strings.HasPrefix(s, "[") {
+		return Posting{}, fmt.Errorf("virtual posting %q is not supported", s)
+	}
+
+	var name, rest string
+	if p.format == Beancount {
+		name, rest, _ = strings.Cut(strings.Replace(s, "\t", " ", 1), " ")
+	} else if i := accountEnd(s); i >= 0 {
+		name, rest = s[:i], s[i:]
+	} else {
+		name = s
+	}
+	posting := Posting{Account: strings.TrimSpace(name), Meta: map[string]string{}}
+
+	rest = strings.TrimSpace(rest)
+	if i := strings.Index(rest, "="); i >= 0 {
+		rest = strings.TrimSpace(rest[:i]) // balance assertion
+	}
+	if rest == "" {
+		posting.elided = true
+		return posting, nil
+	}
+
+	amountText, priceText, total := rest, "", false
+	if i := strings.Index(rest, "@@"); i >= 0 {
+		amountText, priceText, total = rest[:i], rest[i+2:], true
+	} else if i := strings.Index(rest, "@"); i >= 0 {
+		amountText, priceText = rest[:i], rest[i+1:]
+	}
+	if i := strings.Index(amountText, "{"); i >= 0 {
+		cost := strings.Trim(strings.TrimSpace(amountText[i:]), "{}")
+		amountText = amountText[:i]
+		if priceText == "" && cost != "" {
+			priceText, total = cost, strings.HasPrefix(strings.TrimSpace(rest[i:]), "{{")
+		}
+	}
+
+	amount, err := parseAmount(amountText)
+	if err != nil {
+		return Posting{}, err
+	}
+	posting.Amount = amount
+	if priceText == "" {
+		return posting, nil
+	}
+
+	price, err := parseAmount(priceText)
+	if err != nil {
+		return Posting{}, err
+	}
+	if !total {
+		price = price.MulRat(amount.Abs().Rat(), max(price.Scale(), money.Precision(price.Currency())), money.RoundHalfEven)
+	}
+	price = price.Abs()
+	if amount.Sign() < 0 {
+		price = price.Neg()
+	}
+	posting.Price = &price
+	return posting, nil
+}
+
+// accountEnd returns where a ledger-cli account name ends: at a tab or two
+// spaces. It returns -1 if the whole string is the name.
+func accountEnd(s string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\t' || (s[i] == ' ' && i+1 < len(s) && s[i+1] == ' ') {
+			return i
+		}
+	}
+	return -1
+}
+
+// finish closes the current directive, filling in an elided amount.
+func (p *parser) finish() error {
+	txn := p.txn
+	if txn != nil {
+		p.j.Transactions = append(p.j.Transactions, *txn)
+	}
+	if p.decl != nil {
+		p.j.Accounts = append(p.j.Accounts, *p.decl)
+	}
+	p.txn, p.decl, p.skipping = nil, nil, false
+	if txn == nil {
+		return nil
+	}
+
+	elided := -1
+	var sum money.Money
+	var sumErr error
+	for i, posting := range txn.Postings {
+		if posting.elided {
+			if elided >= 0 {
+				return &ParseError{Line: txn.Line, Err: fmt.Errorf("more than one posting without an amount")}
+			}
+			elided = i
+			continue
+		}
+		if sumErr == nil {
+			sum, sumErr = sum.Add(posting.Weight())
+		}
+	}
+	if elided < 0 {
+		return nil
+	}
+	if sumErr != nil {
+		return &ParseError{Line: txn.Line, Err: fmt.Errorf("cannot infer the elided amount: %w", sumErr)}
+	}
+	p.j.Transactions[len(p.j.Transactions)-1].Postings[elided].Amount = sum.Neg()
+	return nil
+}
+
+// parseAmount parses "1,500.00 NPR", "-25 USD", "NPR 100" or "$-5". The
+// commodity may be quoted, and is empty when the amount has none.
+func parseAmount(s string) (money.Money, error) {
+	s = strings.TrimSpace(s)
+	m := amountPattern.FindStringSubmatch(s)
+	if m == nil || (m[1] != "" && strings.ContainsAny(m[3], "+-")) || (m[2] != "" && m[4] != "") {
+		return money.Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	commodity := strings.Trim(m[2]+m[4], `"`)
+	number := strings.ReplaceAll(m[3], ",", "")
+	if m[1] == "-" {
+		number = "-" + strings.TrimLeft(number, "+")
+	}
+	return money.Parse(strings.TrimPrefix(number, "+"), commodity)
+}
+
+// parseDate accepts YYYY-MM-DD as well as ledger-cli's YYYY/MM/DD and
+// YYYY.MM.DD with or without zero padding.
+func parseDate(s string) (calendar.Date, error) {
+	norm := strings.NewReplacer("/", "-", ".", "-").Replace(s)
+	t, err := time.Parse("2006-1-2", norm)
+	if err != nil {
+		return calendar.Date{}, fmt.Errorf("invalid date %q", s)
+	}
+	return calendar.NewDate(t), nil
+}
+
+// cutComment splits s at its first ";" outside double quotes.
+func cutComment(s string) (body, comment string) {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == ';' && !quoted:
+			return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+		}
+	}
+	return strings.TrimSpace(s), ""
+}
+
+// addMeta records a "key: value" comment. Other comments, including
+// ledger-cli's ":tag:" lists, are ignored.
+func addMeta(meta map[string]string, comment string) {
+	if m := metaPattern.FindStringSubmatch(strings.TrimSpace(comment)); m != nil {
+		meta[m[1]] = strings.TrimSpace(m[2])
+	}
+}
+
+// addBeanMeta records a beancount `key: value` line, unquoting string values.
+func addBeanMeta(meta map[string]string, line string) {
+	body, _ := cutComment(line)
+	if m := metaPattern.FindStringSubmatch(body); m != nil {
+		value := strings.TrimSpace(m[2])
+		if s := beanString.FindStringSubmatch(value); s != nil && strings.HasPrefix(value, `"`) {
+			value = unquote(s[1])
+		}
+		meta[m[1]] = value
+	}
+}
+
+func unquote(s string) string {
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(s)
+}
@@ -0,0 +1,38 @@
+package money
+
+import "sync"
+
+// DefaultPrecision is used for currencies that have not been registered.
+const DefaultPrecision = 2
+
+var (
+	precisionMu sync.RWMutex
+	precisions  = map[string]int{
+		"NPR": 2,
+		"INR": 2,
+		"USD": 2,
+		"EUR": 2,
+		"GBP": 2,
+		"CNY": 2,
+		"JPY": 0,
+		"KWD": 3,
+	}
+)
+
+// Precision returns the number of minor-unit digits for a currency code,
+// e.g. 2 for NPR (paisa).
+func Precision(currency string) int {
+	precisionMu.RLock()
+	defer precisionMu.RUnlock()
+	if p, ok := precisions[currency]; ok {
+		return p
+	}
+	return DefaultPrecision
+}
+
+// RegisterCurrency sets the minor-unit precision for a currency code.
+func RegisterCurrency(currency string, precision int) {
+	precisionMu.Lock()
+	defer precisionMu.Unlock()
+	precisions[currency] = precision
+}
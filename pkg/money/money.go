@@ -0,0 +1,358 @@
+package money
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RoundingMode selects how a value is rounded.
+type RoundingMode int
+
+const (
+	// RoundHalfEven rounds ties to the nearest even digit (banker's rounding).
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp rounds ties away from zero.
+	RoundHalfUp
+	// RoundDown truncates towards zero.
+	RoundDown
+)
+
+// Money is an exact decimal amount in a currency. The value is stored as an
+// arbitrary-precision integer of units scaled by 10^-scale, so "25000.50" is
+// 2500050 with scale 2. The zero value is zero with no currency.
+//
+// An empty currency is treated as "not yet known" (for example an amount decoded
+// from JSON where the currency lives on the parent voucher) and is compatible
+// with any other currency in arithmetic.
+type Money struct {
+	units    *big.Int
+	scale    int
+	currency string
+}
+
+// ErrCurrencyMismatch is returned when combining amounts in different currencies.
+type ErrCurrencyMismatch struct {
+	A, B string
+}
+
+func (e *ErrCurrencyMismatch) Error() string {
+	return fmt.Sprintf("currency mismatch: %s and %s", e.A, e.B)
+}
+
+var decimalPattern = regexp.MustCompile(`^[+-]?[0-9]+(\.[0-9]+)?$`)
+
+// Parse parses a plain decimal string such as "1500", "-12.5" or "0.075".
+// The scale of the string is preserved, so String returns the same text.
+func Parse(s, currency string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if !decimalPattern.MatchString(s) {
+		return Money{}, fmt.Errorf("invalid decimal amount %q", s)
+	}
+
+	digits, scale := s, 0
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		scale = len(s) - i - 1
+		digits = s[:i] + s[i+1:]
+	}
+
+	units, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Money{}, fmt.Errorf("invalid decimal amount %q", s)
+	}
+	return Money{units: units, scale: scale, currency: currency}, nil
+}
+
+// MustParse is like Parse but panics on error. Intended for constants and tests.
+func MustParse(s, currency string) Money {
+	m, err := Parse(s, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// FromMinor builds an amount from minor units, e.g. FromMinor(150, "NPR") is 1.50 NPR.
+func FromMinor(minor int64, currency string) Money {
+	return Money{units: big.NewInt(minor), scale: Precision(currency), currency: currency}
+}
+
+// Zero returns zero in the given currency at the currency's precision.
+func Zero(currency string) Money {
+	return FromMinor(0, currency)
+}
+
+// Currency returns the ISO currency code, which may be empty.
+func (m Money) Currency() string { return m.currency }
+
+// Scale returns the number of fraction digits the amount carries.
+func (m Money) Scale() int { return m.scale }
+
+// WithCurrency returns the same amount tagged with a currency code.
+func (m Money) WithCurrency(currency string) Money {
+	m.currency = currency
+	return m
+}
+
+func (m Money) int() *big.Int {
+	if m.units == nil {
+		return new(big.Int)
+	}
+	return m.units
+}
+
+// Sign returns -1, 0 or +1.
+func (m Money) Sign() int { return m.int().Sign() }
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool { return m.Sign() == 0 }
+
+// Neg returns -m.
+func (m Money) Neg() Money {
+	return Money{units: new(big.Int).Neg(m.int()), scale: m.scale, currency: m.currency}
+}
+
+// Abs returns |m|.
+func (m Money) Abs() Money {
+	return Money{units: new(big.Int).Abs(m.int()), scale: m.scale, currency: m.currency}
+}
+
+// Rat returns the exact value as a rational number.
+func (m Money) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(m.int(), pow10(m.scale))
+}
+
+func (m Money) rescaled(scale int) *big.Int {
+	if scale <= m.scale {
+		return new(big.Int).Set(m.int())
+	}
+	return new(big.Int).Mul(m.int(), pow10(scale-m.scale))
+}
+
+func mergeCurrency(a, b Money) (string, error) {
+	switch {
+	case a.currency == "":
+		return b.currency, nil
+	case b.currency == "" || a.currency == b.currency:
+		return a.currency, nil
+	default:
+		return "", &ErrCurrencyMismatch{A: a.currency, B: b.currency}
+	}
+}
+
+// Add returns m + o. The result carries the larger of the two scales.
+func (m Money) Add(o Money) (Money, error) {
+	currency, err := mergeCurrency(m, o)
+	if err != nil {
+		return Money{}, err
+	}
+	scale := max(m.scale, o.scale)
+	units := new(big.Int).Add(m.rescaled(scale), o.rescaled(scale))
+	return Money{units: units, scale: scale, currency: currency}, nil
+}
+
+// Sub returns m - o.
+func (m Money) Sub(o Money) (Money, error) {
+	return m.Add(o.Neg())
+}
+
+// MulInt returns m * n.
+func (m Money) MulInt(n int64) Money {
+	return Money{units: new(big.Int).Mul(m.int(), big.NewInt(n)), scale: m.scale, currency: m.currency}
+}
+
+// MulRat returns m * r rounded to places fraction digits.
+func (m Money) MulRat(r *big.Rat, places int, mode RoundingMode) Money {
+	num := new(big.Int).Mul(m.int(), r.Num())
+	num.Mul(num, pow10(places))
+	den := new(big.Int).Mul(r.Denom(), pow10(m.scale))
+	return Money{units: roundQuo(num, den, mode), scale: places, currency: m.currency}
+}
+
+// Cmp compares m and o and returns -1, 0 or +1. Scales need not match.
+func (m Money) Cmp(o Money) (int, error) {
+	if _, err := mergeCurrency(m, o); err != nil {
+		return 0, err
+	}
+	scale := max(m.scale, o.scale)
+	return m.rescaled(scale).Cmp(o.rescaled(scale)), nil
+}
+
+// Equal reports whether m and o are the same amount in compatible currencies.
+// "1.5" and "1.50" are equal.
+func (m Money) Equal(o Money) bool {
+	c, err := m.Cmp(o)
+	return err == nil && c == 0
+}
+
+// Round returns m rounded to places fraction digits. If places is larger than
+// the current scale the value is padded with zeros.
+func (m Money) Round(places int, mode RoundingMode) Money {
+	if places >= m.scale {
+		return Money{units: m.rescaled(places), scale: places, currency: m.currency}
+	}
+	units := roundQuo(m.int(), pow10(m.scale-places), mode)
+	return Money{units: units, scale: places, currency: m.currency}
+}
+
+// RoundToCurrency rounds m to its currency's precision.
+func (m Money) RoundToCurrency(mode RoundingMode) Money {
+	return m.Round(Precision(m.currency), mode)
+}
+
+// Allocate splits m into parts proportional to ratios without losing any minor
+// unit. Units left over after truncation go to the parts with the largest
+// remainders; ties go to the earliest part, so the result is deterministic.
+func (m Money) Allocate(ratios ...int64) ([]Money, error) {
+	if len(ratios) == 0 {
+		return nil, fmt.Errorf("allocate: no ratios given")
+	}
+	total := new(big.Int)
+	for _, r := range ratios {
+		if r < 0 {
+			return nil, fmt.Errorf("allocate: negative ratio %d", r)
+		}
+		total.Add(total, big.NewInt(r))
+	}
+	if total.Sign() == 0 {
+		return nil, fmt.Errorf("allocate: ratios sum to zero")
+	}
+
+	scale := max(m.scale, Precision(m.currency))
+	amount := m.rescaled(scale)
+	negative := amount.Sign() < 0
+	amount.Abs(amount)
+
+	shares := make([]*big.Int, len(ratios))
+	remainders := make([]*big.Int, len(ratios))
+	allocated := new(big.Int)
+	for i, r := range ratios {
+		share, rem := new(big.Int).QuoRem(new(big.Int).Mul(amount, big.NewInt(r)), total, new(big.Int))
+		shares[i], remainders[i] = share, rem
+		allocated.Add(allocated, share)
+	}
+
+	order := make([]int, len(ratios))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]].Cmp(remainders[order[b]]) > 0
+	})
+
+	left := new(big.Int).Sub(amount, allocated).Int64()
+	for i := int64(0); i < left; i++ {
+		shares[order[i]].Add(shares[order[i]], big.NewInt(1))
+	}
+
+	parts := make([]Money, len(shares))
+	for i, share := range shares {
+		if negative {
+			share.Neg(share)
+		}
+		parts[i] = Money{units: share, scale: scale, currency: m.currency}
+	}
+	return parts, nil
+}
+
+// Split divides m into n near-equal parts; see Allocate.
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("split: n must be positive, got %d", n)
+	}
+	ratios := make([]int64, n)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	return m.Allocate(ratios...)
+}
+
+// Sum adds up amounts that share a currency.
+func Sum(amounts ...Money) (Money, error) {
+	var total Money
+	for _, a := range amounts {
+		var err error
+		if total, err = total.Add(a); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// String formats the amount as a plain decimal at its own scale, e.g. "25000.00".
+func (m Money) String() string {
+	digits := new(big.Int).Abs(m.int()).String()
+	if m.scale > 0 {
+		if len(digits) <= m.scale {
+			digits = strings.Repeat("0", m.scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-m.scale] + "." + digits[len(digits)-m.scale:]
+	}
+	if m.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// MarshalJSON encodes the amount as a quoted decimal string, which is the form
+// Tigg uses for amounts.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON accepts a quoted decimal string or a bare JSON number. The
+// currency is left unchanged.
+func (m *Money) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+	}
+	parsed, err := Parse(s, m.currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// roundQuo returns num/den rounded to an integer with the given mode.
+func roundQuo(num, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 || mode == RoundDown {
+		return q
+	}
+
+	twice := new(big.Int).Abs(r)
+	twice.Lsh(twice, 1)
+	c := twice.Cmp(new(big.Int).Abs(den))
+
+	roundAway := c > 0
+	if c == 0 {
+		switch mode {
+		case RoundHalfUp:
+			roundAway = true
+		case RoundHalfEven:
+			roundAway = q.Bit(0) == 1
+		}
+	}
+	if roundAway {
+		if (num.Sign() < 0) != (den.Sign() < 0) {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,152 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestParseAndString(t *testing.T) {
+	for _, s := range []string{"0", "25000.00", "-12.5", "0.075", "1000000000000000000000.01"} {
+		m, err := Parse(s, "NPR")
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", s, err)
+		}
+		if m.String() != s {
+			t.Errorf("Parse(%q).String() = %q", s, m.String())
+		}
+	}
+	for _, s := range []string{"", "1e3", "12.", ".5", "1,000"} {
+		if _, err := Parse(s, "NPR"); err == nil {
+			t.Errorf("Parse(%q) expected error", s)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a := MustParse("0.10", "NPR")
+	b := MustParse("0.2", "NPR")
+
+	sum, err := a.Add(b)
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if sum.String() != "0.30" {
+		t.Fatalf("0.10 + 0.2 = %s, expected 0.30", sum)
+	}
+
+	diff, _ := b.Sub(MustParse("0.35", "NPR"))
+	if diff.String() != "-0.15" {
+		t.Fatalf("0.2 - 0.35 = %s, expected -0.15", diff)
+	}
+
+	if !MustParse("1.5", "NPR").Equal(MustParse("1.50", "NPR")) {
+		t.Fatal("expected 1.5 to equal 1.50")
+	}
+
+	var mismatch *ErrCurrencyMismatch
+	if _, err := a.Add(MustParse("1", "USD")); !errors.As(err, &mismatch) {
+		t.Fatalf("expected currency mismatch, got %v", err)
+	}
+	if _, err := a.Add(MustParse("1", "")); err != nil {
+		t.Fatalf("expected untagged amount to be compatible, got %v", err)
+	}
+}
+
+func TestRound(t *testing.T) {
+	cases := []struct {
+		in   string
+		mode RoundingMode
+		want string
+	}{
+		{"2.345", RoundHalfEven, "2.34"},
+		{"2.355", RoundHalfEven, "2.36"},
+		{"2.345", RoundHalfUp, "2.35"},
+		{"-2.345", RoundHalfUp, "-2.35"},
+		{"-2.345", RoundHalfEven, "-2.34"},
+		{"2.3449", RoundHalfUp, "2.34"},
+		{"7", RoundHalfUp, "7.00"},
+		{"2.349", RoundDown, "2.34"},
+		{"-2.349", RoundDown, "-2.34"},
+	}
+	for _, c := range cases {
+		got := MustParse(c.in, "NPR").Round(2, c.mode).String()
+		if got != c.want {
+			t.Errorf("Round(%s, %d) = %s, expected %s", c.in, c.mode, got, c.want)
+		}
+	}
+
+	converted := MustParse("100.00", "USD").MulRat(big.NewRat(133245, 1000), 2, RoundHalfEven)
+	if converted.String() != "13324.50" {
+		t.Fatalf("MulRat = %s, expected 13324.50", converted)
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	parts, err := MustParse("100.00", "NPR").Allocate(1, 1, 1)
+	if err != nil {
+		t.Fatalf("Allocate failed: %v", err)
+	}
+	want := []string{"33.34", "33.33", "33.33"}
+	for i, p := range parts {
+		if p.String() != want[i] {
+			t.Errorf("part %d = %s, expected %s", i, p, want[i])
+		}
+	}
+
+	parts, _ = MustParse("-0.05", "NPR").Allocate(70, 30)
+	total, _ := Sum(parts...)
+	if total.String() != "-0.05" {
+		t.Fatalf("allocated parts sum to %s, expected -0.05", total)
+	}
+
+	if _, err := MustParse("1", "NPR").Allocate(0, 0); err == nil {
+		t.Fatal("expected error for zero ratios")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	type item struct {
+		Amount Money `json:"amount"`
+	}
+
+	in := []byte(`{"amount":"25000.50"}`)
+	var it item
+	if err := json.Unmarshal(in, &it); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	out, err := json.Marshal(it)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(out) != string(in) {
+		t.Fatalf("round trip changed bytes: %s -> %s", in, out)
+	}
+
+	if err := json.Unmarshal([]byte(`{"amount":12.5}`), &it); err != nil {
+		t.Fatalf("Unmarshal of bare number failed: %v", err)
+	}
+	if it.Amount.String() != "12.5" {
+		t.Fatalf("expected 12.5, got %s", it.Amount)
+	}
+}
+
+func TestRate(t *testing.T) {
+	r := MustParseRate("1.6")
+	inr := MustParse("1000.00", "INR")
+	if got := r.Convert(inr, "NPR", RoundHalfEven); got.String() != "1600.00" || got.Currency() != "NPR" {
+		t.Fatalf("Convert = %s %s, expected 1600.00 NPR", got, got.Currency())
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil || string(b) != `"1.6"` {
+		t.Fatalf("Marshal = %s, %v", b, err)
+	}
+
+	for _, s := range []string{"0", "-1.2", "abc"} {
+		if _, err := ParseRate(s); err == nil {
+			t.Errorf("ParseRate(%q) expected error", s)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package money
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Rate is an exact, positive exchange rate: the number of units of a target
+// currency per unit of a source currency. Which currencies those are is up to
+// the caller; a journal voucher records them as its own and its base currency.
+type Rate struct {
+	value *big.Rat
+	text  string
+}
+
+// ParseRate parses a positive decimal rate such as "133.245".
+func ParseRate(s string) (Rate, error) {
+	m, err := Parse(s, "")
+	if err != nil {
+		return Rate{}, fmt.Errorf("invalid exchange rate %q", s)
+	}
+	if m.Sign() <= 0 {
+		return Rate{}, fmt.Errorf("exchange rate must be positive, got %q", s)
+	}
+	return Rate{value: m.Rat(), text: m.String()}, nil
+}
+
+// MustParseRate is like ParseRate but panics on error.
+func MustParseRate(s string) Rate {
+	r, err := ParseRate(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// IsZero reports whether the rate is unset.
+func (r Rate) IsZero() bool { return r.value == nil }
+
+// Rat returns the rate as a rational number.
+func (r Rate) Rat() *big.Rat {
+	if r.value == nil {
+		return new(big.Rat)
+	}
+	return new(big.Rat).Set(r.value)
+}
+
+// Convert multiplies m by the rate and rounds the result to the precision of
+// the target currency.
+func (r Rate) Convert(m Money, to string, mode RoundingMode) Money {
+	return m.MulRat(r.Rat(), Precision(to), mode).WithCurrency(to)
+}
+
+// String returns the rate as it was parsed.
+func (r Rate) String() string {
+	if r.value == nil {
+		return "0"
+	}
+	return r.text
+}
+
+// MarshalJSON encodes the rate as a quoted decimal string.
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON accepts a quoted decimal string or a bare JSON number.
+func (r *Rate) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+	}
+	parsed, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
@@ -0,0 +1,234 @@
+package opening
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/accountgroup"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+	"github.com/rohankarmacharya/TigIntegration/pkg/period"
+)
+
+// AccountService lists and creates accounts. *account.Service satisfies it.
+type AccountService interface {
+	journal.AccountLister
+	CreateAccount(acc account.Account) (*account.Account, error)
+}
+
+// GroupLister lists account groups. *accountgroup.Service satisfies it.
+type GroupLister interface {
+	ListAccountGroups() ([]accountgroup.AccountGroup, error)
+}
+
+// VoucherPoster lists, creates and posts journal vouchers. *journal.Service
+// satisfies it.
+type VoucherPoster interface {
+	ListJournalVouchers() ([]journal.JournalVoucher, error)
+	CreateJournalVoucher(jv journal.JournalVoucher) (*journal.JournalVoucher, error)
+	PostVoucher(jv journal.JournalVoucher) (*journal.JournalVoucher, error)
+}
+
+// Result records what Generate created.
+type Result struct {
+	CreatedAccounts []account.Account
+	Voucher         *journal.JournalVoucher
+}
+
+// Generator turns a trial balance into a posted opening voucher.
+type Generator struct {
+	accounts AccountService
+	groups   GroupLister
+	vouchers VoucherPoster
+
+	// EquityAccountCode is the opening-balance equity account that receives
+	// any difference between debits and credits. When empty the trial balance
+	// must balance exactly.
+	EquityAccountCode string
+	// VoucherCode is the code of the opening voucher.
+	VoucherCode string
+}
+
+func NewGenerator(accounts AccountService, groups GroupLister, vouchers VoucherPoster) *Generator {
+	return &Generator{accounts: accounts, groups: groups, vouchers: vouchers, VoucherCode: "OPENING"}
+}
+
+// Generate validates tb against the chart of accounts, creates accounts that
+// are missing under the group named on their row, and posts one voucher dated
+// the first day of p. The voucher is built and validated against the chart as
+// it will be once the missing accounts exist, so nothing is written unless
+// every row is valid. If a later step fails, the returned Result lists what
+// was created.
+//
+// Generate can be run again after a failure: a draft voucher with
+// g.VoucherCode left by an earlier run is posted as it is, and a posted one
+// is refused.
+func (g *Generator) Generate(ctx context.Context, tb *TrialBalance, p period.Period) (*Result, error) {
+	existing, err := g.existingVoucher()
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if existing.VoucherStatus != journal.StatusDraft {
+			return nil, fmt.Errorf("opening voucher %s is already %s", g.VoucherCode, existing.VoucherStatus)
+		}
+		posted, err := g.vouchers.PostVoucher(*existing)
+		if err != nil {
+			return &Result{Voucher: existing}, err
+		}
+		return &Result{Voucher: posted}, nil
+	}
+
+	diff := tb.Difference()
+	if !diff.IsZero() && g.EquityAccountCode == "" {
+		debit, credit := tb.Totals()
+		return nil, fmt.Errorf("trial balance does not balance: debits %s, credits %s", debit, credit)
+	}
+
+	chart, missing, err := g.missingAccounts(tb)
+	if err != nil {
+		return nil, err
+	}
+	planned := append([]account.Account(nil), chart...)
+	for _, acc := range missing {
+		acc.ID = "planned:" + acc.Code
+		planned = append(planned, acc)
+	}
+	if _, err := g.voucher(ctx, plannedChart(planned), tb, p); err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+	for _, acc := range missing {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		created, err := g.accounts.CreateAccount(acc)
+		if err != nil {
+			return res, fmt.Errorf("create account %s: %w", acc.Code, err)
+		}
+		res.CreatedAccounts = append(res.CreatedAccounts, *created)
+	}
+
+	jv, err := g.voucher(ctx, g.accounts, tb, p)
+	if err != nil {
+		return res, err
+	}
+	created, err := g.vouchers.CreateJournalVoucher(*jv)
+	if err != nil {
+		return res, err
+	}
+	res.Voucher = created
+	posted, err := g.vouchers.PostVoucher(*created)
+	if err != nil {
+		return res, err
+	}
+	res.Voucher = posted
+	return res, nil
+}
+
+// existingVoucher returns the voucher with g.VoucherCode that is not voided,
+// or nil.
+func (g *Generator) existingVoucher() (*journal.JournalVoucher, error) {
+	list, err := g.vouchers.ListJournalVouchers()
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if list[i].Code == g.VoucherCode && list[i].VoucherStatus != journal.StatusVoided {
+			return &list[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// plannedChart lists a fixed set of accounts.
+type plannedChart []account.Account
+
+func (c plannedChart) ListAccounts() ([]account.Account, error) {
+	return c, nil
+}
+
+// voucher builds the opening voucher for tb, resolving codes through accounts.
+func (g *Generator) voucher(ctx context.Context, accounts journal.AccountLister, tb *TrialBalance, p period.Period) (*journal.JournalVoucher, error) {
+	b := journal.NewBuilder(accounts, g.VoucherCode, p.Start, tb.Currency).
+		Narration(fmt.Sprintf("Opening balances as at %s", p.Start))
+	for _, l := range tb.Lines {
+		net := l.Net()
+		switch net.Sign() {
+		case 1:
+			b.Debit(l.AccountCode, net, "")
+		case -1:
+			b.Credit(l.AccountCode, net.Abs(), "")
+		}
+	}
+	diff := tb.Difference()
+	switch diff.Sign() {
+	case 1:
+		b.Credit(g.EquityAccountCode, diff, "Opening balance difference")
+	case -1:
+		b.Debit(g.EquityAccountCode, diff.Abs(), "Opening balance difference")
+	}
+	return b.Build(ctx)
+}
+
+// missingAccounts returns the chart of accounts and the accounts to create for
+// rows whose code is not in it. Rows that cannot be created, and a missing
+// equity account, are reported together.
+func (g *Generator) missingAccounts(tb *TrialBalance) (chart, missing []account.Account, err error) {
+	accounts, err := g.accounts.ListAccounts()
+	if err != nil {
+		return nil, nil, err
+	}
+	groups, err := g.groups.ListAccountGroups()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	existing := make(map[string]bool, len(accounts))
+	for _, acc := range accounts {
+		existing[acc.Code] = true
+	}
+	groupsByName := map[string][]accountgroup.AccountGroup{}
+	for _, grp := range groups {
+		key := strings.ToLower(strings.TrimSpace(grp.Name))
+		groupsByName[key] = append(groupsByName[key], grp)
+	}
+
+	var errs RowErrors
+	if g.EquityAccountCode != "" && !existing[g.EquityAccountCode] {
+		errs = append(errs, fmt.Sprintf("opening balance equity account %s not found", g.EquityAccountCode))
+	}
+
+	for _, l := range tb.Lines {
+		if existing[l.AccountCode] {
+			continue
+		}
+		if l.AccountName == "" || l.Group == "" {
+			errs = append(errs, fmt.Sprintf("row %d: account %s not found; account_name and group are needed to create it", l.Row, l.AccountCode))
+			continue
+		}
+		matches := groupsByName[strings.ToLower(l.Group)]
+		switch len(matches) {
+		case 0:
+			errs = append(errs, fmt.Sprintf("row %d: account group %q not found", l.Row, l.Group))
+			continue
+		case 1:
+		default:
+			errs = append(errs, fmt.Sprintf("row %d: account group name %q is ambiguous", l.Row, l.Group))
+			continue
+		}
+		grp := matches[0]
+		missing = append(missing, account.Account{
+			Code:            l.AccountCode,
+			Name:            l.AccountName,
+			ParentGroupID:   &grp.ID,
+			ParentGroupName: &grp.Name,
+		})
+	}
+	if len(errs) > 0 {
+		return nil, nil, errs
+	}
+	return accounts, missing, nil
+}
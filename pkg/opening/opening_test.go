@@ -0,0 +1,239 @@
+package opening
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/accountgroup"
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+	"github.com/rohankarmacharya/TigIntegration/pkg/period"
+	"github.com/rohankarmacharya/TigIntegration/pkg/voucherimport"
+)
+
+type stubBooks struct {
+	accounts []account.Account
+	vouchers []journal.JournalVoucher
+	postErr  error
+}
+
+func (b *stubBooks) ListAccounts() ([]account.Account, error) { return b.accounts, nil }
+
+func (b *stubBooks) CreateAccount(acc account.Account) (*account.Account, error) {
+	acc.ID = fmt.Sprintf("acc-%d", len(b.accounts)+1)
+	b.accounts = append(b.accounts, acc)
+	return &acc, nil
+}
+
+func (b *stubBooks) ListAccountGroups() ([]accountgroup.AccountGroup, error) {
+	return []accountgroup.AccountGroup{{ID: "g-bank", Name: "Bank Accounts"}, {ID: "g-opex", Name: "Operating Expenses"}}, nil
+}
+
+func (b *stubBooks) CreateJournalVoucher(jv journal.JournalVoucher) (*journal.JournalVoucher, error) {
+	jv.ID = fmt.Sprintf("jv-%d", len(b.vouchers)+1)
+	jv.VoucherStatus = journal.StatusDraft
+	b.vouchers = append(b.vouchers, jv)
+	return &jv, nil
+}
+
+func (b *stubBooks) ListJournalVouchers() ([]journal.JournalVoucher, error) {
+	return append([]journal.JournalVoucher(nil), b.vouchers...), nil
+}
+
+func (b *stubBooks) PostVoucher(jv journal.JournalVoucher) (*journal.JournalVoucher, error) {
+	if b.postErr != nil {
+		return nil, b.postErr
+	}
+	for i := range b.vouchers {
+		if b.vouchers[i].ID == jv.ID {
+			b.vouchers[i].VoucherStatus = journal.StatusPosted
+			return &b.vouchers[i], nil
+		}
+	}
+	return nil, fmt.Errorf("voucher %s not found", jv.ID)
+}
+
+func newBooks() *stubBooks {
+	return &stubBooks{accounts: []account.Account{
+		{ID: "acc-bank", Code: "BA0001", Name: "NIC Asia"},
+		{ID: "acc-cap", Code: "EQ0001", Name: "Share Capital"},
+		{ID: "acc-obe", Code: "EQ0900", Name: "Opening Balance Equity"},
+	}}
+}
+
+func readTB(t *testing.T, csv string) *TrialBalance {
+	t.Helper()
+	sheet, err := voucherimport.ReadCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("ReadCSV failed: %v", err)
+	}
+	tb, err := Read(sheet, "NPR")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	return tb
+}
+
+var shrawan = period.Period{
+	Start: calendar.MustParseDate("2024-07-16", calendar.AD),
+	End:   calendar.MustParseDate("2024-08-16", calendar.AD),
+}
+
+func TestRead(t *testing.T) {
+	tb := readTB(t, "Account_Code,Debit,Credit\nBA0001,\"1,500.00\",\nEQ0001,,1500.00\n")
+	if len(tb.Lines) != 2 || tb.Lines[0].Debit.String() != "1500.00" || !tb.Difference().IsZero() {
+		t.Fatalf("unexpected trial balance %+v", tb)
+	}
+
+	sheet, _ := voucherimport.ReadCSV(strings.NewReader("account_code,debit,credit\nBA0001,-5,\nBA0001,1,\n,x,\n"))
+	_, err := Read(sheet, "NPR")
+	errs, ok := err.(RowErrors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("expected three row errors, got %v", err)
+	}
+	if !strings.Contains(errs[1], "row 3: account BA0001 is already on row 2") {
+		t.Fatalf("unexpected duplicate error %q", errs[1])
+	}
+}
+
+func TestReadXLSXAmounts(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="TB" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+			`<row r="1"><c r="A1" t="inlineStr"><is><t>account_code</t></is></c><c r="B1" t="inlineStr"><is><t>debit</t></is></c><c r="C1" t="inlineStr"><is><t>credit</t></is></c></row>` +
+			`<row r="2"><c r="A2" t="inlineStr"><is><t>BA0001</t></is></c><c r="B2"><v>1234.5600000000001</v></c></row>` +
+			`<row r="3"><c r="A3" t="inlineStr"><is><t>EQ0001</t></is></c><c r="C3"><v>1234.56</v></c></row>` +
+			`</sheetData></worksheet>`,
+	} {
+		fw, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sheet, err := voucherimport.ReadXLSX(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("ReadXLSX failed: %v", err)
+	}
+	tb, err := Read(sheet, "NPR")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if tb.Lines[0].Debit.String() != "1234.56" || !tb.Difference().IsZero() {
+		t.Fatalf("expected float noise to be rounded away, got %s", tb.Lines[0].Debit)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	books := newBooks()
+	tb := readTB(t, `account_code,account_name,group,debit,credit
+BA0001,,,100000.00,
+EX0100,Office Rent,operating expenses,25000.00,
+EQ0001,,,,120000.00
+`)
+	gen := NewGenerator(books, books, books)
+	if _, err := gen.Generate(context.Background(), tb, shrawan); err == nil || !strings.Contains(err.Error(), "does not balance") {
+		t.Fatalf("expected an unbalanced trial balance to be rejected, got %v", err)
+	}
+	if len(books.accounts) != 3 {
+		t.Fatal("expected nothing to be created for a rejected trial balance")
+	}
+
+	gen.EquityAccountCode = "EQ0900"
+	res, err := gen.Generate(context.Background(), tb, shrawan)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(res.CreatedAccounts) != 1 || *res.CreatedAccounts[0].ParentGroupID != "g-opex" {
+		t.Fatalf("unexpected created accounts %+v", res.CreatedAccounts)
+	}
+
+	jv := res.Voucher
+	if jv.VoucherStatus != journal.StatusPosted || jv.Date.String() != "2024-07-16" || jv.Code != "OPENING" {
+		t.Fatalf("unexpected opening voucher %+v", jv)
+	}
+	last := jv.Items[len(jv.Items)-1]
+	if len(jv.Items) != 4 || last.AccountID != "acc-obe" || last.TxnType != journal.TxnTypeCredit || last.Amount.String() != "5000.00" {
+		t.Fatalf("expected the 5000.00 difference credited to equity, got %+v", jv.Items)
+	}
+}
+
+func TestGenerateReportsMissingAccounts(t *testing.T) {
+	books := newBooks()
+	tb := readTB(t, `account_code,account_name,group,debit,credit
+EX0100,,,10.00,
+EX0101,Water,Utilities,,10.00
+`)
+	gen := NewGenerator(books, books, books)
+	gen.EquityAccountCode = "EQ9999"
+	_, err := gen.Generate(context.Background(), tb, shrawan)
+	errs, ok := err.(RowErrors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("expected equity and both rows to be reported, got %v", err)
+	}
+	if len(books.accounts) != 3 || len(books.vouchers) != 0 {
+		t.Fatal("expected nothing to be written")
+	}
+}
+
+func TestGenerateValidatesVoucherBeforeCreatingAccounts(t *testing.T) {
+	books := newBooks()
+	books.accounts[1].Inactive = true
+	tb := readTB(t, `account_code,account_name,group,debit,credit
+EX0100,Office Rent,operating expenses,25000.00,
+EQ0001,,,,25000.00
+`)
+	_, err := NewGenerator(books, books, books).Generate(context.Background(), tb, shrawan)
+	if err == nil || !strings.Contains(err.Error(), "inactive") {
+		t.Fatalf("expected the inactive account to be reported, got %v", err)
+	}
+	if len(books.accounts) != 3 || len(books.vouchers) != 0 {
+		t.Fatal("expected no account to be created for an invalid voucher")
+	}
+}
+
+func TestGenerateRerun(t *testing.T) {
+	books := newBooks()
+	tb := readTB(t, `account_code,account_name,group,debit,credit
+EX0100,Office Rent,operating expenses,25000.00,
+EQ0001,,,,25000.00
+`)
+	gen := NewGenerator(books, books, books)
+	books.postErr = errors.New("server unavailable")
+	if _, err := gen.Generate(context.Background(), tb, shrawan); err == nil {
+		t.Fatal("expected the failed post to be reported")
+	}
+
+	books.postErr = nil
+	res, err := gen.Generate(context.Background(), tb, shrawan)
+	if err != nil {
+		t.Fatalf("rerun failed: %v", err)
+	}
+	if res.Voucher.ID != "jv-1" || res.Voucher.VoucherStatus != journal.StatusPosted || len(res.CreatedAccounts) != 0 {
+		t.Fatalf("expected the draft to be posted as it is, got %+v", res)
+	}
+	if len(books.accounts) != 4 || len(books.vouchers) != 1 {
+		t.Fatal("expected the rerun to create nothing")
+	}
+
+	if _, err := gen.Generate(context.Background(), tb, shrawan); err == nil || !strings.Contains(err.Error(), "already POSTED") {
+		t.Fatalf("expected a posted opening voucher to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package opening
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+	"github.com/rohankarmacharya/TigIntegration/pkg/voucherimport"
+)
+
+// Trial balance columns, matched case-insensitively against the header row.
+// account_name and group are only needed for accounts that do not exist yet.
+const (
+	ColAccountCode = "account_code"
+	ColAccountName = "account_name"
+	ColGroup       = "group"
+	ColDebit       = "debit"
+	ColCredit      = "credit"
+)
+
+var requiredColumns = []string{ColAccountCode, ColDebit, ColCredit}
+
+// Line is one account of a trial balance. Row is the sheet row, counting the
+// header as row 1.
+type Line struct {
+	Row         int
+	AccountCode string
+	AccountName string
+	Group       string
+	Debit       money.Money
+	Credit      money.Money
+}
+
+// Net returns Debit - Credit.
+func (l Line) Net() money.Money {
+	net, _ := l.Debit.Sub(l.Credit)
+	return net
+}
+
+// TrialBalance is a list of account balances in one currency.
+type TrialBalance struct {
+	Currency string
+	Lines    []Line
+}
+
+// Totals returns the total debits and credits.
+func (tb *TrialBalance) Totals() (debit, credit money.Money) {
+	debit, credit = money.Zero(tb.Currency), money.Zero(tb.Currency)
+	for _, l := range tb.Lines {
+		debit, _ = debit.Add(l.Debit)
+		credit, _ = credit.Add(l.Credit)
+	}
+	return debit, credit
+}
+
+// Difference returns total debits minus total credits.
+func (tb *TrialBalance) Difference() money.Money {
+	debit, credit := tb.Totals()
+	diff, _ := debit.Sub(credit)
+	return diff
+}
+
+// RowErrors lists every problem found in a trial balance sheet.
+type RowErrors []string
+
+func (e RowErrors) Error() string {
+	return fmt.Sprintf("invalid trial balance: %s", strings.Join(e, "; "))
+}
+
+// ReadFile reads a CSV or XLSX trial balance.
+func ReadFile(path, currency string) (*TrialBalance, error) {
+	sheet, err := voucherimport.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Read(sheet, currency)
+}
+
+// Read parses a trial balance sheet. Amounts may use thousands separators and
+// empty cells count as zero. Every row problem is reported together as
+// RowErrors.
+func Read(sheet *voucherimport.Sheet, currency string) (*TrialBalance, error) {
+	for _, c := range requiredColumns {
+		if sheet.Column(c) < 0 {
+			return nil, fmt.Errorf("trial balance is missing column %q", c)
+		}
+	}
+	codeCol, nameCol, groupCol := sheet.Column(ColAccountCode), sheet.Column(ColAccountName), sheet.Column(ColGroup)
+	debitCol, creditCol := sheet.Column(ColDebit), sheet.Column(ColCredit)
+
+	tb := &TrialBalance{Currency: currency}
+	var errs RowErrors
+	seen := map[string]int{}
+	for i, row := range sheet.Rows {
+		n := i + 2
+		l := Line{
+			Row:         n,
+			AccountCode: sheet.Cell(i, codeCol),
+			AccountName: sheet.Cell(i, nameCol),
+			Group:       sheet.Cell(i, groupCol),
+		}
+		if l.AccountCode == "" {
+			if strings.TrimSpace(strings.Join(row, "")) != "" {
+				errs = append(errs, fmt.Sprintf("row %d: account_code is required", n))
+			}
+			continue
+		}
+		if first, ok := seen[l.AccountCode]; ok {
+			errs = append(errs, fmt.Sprintf("row %d: account %s is already on row %d", n, l.AccountCode, first))
+			continue
+		}
+		seen[l.AccountCode] = n
+
+		var err error
+		if l.Debit, err = parseAmount(sheet, i, debitCol, currency); err != nil {
+			errs = append(errs, fmt.Sprintf("row %d: debit: %v", n, err))
+		}
+		if l.Credit, err = parseAmount(sheet, i, creditCol, currency); err != nil {
+			errs = append(errs, fmt.Sprintf("row %d: credit: %v", n, err))
+		}
+		tb.Lines = append(tb.Lines, l)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return tb, nil
+}
+
+// parseAmount reads a non-negative amount, taking an empty cell as zero.
+func parseAmount(sheet *voucherimport.Sheet, row, col int, currency string) (money.Money, error) {
+	if sheet.Cell(row, col) == "" {
+		return money.Zero(currency), nil
+	}
+	m, err := sheet.Amount(row, col, currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	if m.Sign() < 0 {
+		return money.Money{}, fmt.Errorf("amount must not be negative, got %s", sheet.Cell(row, col))
+	}
+	return m, nil
+}
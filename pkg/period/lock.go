@@ -0,0 +1,179 @@
+package period
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/internal/jsonfile"
+)
+
+// Status is the lock state of a period.
+type Status string
+
+const (
+	// Open periods accept any voucher.
+	Open Status = "OPEN"
+	// SoftClosed periods reject vouchers unless the caller overrides the lock.
+	SoftClosed Status = "SOFT_CLOSED"
+	// HardClosed periods reject every voucher and cannot be reopened.
+	HardClosed Status = "HARD_CLOSED"
+)
+
+// Store persists period statuses by period key. Periods without a stored
+// status are Open.
+type Store interface {
+	GetStatus(key string) (Status, error)
+	PutStatus(key string, status Status) error
+}
+
+// MemoryStore keeps statuses in memory.
+type MemoryStore struct {
+	mu   sync.Mutex
+	data map[string]Status
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: map[string]Status{}}
+}
+
+func (s *MemoryStore) GetStatus(key string) (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st, ok := s.data[key]; ok {
+		return st, nil
+	}
+	return Open, nil
+}
+
+func (s *MemoryStore) PutStatus(key string, status Status) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = status
+	return nil
+}
+
+// FileStore persists statuses in a JSON file mapping period keys to statuses,
+// so closings survive a restart.
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (s *FileStore) load() (map[string]Status, error) {
+	data := map[string]Status{}
+	if err := jsonfile.Read(s.path, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *FileStore) GetStatus(key string) (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	if st, ok := data[key]; ok {
+		return st, nil
+	}
+	return Open, nil
+}
+
+func (s *FileStore) PutStatus(key string, status Status) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := s.load()
+	if err != nil {
+		return err
+	}
+	data[key] = status
+	return jsonfile.Write(s.path, data)
+}
+
+// ClosedError is returned when a voucher date falls in a closed period.
+type ClosedError struct {
+	Date   calendar.Date
+	Period Period
+	Status Status
+}
+
+func (e *ClosedError) Error() string {
+	return fmt.Sprintf("date %s is in period %s which is %s", e.Date, e.Period, e.Status)
+}
+
+// Locker tracks the status of the periods of one fiscal year configuration.
+type Locker struct {
+	config      Config
+	granularity Granularity
+	store       Store
+}
+
+func NewLocker(cfg Config, g Granularity, store Store) *Locker {
+	return &Locker{config: cfg, granularity: g, store: store}
+}
+
+// PeriodOf returns the period containing d.
+func (l *Locker) PeriodOf(d calendar.Date) (Period, error) {
+	return l.config.PeriodOf(d, l.granularity)
+}
+
+// Status returns the status of the period containing d.
+func (l *Locker) Status(d calendar.Date) (Period, Status, error) {
+	p, err := l.PeriodOf(d)
+	if err != nil {
+		return Period{}, "", err
+	}
+	st, err := l.store.GetStatus(p.Key())
+	if err != nil {
+		return Period{}, "", err
+	}
+	return p, st, nil
+}
+
+// SoftClose closes the period containing d; vouchers need an override.
+func (l *Locker) SoftClose(d calendar.Date) (Period, error) {
+	return l.setStatus(d, SoftClosed)
+}
+
+// HardClose closes the period containing d for good.
+func (l *Locker) HardClose(d calendar.Date) (Period, error) {
+	return l.setStatus(d, HardClosed)
+}
+
+// Reopen opens a soft-closed period again.
+func (l *Locker) Reopen(d calendar.Date) (Period, error) {
+	return l.setStatus(d, Open)
+}
+
+func (l *Locker) setStatus(d calendar.Date, to Status) (Period, error) {
+	p, from, err := l.Status(d)
+	if err != nil {
+		return Period{}, err
+	}
+	if from == HardClosed && to != HardClosed {
+		return Period{}, fmt.Errorf("period %s is %s and cannot be changed to %s", p.Key(), from, to)
+	}
+	if err := l.store.PutStatus(p.Key(), to); err != nil {
+		return Period{}, err
+	}
+	return p, nil
+}
+
+// CheckDate returns a *ClosedError if d is in a closed period. override lets
+// a soft-closed period through; hard-closed periods are never overridden.
+func (l *Locker) CheckDate(d calendar.Date, override bool) error {
+	p, st, err := l.Status(d)
+	if err != nil {
+		return err
+	}
+	if st == Open || (st == SoftClosed && override) {
+		return nil
+	}
+	return &ClosedError{Date: d, Period: p, Status: st}
+}
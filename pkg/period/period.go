@@ -0,0 +1,192 @@
+package period
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+)
+
+// Config describes when a fiscal year starts.
+type Config struct {
+	// System is the calendar the fiscal year is defined in.
+	System calendar.System
+	// StartMonth is the first month of the fiscal year in System (1-12).
+	StartMonth int
+}
+
+var (
+	// NepaliFiscalYear runs from 1 Shrawan to the end of Asar in BS.
+	NepaliFiscalYear = Config{System: calendar.BS, StartMonth: calendar.Shrawan}
+	// CalendarYear runs from 1 January to 31 December in AD.
+	CalendarYear = Config{System: calendar.AD, StartMonth: 1}
+)
+
+func (c Config) validate() error {
+	if c.StartMonth < 1 || c.StartMonth > 12 {
+		return fmt.Errorf("fiscal year start month must be 1-12, got %d", c.StartMonth)
+	}
+	return nil
+}
+
+// monthStart returns the first day of a month in c.System. month may be
+// outside 1-12 and rolls over into neighbouring years.
+func (c Config) monthStart(year, month int) (calendar.Date, error) {
+	index := year*12 + month - 1
+	year, month = index/12, index%12+1
+	if c.System == calendar.BS {
+		return calendar.FromBS(calendar.BSDate{Year: year, Month: month, Day: 1})
+	}
+	return calendar.NewDate(time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)), nil
+}
+
+// yearMonth returns the year and month of d in c.System.
+func (c Config) yearMonth(d calendar.Date) (int, int, error) {
+	if c.System == calendar.BS {
+		bs, err := d.BS()
+		if err != nil {
+			return 0, 0, err
+		}
+		return bs.Year, bs.Month, nil
+	}
+	t := d.Time()
+	return t.Year(), int(t.Month()), nil
+}
+
+// FiscalYear is one fiscal year. Year is the calendar year it starts in.
+type FiscalYear struct {
+	Config Config
+	Year   int
+	Start  calendar.Date
+	End    calendar.Date
+}
+
+// Label names the fiscal year, e.g. "2081/82" for a year spanning two calendar
+// years or "2024" for one that starts in January.
+func (fy FiscalYear) Label() string {
+	if fy.Config.StartMonth == 1 {
+		return fmt.Sprintf("%d", fy.Year)
+	}
+	return fmt.Sprintf("%d/%02d", fy.Year, (fy.Year+1)%100)
+}
+
+// FiscalYear returns the fiscal year that starts in the given calendar year.
+func (c Config) FiscalYear(year int) (FiscalYear, error) {
+	if err := c.validate(); err != nil {
+		return FiscalYear{}, err
+	}
+	start, err := c.monthStart(year, c.StartMonth)
+	if err != nil {
+		return FiscalYear{}, err
+	}
+	next, err := c.monthStart(year, c.StartMonth+12)
+	if err != nil {
+		return FiscalYear{}, err
+	}
+	return FiscalYear{Config: c, Year: year, Start: start.In(c.System), End: next.AddDays(-1).In(c.System)}, nil
+}
+
+// FiscalYearOf returns the fiscal year containing d.
+func (c Config) FiscalYearOf(d calendar.Date) (FiscalYear, error) {
+	if err := c.validate(); err != nil {
+		return FiscalYear{}, err
+	}
+	year, month, err := c.yearMonth(d)
+	if err != nil {
+		return FiscalYear{}, err
+	}
+	if month < c.StartMonth {
+		year--
+	}
+	return c.FiscalYear(year)
+}
+
+// Granularity is the length of an accounting period.
+type Granularity string
+
+const (
+	Monthly   Granularity = "MONTHLY"
+	Quarterly Granularity = "QUARTERLY"
+)
+
+func (g Granularity) months() (int, error) {
+	switch g {
+	case Monthly:
+		return 1, nil
+	case Quarterly:
+		return 3, nil
+	}
+	return 0, fmt.Errorf("unknown period granularity %q", g)
+}
+
+// Period is an accounting period within a fiscal year. Number counts from 1.
+type Period struct {
+	FiscalYear  FiscalYear
+	Granularity Granularity
+	Number      int
+	Start       calendar.Date
+	End         calendar.Date
+}
+
+// Key identifies the period, e.g. "2081/82-M01" or "2081/82-Q2".
+func (p Period) Key() string {
+	if p.Granularity == Quarterly {
+		return fmt.Sprintf("%s-Q%d", p.FiscalYear.Label(), p.Number)
+	}
+	return fmt.Sprintf("%s-M%02d", p.FiscalYear.Label(), p.Number)
+}
+
+// Contains reports whether d falls within the period.
+func (p Period) Contains(d calendar.Date) bool {
+	return !d.Before(p.Start) && !d.After(p.End)
+}
+
+func (p Period) String() string {
+	return fmt.Sprintf("%s (%s to %s)", p.Key(), p.Start, p.End)
+}
+
+// Periods splits the fiscal year into periods of the given granularity.
+func (fy FiscalYear) Periods(g Granularity) ([]Period, error) {
+	step, err := g.months()
+	if err != nil {
+		return nil, err
+	}
+	c := fy.Config
+	var out []Period
+	for offset := 0; offset < 12; offset += step {
+		start, err := c.monthStart(fy.Year, c.StartMonth+offset)
+		if err != nil {
+			return nil, err
+		}
+		next, err := c.monthStart(fy.Year, c.StartMonth+offset+step)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Period{
+			FiscalYear:  fy,
+			Granularity: g,
+			Number:      offset/step + 1,
+			Start:       start.In(c.System),
+			End:         next.AddDays(-1).In(c.System),
+		})
+	}
+	return out, nil
+}
+
+// PeriodOf returns the period of the given granularity containing d.
+func (c Config) PeriodOf(d calendar.Date, g Granularity) (Period, error) {
+	fy, err := c.FiscalYearOf(d)
+	if err != nil {
+		return Period{}, err
+	}
+	periods, err := fy.Periods(g)
+	if err != nil {
+		return Period{}, err
+	}
+	for _, p := range periods {
+		if p.Contains(d) {
+			return p, nil
+		}
+	}
+	return Period{}, fmt.Errorf("no %s period of %s contains %s", g, fy.Label(), d)
+}
@@ -0,0 +1,99 @@
+package period
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+)
+
+func TestNepaliFiscalYear(t *testing.T) {
+	fy, err := NepaliFiscalYear.FiscalYearOf(calendar.MustParseDate("2025-01-01", calendar.AD))
+	if err != nil {
+		t.Fatalf("FiscalYearOf failed: %v", err)
+	}
+	if fy.Label() != "2081/82" {
+		t.Fatalf("expected 2081/82, got %s", fy.Label())
+	}
+	if fy.Start.String() != "2081-04-01" || fy.Start.Format(calendar.AD) != "2024-07-16" {
+		t.Fatalf("unexpected start %s (%s AD)", fy.Start, fy.Start.Format(calendar.AD))
+	}
+	if fy.End.Format(calendar.AD) != "2025-07-16" {
+		t.Fatalf("unexpected end %s AD", fy.End.Format(calendar.AD))
+	}
+
+	// 1 Shrawan 2081 is the first day of 2081/82, the day before is in 2080/81.
+	prev, _ := NepaliFiscalYear.FiscalYearOf(calendar.MustParseDate("2024-07-15", calendar.AD))
+	if prev.Label() != "2080/81" {
+		t.Fatalf("expected 2080/81, got %s", prev.Label())
+	}
+
+	quarters, err := fy.Periods(Quarterly)
+	if err != nil {
+		t.Fatalf("Periods failed: %v", err)
+	}
+	if len(quarters) != 4 || quarters[0].End.String() != "2081-06-30" || quarters[3].Start.String() != "2082-01-01" {
+		t.Fatalf("unexpected quarters %v", quarters)
+	}
+	months, _ := fy.Periods(Monthly)
+	if len(months) != 12 || months[11].Key() != "2081/82-M12" || !months[11].End.Equal(fy.End) {
+		t.Fatalf("unexpected months %v", months)
+	}
+}
+
+func TestAlternativeFiscalYears(t *testing.T) {
+	p, err := CalendarYear.PeriodOf(calendar.MustParseDate("2024-02-29", calendar.AD), Monthly)
+	if err != nil {
+		t.Fatalf("PeriodOf failed: %v", err)
+	}
+	if p.Key() != "2024-M02" || p.End.String() != "2024-02-29" {
+		t.Fatalf("unexpected period %s", p)
+	}
+
+	april := Config{System: calendar.AD, StartMonth: 4}
+	p, _ = april.PeriodOf(calendar.MustParseDate("2025-03-31", calendar.AD), Quarterly)
+	if p.Key() != "2024/25-Q4" || p.Start.String() != "2025-01-01" {
+		t.Fatalf("unexpected period %s", p)
+	}
+
+	if _, err := (Config{StartMonth: 13}).FiscalYear(2024); err == nil {
+		t.Fatal("expected invalid start month to be rejected")
+	}
+}
+
+func TestLocker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "periods.json")
+	l := NewLocker(NepaliFiscalYear, Monthly, NewFileStore(path))
+	shrawan := calendar.MustParseDate("2081-04-15", calendar.BS)
+
+	if err := l.CheckDate(shrawan, false); err != nil {
+		t.Fatalf("expected open period, got %v", err)
+	}
+	if _, err := l.SoftClose(shrawan); err != nil {
+		t.Fatalf("SoftClose failed: %v", err)
+	}
+
+	// A fresh locker on the same file sees the closed period.
+	l = NewLocker(NepaliFiscalYear, Monthly, NewFileStore(path))
+	var closed *ClosedError
+	if err := l.CheckDate(shrawan, false); !errors.As(err, &closed) || closed.Status != SoftClosed {
+		t.Fatalf("expected soft-closed error, got %v", err)
+	}
+	if err := l.CheckDate(shrawan, true); err != nil {
+		t.Fatalf("expected override to pass a soft-closed period, got %v", err)
+	}
+	if err := l.CheckDate(calendar.MustParseDate("2081-05-01", calendar.BS), false); err != nil {
+		t.Fatalf("expected Bhadra to stay open, got %v", err)
+	}
+
+	if _, err := l.HardClose(shrawan); err != nil {
+		t.Fatalf("HardClose failed: %v", err)
+	}
+	if err := l.CheckDate(shrawan, true); !errors.As(err, &closed) || closed.Status != HardClosed {
+		t.Fatalf("expected hard-closed error despite override, got %v", err)
+	}
+	if _, err := l.Reopen(shrawan); err == nil {
+		t.Fatal("expected hard-closed period to stay closed")
+	}
+}
@@ -0,0 +1,241 @@
+package reconcile
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// Options tune automatic matching.
+type Options struct {
+	// DateWindow is how many days apart a statement line and a book line may
+	// be and still match.
+	DateWindow int
+	// AmountTolerance lets fuzzy one-to-one matches differ by up to this much.
+	// Zero requires equal amounts.
+	AmountTolerance money.Money
+	// MaxGroupSize caps how many lines one side of a one-to-many or
+	// many-to-one match may have. Values below 2 disable group matching.
+	MaxGroupSize int
+	// ConfirmExact confirms exact matches straight away instead of proposing them.
+	ConfirmExact bool
+}
+
+// DefaultOptions allows three days between bank and book dates and groups of
+// up to four lines.
+func DefaultOptions() Options {
+	return Options{DateWindow: 3, MaxGroupSize: 4}
+}
+
+// maxGroupCandidates bounds the subset search for group matches.
+const maxGroupCandidates = 20
+
+// AutoMatch proposes matches for unmatched lines and returns them. It runs
+// in passes, each only over what earlier passes left:
+//
+//  1. exact: equal amounts on the same date, or within the window with a
+//     matching reference;
+//  2. fuzzy: amounts within tolerance inside the window, best score first;
+//  3. one-to-many: one statement line equal to the sum of several book lines
+//     inside the window, such as a deposit of several receipts;
+//  4. many-to-one: several statement lines equal to one book line.
+//
+// Group matches from passes 3 and 4 have kind Group.
+func (s *Session) AutoMatch(opts Options) []Match {
+	var found []Match
+	add := func(stmt, book []string, kind MatchKind, score float64) {
+		status := Proposed
+		if kind == Exact && opts.ConfirmExact {
+			status = Confirmed
+		}
+		found = append(found, s.addMatch(stmt, book, kind, status, score))
+	}
+
+	stmt, book := s.Unmatched()
+	used := map[string]bool{}
+
+	for _, st := range stmt {
+		best, bestDays := -1, 0
+		for j, bk := range book {
+			if used[bk.Key] || !equal(st.Amount, bk.Amount) {
+				continue
+			}
+			days := daysApart(st, bk)
+			if days != 0 && (days > opts.DateWindow || !refMatch(st, bk)) {
+				continue
+			}
+			if best < 0 || days < bestDays {
+				best, bestDays = j, days
+			}
+		}
+		if best >= 0 {
+			used[st.Key], used[book[best].Key] = true, true
+			add([]string{st.Key}, []string{book[best].Key}, Exact, 1)
+		}
+	}
+
+	type pair struct {
+		s, b  int
+		score float64
+	}
+	var pairs []pair
+	for i, st := range stmt {
+		if used[st.Key] {
+			continue
+		}
+		for j, bk := range book {
+			if used[bk.Key] || daysApart(st, bk) > opts.DateWindow || !withinTolerance(st.Amount, bk.Amount, opts.AmountTolerance) {
+				continue
+			}
+			pairs = append(pairs, pair{i, j, score(st, bk, opts)})
+		}
+	}
+	sort.SliceStable(pairs, func(a, b int) bool { return pairs[a].score > pairs[b].score })
+	for _, p := range pairs {
+		st, bk := stmt[p.s], book[p.b]
+		if used[st.Key] || used[bk.Key] {
+			continue
+		}
+		used[st.Key], used[bk.Key] = true, true
+		add([]string{st.Key}, []string{bk.Key}, Fuzzy, p.score)
+	}
+
+	if opts.MaxGroupSize < 2 {
+		return found
+	}
+	for _, st := range stmt {
+		if used[st.Key] {
+			continue
+		}
+		if group, sc := findGroup(st, book, used, opts); group != nil {
+			used[st.Key] = true
+			add([]string{st.Key}, group, Group, sc)
+		}
+	}
+	for _, bk := range book {
+		if used[bk.Key] {
+			continue
+		}
+		if group, sc := findGroup(bk, stmt, used, opts); group != nil {
+			used[bk.Key] = true
+			add(group, []string{bk.Key}, Group, sc)
+		}
+	}
+	return found
+}
+
+// findGroup looks for 2 to MaxGroupSize unused items within the date window of
+// target whose amounts add up to target's exactly. It marks and returns their
+// keys with a score, or returns nil.
+func findGroup(target Item, items []Item, used map[string]bool, opts Options) ([]string, float64) {
+	var candidates []Item
+	for _, it := range items {
+		if used[it.Key] || daysApart(target, it) > opts.DateWindow || it.Amount.Sign() != target.Amount.Sign() {
+			continue
+		}
+		if c, err := it.Amount.Abs().Cmp(target.Amount.Abs()); err != nil || c >= 0 {
+			continue
+		}
+		candidates = append(candidates, it)
+		if len(candidates) == maxGroupCandidates {
+			break
+		}
+	}
+
+	var pick []int
+	var search func(start int, sum money.Money) bool
+	search = func(start int, sum money.Money) bool {
+		if len(pick) >= 2 && equal(sum, target.Amount) {
+			return true
+		}
+		if len(pick) == opts.MaxGroupSize {
+			return false
+		}
+		for i := start; i < len(candidates); i++ {
+			next, err := sum.Add(candidates[i].Amount)
+			if err != nil {
+				continue
+			}
+			// Every candidate has target's sign, so overshooting is final.
+			if c, _ := next.Abs().Cmp(target.Amount.Abs()); c > 0 {
+				continue
+			}
+			pick = append(pick, i)
+			if search(i+1, next) {
+				return true
+			}
+			pick = pick[:len(pick)-1]
+		}
+		return false
+	}
+	if !search(0, money.Zero(target.Amount.Currency())) {
+		return nil, 0
+	}
+
+	keys := make([]string, len(pick))
+	farthest := 0
+	for n, i := range pick {
+		keys[n] = candidates[i].Key
+		used[candidates[i].Key] = true
+		if d := daysApart(target, candidates[i]); d > farthest {
+			farthest = d
+		}
+	}
+	return keys, groupScore(farthest, opts)
+}
+
+// score rates a fuzzy pair: up to 0.4 for closeness in time, 0.4 for a
+// matching reference and 0.2 for an exact amount.
+func score(st, bk Item, opts Options) float64 {
+	sc := 0.4 * (1 - float64(daysApart(st, bk))/float64(opts.DateWindow+1))
+	if refMatch(st, bk) {
+		sc += 0.4
+	}
+	if equal(st.Amount, bk.Amount) {
+		sc += 0.2
+	}
+	return sc
+}
+
+// groupScore rates a group match, whose sum is always exact: 0.2 for the
+// amount and up to 0.8 for how close in time its farthest line is.
+func groupScore(farthest int, opts Options) float64 {
+	return 0.2 + 0.8*(1-float64(farthest)/float64(opts.DateWindow+1))
+}
+
+func daysApart(a, b Item) int {
+	d := int(a.Date.Time().Sub(b.Date.Time()).Hours() / 24)
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func equal(a, b money.Money) bool {
+	c, err := a.Cmp(b)
+	return err == nil && c == 0
+}
+
+func withinTolerance(a, b, tolerance money.Money) bool {
+	diff, err := a.Sub(b)
+	if err != nil {
+		return false
+	}
+	c, err := diff.Abs().Cmp(tolerance)
+	return err == nil && c <= 0
+}
+
+var nonAlnum = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// refMatch reports whether either item's reference, of at least four letters
+// or digits, appears in the other's reference or description.
+func refMatch(a, b Item) bool {
+	norm := func(s string) string { return nonAlnum.ReplaceAllString(strings.ToUpper(s), "") }
+	contains := func(ref string, other Item) bool {
+		ref = norm(ref)
+		return len(ref) >= 4 && strings.Contains(norm(other.Reference+" "+other.Description), ref)
+	}
+	return contains(a.Reference, b) || contains(b.Reference, a)
+}
@@ -0,0 +1,410 @@
+package reconcile
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/bankstatement"
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// Item is one side of a reconciliation: a bank statement line or a posted
+// voucher line on the bank account. Amount is positive for money into the
+// account, so a receipt is a deposit on the statement and a debit in the books.
+type Item struct {
+	Key         string        `json:"key"`
+	Date        calendar.Date `json:"date"`
+	Amount      money.Money   `json:"amount"`
+	Reference   string        `json:"reference,omitempty"`
+	Description string        `json:"description,omitempty"`
+}
+
+// StatementItems turns statement lines into items keyed by the bank's
+// transaction ID, or by position when the format has none.
+func StatementItems(st *bankstatement.Statement) []Item {
+	items := make([]Item, 0, len(st.Transactions))
+	for i, txn := range st.Transactions {
+		key := "S:" + txn.ID
+		if txn.ID == "" {
+			key = fmt.Sprintf("S:%s#%d", txn.Date.Format(calendar.AD), i+1)
+		}
+		amount := txn.Amount
+		if amount.Currency() == "" {
+			amount = amount.WithCurrency(st.Currency)
+		}
+		items = append(items, Item{
+			Key:         key,
+			Date:        txn.Date,
+			Amount:      amount,
+			Reference:   txn.Reference,
+			Description: strings.TrimSpace(txn.Description + " " + txn.Counterparty),
+		})
+	}
+	return items
+}
+
+// BookItems returns the lines of posted vouchers dated on or before asOf that
+// hit the bank account, keyed by voucher ID and line number. Drafts and voided
+// vouchers are skipped, and a zero asOf takes every date. Foreign-currency
+// vouchers contribute their base amounts, as the bank account is kept in the
+// base currency.
+func BookItems(vouchers []journal.JournalVoucher, bank account.Account, asOf calendar.Date) ([]Item, error) {
+	var items []Item
+	for _, jv := range vouchers {
+		if jv.VoucherStatus != journal.StatusPosted || (!asOf.IsZero() && jv.Date.After(asOf)) {
+			continue
+		}
+		for i, line := range jv.Items {
+			if !(line.AccountID != "" && line.AccountID == bank.ID) && !(line.AccountCode != "" && line.AccountCode == bank.Code) {
+				continue
+			}
+			amount, currency := line.Amount, jv.CurrencyCode
+			if jv.BaseCurrencyCode != "" && jv.BaseCurrencyCode != jv.CurrencyCode {
+				if line.BaseAmount == nil {
+					return nil, fmt.Errorf("voucher %s line %d: foreign-currency line has no base amount", jv.ID, i+1)
+				}
+				amount, currency = *line.BaseAmount, jv.BaseCurrencyCode
+			}
+			if amount.Currency() == "" {
+				amount = amount.WithCurrency(currency)
+			}
+			if line.TxnType == journal.TxnTypeCredit {
+				amount = amount.Neg()
+			}
+			items = append(items, Item{
+				Key:         fmt.Sprintf("B:%s#%d", jv.ID, i),
+				Date:        jv.Date,
+				Amount:      amount,
+				Reference:   jv.Code,
+				Description: strings.TrimSpace(jv.Narration + " " + line.Narration),
+			})
+		}
+	}
+	return items, nil
+}
+
+// MatchKind is how a match was found.
+type MatchKind string
+
+const (
+	Exact  MatchKind = "EXACT"
+	Fuzzy  MatchKind = "FUZZY"
+	Group  MatchKind = "GROUP"
+	Manual MatchKind = "MANUAL"
+)
+
+// MatchStatus is whether a match has been accepted.
+type MatchStatus string
+
+const (
+	Proposed  MatchStatus = "PROPOSED"
+	Confirmed MatchStatus = "CONFIRMED"
+)
+
+// Match pairs one or more statement lines with one or more book lines whose
+// amounts add up to the same total. Score runs from 0 to 1 for automatic
+// matches and is 1 for manual ones.
+type Match struct {
+	ID        string      `json:"id"`
+	Statement []string    `json:"statement"`
+	Book      []string    `json:"book"`
+	Kind      MatchKind   `json:"kind"`
+	Status    MatchStatus `json:"status"`
+	Score     float64     `json:"score"`
+}
+
+// Session is the state of reconciling one bank account as at a statement
+// date. Only confirmed matches clear items; proposed matches only keep their
+// items out of further automatic matching until they are confirmed or undone.
+//
+// Book holds every posted line on the account up to AsOf, as BookItems returns
+// them, so the book balance is their total. Lines cleared in earlier sessions
+// are listed in Cleared: they count towards the book balance but are never
+// matched or reported as uncleared again.
+type Session struct {
+	ID          string        `json:"id"`
+	AccountCode string        `json:"account_code"`
+	AsOf        calendar.Date `json:"as_of"`
+	// BankBalance is the closing balance on the statement.
+	BankBalance money.Money `json:"bank_balance"`
+	Statement   []Item      `json:"statement"`
+	Book        []Item      `json:"book"`
+	Cleared     []string    `json:"cleared,omitempty"`
+	Matches     []Match     `json:"matches"`
+	NextMatch   int         `json:"next_match"`
+}
+
+func NewSession(id, accountCode string, asOf calendar.Date, bankBalance money.Money) *Session {
+	return &Session{ID: id, AccountCode: accountCode, AsOf: asOf, BankBalance: bankBalance}
+}
+
+// Next starts the session for the following statement. It carries forward the
+// lines cleared so far, the book lines, and the statement lines not yet in the
+// books, so only the new statement and vouchers need to be added.
+func (s *Session) Next(id string, asOf calendar.Date, bankBalance money.Money) *Session {
+	next := NewSession(id, s.AccountCode, asOf, bankBalance)
+	next.Cleared = append(next.Cleared, s.Cleared...)
+	for _, m := range s.Matches {
+		if m.Status == Confirmed {
+			next.Cleared = append(append(next.Cleared, m.Statement...), m.Book...)
+		}
+	}
+	stmt, _ := s.unmatched(true)
+	next.Statement = append(next.Statement, stmt...)
+	next.Book = append(next.Book, s.Book...)
+	return next
+}
+
+// BookBalance returns the account's balance in the books as at AsOf: the total
+// of the book lines.
+func (s *Session) BookBalance() (money.Money, error) {
+	return addItemsTo(money.Zero(s.BankBalance.Currency()), s.Book)
+}
+
+// AddStatement adds statement lines. Lines whose key is already in the
+// session or was cleared in an earlier session are ignored, so a statement
+// can be loaded again safely.
+func (s *Session) AddStatement(items ...Item) {
+	cleared := make(map[string]bool, len(s.Cleared))
+	for _, k := range s.Cleared {
+		cleared[k] = true
+	}
+	var fresh []Item
+	for _, it := range items {
+		if !cleared[it.Key] {
+			fresh = append(fresh, it)
+		}
+	}
+	s.Statement = addItems(s.Statement, fresh)
+}
+
+// AddBook adds book lines, ignoring keys already in the session. Lines
+// cleared in earlier sessions are kept for the book balance only.
+func (s *Session) AddBook(items ...Item) {
+	s.Book = addItems(s.Book, items)
+}
+
+func addItems(dst, items []Item) []Item {
+	seen := make(map[string]bool, len(dst))
+	for _, it := range dst {
+		seen[it.Key] = true
+	}
+	for _, it := range items {
+		if !seen[it.Key] {
+			seen[it.Key] = true
+			dst = append(dst, it)
+		}
+	}
+	return dst
+}
+
+// matched returns the keys of items cleared in earlier sessions and of items
+// in any match, proposed or confirmed, or only in confirmed matches.
+func (s *Session) matched(confirmedOnly bool) map[string]bool {
+	out := map[string]bool{}
+	for _, k := range s.Cleared {
+		out[k] = true
+	}
+	for _, m := range s.Matches {
+		if confirmedOnly && m.Status != Confirmed {
+			continue
+		}
+		for _, k := range append(append([]string(nil), m.Statement...), m.Book...) {
+			out[k] = true
+		}
+	}
+	return out
+}
+
+// Unmatched returns the statement and book lines that are in no match.
+func (s *Session) Unmatched() (statement, book []Item) {
+	return s.unmatched(false)
+}
+
+func (s *Session) unmatched(confirmedOnly bool) (statement, book []Item) {
+	done := s.matched(confirmedOnly)
+	for _, it := range s.Statement {
+		if !done[it.Key] {
+			statement = append(statement, it)
+		}
+	}
+	for _, it := range s.Book {
+		if !done[it.Key] {
+			book = append(book, it)
+		}
+	}
+	return statement, book
+}
+
+func (s *Session) addMatch(statement, book []string, kind MatchKind, status MatchStatus, score float64) Match {
+	s.NextMatch++
+	m := Match{ID: fmt.Sprintf("M%d", s.NextMatch), Statement: statement, Book: book, Kind: kind, Status: status, Score: score}
+	s.Matches = append(s.Matches, m)
+	return m
+}
+
+func (s *Session) findMatch(id string) (int, error) {
+	for i := range s.Matches {
+		if s.Matches[i].ID == id {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("match %s not found in session %s", id, s.ID)
+}
+
+// Confirm accepts a proposed match.
+func (s *Session) Confirm(id string) error {
+	i, err := s.findMatch(id)
+	if err != nil {
+		return err
+	}
+	s.Matches[i].Status = Confirmed
+	return nil
+}
+
+// Undo removes a match, proposed or confirmed, and releases its items.
+func (s *Session) Undo(id string) error {
+	i, err := s.findMatch(id)
+	if err != nil {
+		return err
+	}
+	s.Matches = append(s.Matches[:i], s.Matches[i+1:]...)
+	return nil
+}
+
+// Pair records a confirmed manual match. Every item must exist and be
+// unmatched, and both sides must total the same amount.
+func (s *Session) Pair(statementKeys, bookKeys []string) (*Match, error) {
+	if len(statementKeys) == 0 || len(bookKeys) == 0 {
+		return nil, fmt.Errorf("a match needs at least one statement line and one book line")
+	}
+	stmt, book := s.Unmatched()
+	stmtTotal, err := total(stmt, statementKeys)
+	if err != nil {
+		return nil, err
+	}
+	bookTotal, err := total(book, bookKeys)
+	if err != nil {
+		return nil, err
+	}
+	if !equal(stmtTotal, bookTotal) {
+		return nil, fmt.Errorf("statement lines total %s but book lines total %s", stmtTotal, bookTotal)
+	}
+	m := s.addMatch(statementKeys, bookKeys, Manual, Confirmed, 1)
+	return &m, nil
+}
+
+// total sums the items with the given keys, failing on a key that is not
+// among items.
+func total(items []Item, keys []string) (money.Money, error) {
+	byKey := make(map[string]Item, len(items))
+	for _, it := range items {
+		byKey[it.Key] = it
+	}
+	var amounts []money.Money
+	for _, k := range keys {
+		it, ok := byKey[k]
+		if !ok {
+			return money.Money{}, fmt.Errorf("item %s is unknown or already matched", k)
+		}
+		amounts = append(amounts, it.Amount)
+	}
+	return money.Sum(amounts...)
+}
+
+// Reconciliation is the reconciliation statement: the book and bank balances
+// and the items that explain the difference between them.
+type Reconciliation struct {
+	AsOf        calendar.Date
+	BookBalance money.Money
+	BankBalance money.Money
+	// UnclearedBook are book lines not yet on the statement: deposits in
+	// transit (positive) and outstanding payments (negative).
+	UnclearedBook []Item
+	// UnrecordedBank are statement lines not yet in the books, such as bank
+	// charges and interest.
+	UnrecordedBank []Item
+	// AdjustedBank is BankBalance plus UnclearedBook; AdjustedBook is
+	// BookBalance plus UnrecordedBank. They agree when the account reconciles.
+	AdjustedBank money.Money
+	AdjustedBook money.Money
+}
+
+// Difference returns AdjustedBank - AdjustedBook.
+func (r *Reconciliation) Difference() money.Money {
+	diff, _ := r.AdjustedBank.Sub(r.AdjustedBook)
+	return diff
+}
+
+// Reconciled reports whether the adjusted balances agree.
+func (r *Reconciliation) Reconciled() bool {
+	return r.Difference().IsZero()
+}
+
+// Report builds the reconciliation statement from confirmed matches.
+func (s *Session) Report() (*Reconciliation, error) {
+	bookBalance, err := s.BookBalance()
+	if err != nil {
+		return nil, err
+	}
+	stmt, book := s.unmatched(true)
+	r := &Reconciliation{
+		AsOf:           s.AsOf,
+		BookBalance:    bookBalance,
+		BankBalance:    s.BankBalance,
+		UnclearedBook:  book,
+		UnrecordedBank: stmt,
+	}
+	if r.AdjustedBank, err = addItemsTo(s.BankBalance, book); err != nil {
+		return nil, err
+	}
+	if r.AdjustedBook, err = addItemsTo(bookBalance, stmt); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func addItemsTo(balance money.Money, items []Item) (money.Money, error) {
+	for _, it := range items {
+		var err error
+		if balance, err = balance.Add(it.Amount); err != nil {
+			return money.Money{}, fmt.Errorf("item %s: %w", it.Key, err)
+		}
+	}
+	return balance, nil
+}
+
+// WriteTo prints the reconciliation statement.
+func (r *Reconciliation) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	line := func(label string, m money.Money) {
+		fmt.Fprintf(&b, "%-40s %15s\n", label, m)
+	}
+	section := func(title string, items []Item) {
+		fmt.Fprintf(&b, "%s:\n", title)
+		if len(items) == 0 {
+			b.WriteString("  (none)\n")
+		}
+		for _, it := range items {
+			fmt.Fprintf(&b, "  %s %-12s %-23.23s %15s\n", it.Date, it.Reference, it.Description, it.Amount)
+		}
+	}
+
+	fmt.Fprintf(&b, "Bank reconciliation as at %s\n\n", r.AsOf)
+	line("Balance as per bank statement", r.BankBalance)
+	section("Add/less: items in books not on statement", r.UnclearedBook)
+	line("Adjusted bank balance", r.AdjustedBank)
+	b.WriteString("\n")
+	line("Balance as per books", r.BookBalance)
+	section("Add/less: items on statement not in books", r.UnrecordedBank)
+	line("Adjusted book balance", r.AdjustedBook)
+	b.WriteString("\n")
+	line("Difference", r.Difference())
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
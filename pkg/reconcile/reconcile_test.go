@@ -0,0 +1,215 @@
+package reconcile
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/bankstatement"
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+func item(key, date, amount, ref string) Item {
+	return Item{Key: key, Date: calendar.MustParseDate(date, calendar.AD), Amount: money.MustParse(amount, "NPR"), Reference: ref}
+}
+
+func moneyPtr(m money.Money) *money.Money { return &m }
+
+func TestBookItems(t *testing.T) {
+	bank := account.Account{ID: "acc-bank", Code: "BA0001"}
+	vouchers := []journal.JournalVoucher{
+		{ID: "jv-1", Code: "RCP-1", Date: calendar.MustParseDate("2024-07-16", calendar.AD), CurrencyCode: "NPR", VoucherStatus: journal.StatusPosted, Items: []journal.JournalVoucherItem{
+			{AccountID: "acc-bank", Amount: money.MustParse("500", ""), TxnType: journal.TxnTypeDebit},
+			{AccountID: "acc-sales", Amount: money.MustParse("500", ""), TxnType: journal.TxnTypeCredit},
+		}},
+		{ID: "jv-2", Code: "PAY-1", Date: calendar.MustParseDate("2024-07-17", calendar.AD), VoucherStatus: journal.StatusPosted, Items: []journal.JournalVoucherItem{
+			{AccountID: "acc-rent", Amount: money.MustParse("200", "NPR"), TxnType: journal.TxnTypeDebit},
+			{AccountCode: "BA0001", Amount: money.MustParse("200", "NPR"), TxnType: journal.TxnTypeCredit},
+		}},
+		{ID: "jv-3", VoucherStatus: journal.StatusDraft, Items: []journal.JournalVoucherItem{
+			{AccountID: "acc-bank", Amount: money.MustParse("1", "NPR"), TxnType: journal.TxnTypeDebit},
+		}},
+		{ID: "jv-4", Code: "RCP-2", Date: calendar.MustParseDate("2024-07-18", calendar.AD), CurrencyCode: "USD", BaseCurrencyCode: "NPR", VoucherStatus: journal.StatusPosted, Items: []journal.JournalVoucherItem{
+			{AccountID: "acc-bank", Amount: money.MustParse("10", "USD"), BaseAmount: moneyPtr(money.MustParse("1335", "NPR")), TxnType: journal.TxnTypeDebit},
+			{AccountID: "acc-sales", Amount: money.MustParse("10", "USD"), BaseAmount: moneyPtr(money.MustParse("1335", "NPR")), TxnType: journal.TxnTypeCredit},
+		}},
+		{ID: "jv-5", Code: "RCP-3", Date: calendar.MustParseDate("2024-08-01", calendar.AD), CurrencyCode: "NPR", VoucherStatus: journal.StatusPosted, Items: []journal.JournalVoucherItem{
+			{AccountID: "acc-bank", Amount: money.MustParse("75", "NPR"), TxnType: journal.TxnTypeDebit},
+		}},
+	}
+	items, err := BookItems(vouchers, bank, calendar.MustParseDate("2024-07-31", calendar.AD))
+	if err != nil {
+		t.Fatalf("BookItems failed: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("expected 3 book items up to the statement date, got %+v", items)
+	}
+	if items[0].Key != "B:jv-1#0" || items[0].Amount.String() != "500" || items[0].Amount.Currency() != "NPR" {
+		t.Fatalf("unexpected receipt %+v", items[0])
+	}
+	if items[1].Key != "B:jv-2#1" || items[1].Amount.String() != "-200" || items[1].Reference != "PAY-1" {
+		t.Fatalf("unexpected payment %+v", items[1])
+	}
+	if items[2].Key != "B:jv-4#0" || items[2].Amount.String() != "1335" || items[2].Amount.Currency() != "NPR" {
+		t.Fatalf("expected the base amount of a foreign receipt, got %+v", items[2])
+	}
+	if all, err := BookItems(vouchers, bank, calendar.Date{}); err != nil || len(all) != 4 {
+		t.Fatalf("expected a zero date to take every voucher, got %+v, %v", all, err)
+	}
+	vouchers[3].Items[0].BaseAmount = nil
+	if _, err := BookItems(vouchers, bank, calendar.Date{}); err == nil || !strings.Contains(err.Error(), "base amount") {
+		t.Fatalf("expected a missing base amount to fail, got %v", err)
+	}
+
+	st := &bankstatement.Statement{Currency: "NPR", Transactions: []bankstatement.Transaction{
+		{ID: "FT1", Date: calendar.MustParseDate("2024-07-16", calendar.AD), Amount: money.MustParse("500", "")},
+		{Date: calendar.MustParseDate("2024-07-17", calendar.AD), Amount: money.MustParse("-200", "NPR")},
+	}}
+	stmt := StatementItems(st)
+	if stmt[0].Key != "S:FT1" || stmt[0].Amount.Currency() != "NPR" || stmt[1].Key != "S:2024-07-17#2" {
+		t.Fatalf("unexpected statement items %+v", stmt)
+	}
+}
+
+func TestAutoMatch(t *testing.T) {
+	s := NewSession("july", "BA0001", calendar.MustParseDate("2024-07-31", calendar.AD), money.MustParse("0", "NPR"))
+	s.AddStatement(
+		item("S:1", "2024-07-16", "500", ""),
+		item("S:2", "2024-07-19", "-1200", "CHQ000123"),
+		item("S:3", "2024-07-20", "-99.50", ""),
+		item("S:4", "2024-07-22", "3000", ""),
+		item("S:5", "2024-07-25", "400", ""),
+		item("S:6", "2024-07-25", "600", ""),
+		item("S:7", "2024-07-28", "-15", ""),
+	)
+	s.AddBook(
+		item("B:a", "2024-07-16", "500", "RCP-1"),
+		item("B:b", "2024-07-16", "-1200", "PAY chq 000123"),
+		item("B:c", "2024-07-18", "-99.00", ""),
+		item("B:d", "2024-07-21", "1000", ""),
+		item("B:e", "2024-07-21", "2000", ""),
+		item("B:f", "2024-07-24", "1000", ""),
+		item("B:g", "2024-07-29", "750", ""),
+	)
+	s.AddStatement(item("S:1", "2024-07-16", "500", ""))
+	if len(s.Statement) != 7 {
+		t.Fatalf("expected a reloaded line to be ignored, got %d lines", len(s.Statement))
+	}
+
+	opts := DefaultOptions()
+	opts.AmountTolerance = money.MustParse("1", "NPR")
+	matches := s.AutoMatch(opts)
+	got := map[string]Match{}
+	for _, m := range matches {
+		got[strings.Join(m.Statement, ",")+"="+strings.Join(m.Book, ",")] = m
+	}
+	for key, kind := range map[string]MatchKind{
+		"S:1=B:a":     Exact,
+		"S:2=B:b":     Exact,
+		"S:3=B:c":     Fuzzy,
+		"S:4=B:d,B:e": Group,
+		"S:5,S:6=B:f": Group,
+	} {
+		m, ok := got[key]
+		if !ok || m.Kind != kind || m.Status != Proposed {
+			t.Fatalf("expected a proposed %s match %s, got %+v", kind, key, matches)
+		}
+	}
+	if len(matches) != 5 {
+		t.Fatalf("expected 5 matches, got %+v", matches)
+	}
+	if sc := got["S:4=B:d,B:e"].Score; sc <= 0.2 || sc >= 1 {
+		t.Fatalf("expected a group score between the amount and a perfect match, got %v", sc)
+	}
+
+	// Nothing is cleared until matches are confirmed.
+	r, err := s.Report()
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	if len(r.UnclearedBook) != 7 {
+		t.Fatalf("expected proposed matches not to clear items, got %+v", r.UnclearedBook)
+	}
+	if again := s.AutoMatch(opts); len(again) != 0 {
+		t.Fatalf("expected proposed items to be left alone, got %+v", again)
+	}
+}
+
+func TestSessionWorkflow(t *testing.T) {
+	// June clears the opening deposit.
+	june := NewSession("june", "BA0001", calendar.MustParseDate("2024-06-30", calendar.AD), money.MustParse("11550", "NPR"))
+	june.AddStatement(item("S:0", "2024-06-15", "11550", ""))
+	june.AddBook(item("B:0", "2024-06-15", "11550", ""))
+	if _, err := june.Pair([]string{"S:0"}, []string{"B:0"}); err != nil {
+		t.Fatalf("Pair failed: %v", err)
+	}
+
+	// In July the books include a 750 deposit the bank has not cleared yet;
+	// the bank has charged a 15 fee the books do not have. The statement and
+	// the books are loaded in full again.
+	s := june.Next("july", calendar.MustParseDate("2024-07-31", calendar.AD), money.MustParse("9235", "NPR"))
+	s.AddStatement(item("S:0", "2024-06-15", "11550", ""), item("S:1", "2024-07-19", "-1200", ""), item("S:2", "2024-07-28", "-15", ""), item("S:3", "2024-07-30", "-1100", ""))
+	s.AddBook(item("B:0", "2024-06-15", "11550", ""), item("B:a", "2024-07-19", "-1200", ""), item("B:b", "2024-07-29", "750", ""), item("B:c", "2024-07-30", "-1100", ""))
+	if bal, err := s.BookBalance(); err != nil || !bal.Equal(money.MustParse("10000", "NPR")) {
+		t.Fatalf("expected a book balance of 10000 from the book lines, got %s %v", bal, err)
+	}
+
+	opts := DefaultOptions()
+	opts.ConfirmExact = true
+	matches := s.AutoMatch(opts)
+	if len(matches) != 2 || matches[0].Status != Confirmed {
+		t.Fatalf("expected 2 confirmed exact matches, got %+v", matches)
+	}
+	if err := s.Undo(matches[1].ID); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if _, err := s.Pair([]string{"S:2"}, []string{"B:b"}); err == nil {
+		t.Fatal("expected pairing unequal amounts to fail")
+	}
+	if _, err := s.Pair([]string{"S:1"}, []string{"B:a"}); err == nil {
+		t.Fatal("expected pairing already matched items to fail")
+	}
+	m, err := s.Pair([]string{"S:3"}, []string{"B:c"})
+	if err != nil || m.Kind != Manual || m.ID != "M3" {
+		t.Fatalf("expected manual match M3, got %+v %v", m, err)
+	}
+	if err := s.Confirm("M9"); err == nil {
+		t.Fatal("expected confirming an unknown match to fail")
+	}
+
+	store := NewFileSessionStore(filepath.Join(t.TempDir(), "sessions.json"))
+	if err := store.PutSession(s); err != nil {
+		t.Fatalf("PutSession failed: %v", err)
+	}
+	loaded, err := store.GetSession("july")
+	if err != nil || loaded == nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if missing, err := store.GetSession("june"); err != nil || missing != nil {
+		t.Fatalf("expected no session, got %+v %v", missing, err)
+	}
+
+	r, err := loaded.Report()
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	if len(r.UnclearedBook) != 1 || len(r.UnrecordedBank) != 1 {
+		t.Fatalf("unexpected open items %+v %+v", r.UnclearedBook, r.UnrecordedBank)
+	}
+	if r.AdjustedBank.String() != "9985" || !r.AdjustedBook.Equal(r.AdjustedBank) || !r.Reconciled() {
+		t.Fatalf("unexpected adjusted balances %s %s", r.AdjustedBank, r.AdjustedBook)
+	}
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	for _, want := range []string{"Bank reconciliation as at 2024-07-31", "Adjusted bank balance", "9985", "Difference"} {
+		if !strings.Contains(b.String(), want) {
+			t.Fatalf("expected %q in\n%s", want, b.String())
+		}
+	}
+}
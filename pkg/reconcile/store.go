@@ -0,0 +1,97 @@
+package reconcile
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/internal/jsonfile"
+)
+
+// SessionStore persists reconciliation sessions between runs.
+type SessionStore interface {
+	GetSession(id string) (*Session, error)
+	PutSession(s *Session) error
+}
+
+// MemorySessionStore keeps sessions in memory.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string][]byte
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string][]byte{}}
+}
+
+// GetSession returns a copy of the stored session, or nil if there is none.
+func (s *MemorySessionStore) GetSession(id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	var out Session
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *MemorySessionStore) PutSession(sess *Session) error {
+	if sess.ID == "" {
+		return fmt.Errorf("reconciliation session id is required")
+	}
+	b, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sess.ID] = b
+	return nil
+}
+
+// FileSessionStore keeps every session in one JSON file keyed by session ID,
+// so a reconciliation can be picked up again later.
+type FileSessionStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileSessionStore(path string) *FileSessionStore {
+	return &FileSessionStore{path: path}
+}
+
+func (s *FileSessionStore) load() (map[string]*Session, error) {
+	data := map[string]*Session{}
+	if err := jsonfile.Read(s.path, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *FileSessionStore) GetSession(id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return data[id], nil
+}
+
+func (s *FileSessionStore) PutSession(sess *Session) error {
+	if sess.ID == "" {
+		return fmt.Errorf("reconciliation session id is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := s.load()
+	if err != nil {
+		return err
+	}
+	data[sess.ID] = sess
+	return jsonfile.Write(s.path, data)
+}
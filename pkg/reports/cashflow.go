@@ -0,0 +1,264 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// Activity is where an account's movement appears on a cash flow statement.
+type Activity string
+
+const (
+	// Cash marks cash and bank accounts, whose change the statement explains.
+	Cash Activity = "CASH"
+	// NonCash marks balance sheet accounts whose movement is a non-cash item
+	// in profit, such as accumulated depreciation and provisions. It is added
+	// back to profit.
+	NonCash   Activity = "NON_CASH"
+	Operating Activity = "OPERATING"
+	Investing Activity = "INVESTING"
+	Financing Activity = "FINANCING"
+)
+
+func (a Activity) valid() bool {
+	switch a {
+	case Cash, NonCash, Operating, Investing, Financing:
+		return true
+	}
+	return false
+}
+
+// CashFlowMapping assigns balance sheet accounts to activities. Income and
+// expense accounts need no entry: they make up the net profit the statement
+// starts from. An account's own entry wins; otherwise the closest group up
+// its group chain with an entry decides.
+type CashFlowMapping struct {
+	// Accounts maps account codes or IDs to activities.
+	Accounts map[string]Activity `json:"accounts,omitempty"`
+	// Groups maps account group IDs or names to activities. Names match
+	// case-insensitively.
+	Groups map[string]Activity `json:"groups,omitempty"`
+}
+
+// LoadCashFlowMapping reads a mapping from a JSON file.
+func LoadCashFlowMapping(path string) (CashFlowMapping, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return CashFlowMapping{}, err
+	}
+	var m CashFlowMapping
+	if err := json.Unmarshal(b, &m); err != nil {
+		return CashFlowMapping{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := m.validate(); err != nil {
+		return CashFlowMapping{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+func (m CashFlowMapping) validate() error {
+	for k, a := range m.Accounts {
+		if !a.valid() {
+			return fmt.Errorf("account %s: unknown activity %q", k, a)
+		}
+	}
+	for k, a := range m.Groups {
+		if !a.valid() {
+			return fmt.Errorf("group %s: unknown activity %q", k, a)
+		}
+	}
+	return nil
+}
+
+// activity returns the activity of acc, or "" when nothing maps it.
+func (m CashFlowMapping) activity(acc account.Account, c *chart) Activity {
+	if a, ok := m.Accounts[acc.Code]; ok {
+		return a
+	}
+	if a, ok := m.Accounts[acc.ID]; ok {
+		return a
+	}
+	chain := c.chain(groupOf(acc))
+	for i := len(chain) - 1; i >= 0; i-- {
+		if a, ok := m.group(chain[i].ID, chain[i].Name); ok {
+			return a
+		}
+	}
+	if a, ok := m.group("", acc.PrimaryGroupName); ok {
+		return a
+	}
+	return ""
+}
+
+func (m CashFlowMapping) group(id, name string) (Activity, bool) {
+	if a, ok := m.Groups[id]; ok && id != "" {
+		return a, true
+	}
+	for k, a := range m.Groups {
+		if name != "" && strings.EqualFold(k, name) {
+			return a, true
+		}
+	}
+	return "", false
+}
+
+// CashFlowStatement explains the change in cash over each column by the
+// indirect method: net profit, adjusted for non-cash items and working
+// capital, then investing and financing flows. Amounts are inflows, so an
+// increase in receivables shows as a negative.
+type CashFlowStatement struct {
+	Title     string        `json:"title"`
+	Currency  string        `json:"currency"`
+	Columns   []Column      `json:"columns"`
+	NetProfit []money.Money `json:"net_profit"`
+	// NonCashItems are added back to profit; WorkingCapital are the movements
+	// in the other operating accounts.
+	NonCashItems   *StatementSection `json:"non_cash_items"`
+	WorkingCapital *StatementSection `json:"working_capital"`
+	// Operating is NetProfit plus the NonCashItems and WorkingCapital totals.
+	Operating   []money.Money     `json:"operating"`
+	Investing   *StatementSection `json:"investing"`
+	Financing   *StatementSection `json:"financing"`
+	NetChange   []money.Money     `json:"net_change"`
+	OpeningCash []money.Money     `json:"opening_cash"`
+	ClosingCash []money.Money     `json:"closing_cash"`
+	// Difference is NetChange less the actual change in cash. It is zero
+	// unless an account with movement is unmapped.
+	Difference []money.Money `json:"difference"`
+	// Warnings lists balance sheet accounts with movement that the mapping
+	// does not cover.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Reconciled reports whether NetChange explains the change in cash in every
+// column.
+func (cf *CashFlowStatement) Reconciled() bool {
+	return allZero(cf.Difference)
+}
+
+// CashFlow draws up a cash flow statement over each column. Every balance
+// sheet account with movement should be mapped; those that are not are
+// reported in Warnings and show up in Difference.
+func (r *Reporter) CashFlow(mapping CashFlowMapping, columns ...Column) (*CashFlowStatement, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("cash flow statement needs at least one column")
+	}
+	if err := mapping.validate(); err != nil {
+		return nil, err
+	}
+
+	c, err := r.loadChart()
+	if err != nil {
+		return nil, err
+	}
+	balances, err := r.columnBalances(c, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(columns)
+	cf := &CashFlowStatement{
+		Title: "Cash Flow Statement", Currency: r.Currency, Columns: columns,
+		NetProfit:      r.zeros(n),
+		NonCashItems:   &StatementSection{Title: "Adjustments for non-cash items"},
+		WorkingCapital: &StatementSection{Title: "Changes in working capital"},
+		Investing:      &StatementSection{Title: "Investing activities"},
+		Financing:      &StatementSection{Title: "Financing activities"},
+		OpeningCash:    r.zeros(n),
+		ClosingCash:    r.zeros(n),
+	}
+	sections := map[Activity]*StatementSection{
+		NonCash: cf.NonCashItems, Operating: cf.WorkingCapital, Investing: cf.Investing, Financing: cf.Financing,
+	}
+	haveCash := false
+
+	for idx, acc := range c.accounts {
+		opening, movement := r.zeros(n), r.zeros(n)
+		for i := range columns {
+			b, ok := balances[i][idx]
+			if !ok {
+				continue
+			}
+			opening[i] = b.Opening
+			if movement[i], err = b.Closing.Sub(b.Opening); err != nil {
+				return nil, err
+			}
+		}
+
+		if class, err := account.NormalizeClass(acc.AccountClassName); err == nil && (class == account.ClassIncome || class == account.ClassExpenses) {
+			if err := addAmounts(cf.NetProfit, present(movement, true)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		activity := mapping.activity(acc, c)
+		if activity == Cash {
+			haveCash = true
+			if err := addAmounts(cf.OpeningCash, opening); err != nil {
+				return nil, err
+			}
+			if err := addAmounts(cf.ClosingCash, opening); err != nil {
+				return nil, err
+			}
+			if err := addAmounts(cf.ClosingCash, movement); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if allZero(movement) && !r.ShowZero {
+			continue
+		}
+		sec, ok := sections[activity]
+		if !ok {
+			if !allZero(movement) {
+				cf.Warnings = append(cf.Warnings, fmt.Sprintf("account %s %s has movement but no cash flow activity", acc.Code, acc.Name))
+			}
+			continue
+		}
+		row := &StatementNode{Kind: AccountNode, ID: acc.ID, Code: acc.Code, Name: acc.Name, Amounts: present(movement, true)}
+		for _, col := range columns {
+			row.Refs = append(row.Refs, LedgerRef{AccountCode: acc.Code, From: col.From, To: col.To})
+		}
+		sec.Rows = append(sec.Rows, row)
+	}
+	if !haveCash {
+		return nil, fmt.Errorf("cash flow mapping marks no account as %s", Cash)
+	}
+
+	for _, sec := range sections {
+		sec.Totals = r.zeros(n)
+		for _, row := range sec.Rows {
+			if err := addAmounts(sec.Totals, row.Amounts); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	cf.Operating = append([]money.Money(nil), cf.NetProfit...)
+	cf.NetChange = r.zeros(n)
+	cf.Difference = r.zeros(n)
+	for _, add := range []struct {
+		dst []money.Money
+		src []money.Money
+	}{
+		{cf.Operating, cf.NonCashItems.Totals},
+		{cf.Operating, cf.WorkingCapital.Totals},
+		{cf.NetChange, cf.Operating},
+		{cf.NetChange, cf.Investing.Totals},
+		{cf.NetChange, cf.Financing.Totals},
+		{cf.Difference, cf.NetChange},
+		{cf.Difference, present(cf.ClosingCash, true)},
+		{cf.Difference, cf.OpeningCash},
+	} {
+		if err := addAmounts(add.dst, add.src); err != nil {
+			return nil, err
+		}
+	}
+	return cf, nil
+}
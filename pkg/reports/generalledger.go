@@ -0,0 +1,210 @@
+package reports
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// LedgerOptions tune a general ledger.
+type LedgerOptions struct {
+	// IncludeVoided lists the lines of voided vouchers, marked Voided. They
+	// never move the running balance.
+	IncludeVoided bool
+}
+
+// LedgerLine is one voucher line in a general ledger. Balance is the running
+// net debit after the line.
+type LedgerLine struct {
+	Date        calendar.Date `json:"date"`
+	VoucherID   string        `json:"voucher_id"`
+	VoucherCode string        `json:"voucher_code"`
+	// Line is the line's index within the voucher.
+	Line      int         `json:"line"`
+	Narration string      `json:"narration,omitempty"`
+	Debit     money.Money `json:"debit"`
+	Credit    money.Money `json:"credit"`
+	Balance   money.Money `json:"balance"`
+	Voided    bool        `json:"voided,omitempty"`
+}
+
+// AccountLedger is the ledger of one account: its opening balance, the lines
+// within the range in date order and the resulting closing balance.
+type AccountLedger struct {
+	Account account.Account `json:"account"`
+	Balance
+	Lines []LedgerLine `json:"lines"`
+}
+
+// GeneralLedger holds the ledgers of one or more accounts, ordered by code.
+type GeneralLedger struct {
+	From     calendar.Date    `json:"from"`
+	To       calendar.Date    `json:"to"`
+	Currency string           `json:"currency"`
+	Accounts []*AccountLedger `json:"accounts"`
+}
+
+// AccountLedger returns the general ledger of the account with the given code
+// from from to to, inclusive. Zero dates leave the range open.
+func (r *Reporter) AccountLedger(code string, from, to calendar.Date, opts LedgerOptions) (*GeneralLedger, error) {
+	c, err := r.loadChart()
+	if err != nil {
+		return nil, err
+	}
+	i, ok := c.byCode[code]
+	if !ok {
+		return nil, fmt.Errorf("account %s not found", code)
+	}
+	return r.generalLedger(c, []int{i}, from, to, opts)
+}
+
+// GroupLedger returns the general ledgers of every account anywhere under the
+// account group groupID. Accounts without a balance or lines are left out
+// unless r.ShowZero is set.
+func (r *Reporter) GroupLedger(groupID string, from, to calendar.Date, opts LedgerOptions) (*GeneralLedger, error) {
+	c, err := r.loadChart()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := c.groups[groupID]; !ok {
+		return nil, fmt.Errorf("account group %s not found", groupID)
+	}
+	var indexes []int
+	for i, acc := range c.accounts {
+		if c.under(acc, groupID) {
+			indexes = append(indexes, i)
+		}
+	}
+	gl, err := r.generalLedger(c, indexes, from, to, opts)
+	if err != nil || r.ShowZero {
+		return gl, err
+	}
+	kept := gl.Accounts[:0]
+	for _, al := range gl.Accounts {
+		if !al.IsZero() || len(al.Lines) > 0 {
+			kept = append(kept, al)
+		}
+	}
+	gl.Accounts = kept
+	return gl, nil
+}
+
+// generalLedger builds the ledgers of the accounts at the given chart indexes.
+func (r *Reporter) generalLedger(c *chart, indexes []int, from, to calendar.Date, opts LedgerOptions) (*GeneralLedger, error) {
+	gl := &GeneralLedger{From: from, To: to, Currency: r.Currency}
+	byIndex := make(map[int]*AccountLedger, len(indexes))
+	for _, i := range indexes {
+		al := &AccountLedger{Account: c.accounts[i], Balance: zeroBalance(r.Currency)}
+		byIndex[i] = al
+		gl.Accounts = append(gl.Accounts, al)
+	}
+
+	include := func(s journal.VoucherStatus) bool {
+		return s == journal.StatusPosted || (opts.IncludeVoided && s == journal.StatusVoided)
+	}
+	err := r.eachPosting(c, include, func(p posting) error {
+		al, ok := byIndex[p.index]
+		if !ok {
+			return nil
+		}
+		jv := p.voucher
+		if !to.IsZero() && jv.Date.After(to) {
+			return nil
+		}
+		voided := jv.VoucherStatus == journal.StatusVoided
+		if !from.IsZero() && jv.Date.Before(from) {
+			if voided {
+				return nil
+			}
+			return al.post(p.amount, true)
+		}
+
+		narration := jv.Items[p.line].Narration
+		if narration == "" {
+			narration = jv.Narration
+		}
+		zero := money.Zero(r.Currency)
+		line := LedgerLine{
+			Date: jv.Date, VoucherID: jv.ID, VoucherCode: jv.Code, Line: p.line,
+			Narration: narration, Debit: zero, Credit: zero, Voided: voided,
+		}
+		if p.amount.Sign() < 0 {
+			line.Credit = p.amount.Neg()
+		} else {
+			line.Debit = p.amount
+		}
+		al.Lines = append(al.Lines, line)
+		if voided {
+			return nil
+		}
+		return al.post(p.amount, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, al := range gl.Accounts {
+		sort.SliceStable(al.Lines, func(i, j int) bool {
+			a, b := al.Lines[i], al.Lines[j]
+			if !a.Date.Equal(b.Date) {
+				return a.Date.Before(b.Date)
+			}
+			return a.VoucherCode < b.VoucherCode
+		})
+		running := al.Opening
+		for i := range al.Lines {
+			l := &al.Lines[i]
+			if !l.Voided {
+				if running, err = running.Add(l.Debit); err != nil {
+					return nil, err
+				}
+				if running, err = running.Sub(l.Credit); err != nil {
+					return nil, err
+				}
+			}
+			l.Balance = running
+		}
+	}
+	return gl, nil
+}
+
+// WriteTo prints the general ledger as text. Voided lines are struck through.
+func (gl *GeneralLedger) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	row := func(date, code, narration, debit, credit string) string {
+		return fmt.Sprintf("%-10s %-14.14s %-30.30s %15s %15s", date, code, narration, debit, credit)
+	}
+	for i, al := range gl.Accounts {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s %s\n", al.Account.Code, al.Account.Name)
+		fmt.Fprintf(&b, "%s %15s\n", row("", "", "Opening balance", "", ""), al.Opening)
+		for _, l := range al.Lines {
+			text := row(l.Date.String(), l.VoucherCode, l.Narration, l.Debit.String(), l.Credit.String())
+			if l.Voided {
+				text = strike(text)
+			}
+			fmt.Fprintf(&b, "%s %15s\n", text, l.Balance)
+		}
+		fmt.Fprintf(&b, "%s %15s\n", row("", "", "Closing balance", al.Debit.String(), al.Credit.String()), al.Closing)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// strike strikes text through with combining long stroke overlays.
+func strike(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		b.WriteRune(r)
+		b.WriteRune('\u0336')
+	}
+	return b.String()
+}
@@ -0,0 +1,270 @@
+package reports
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/accountgroup"
+	"github.com/rohankarmacharya/TigIntegration/pkg/dimension"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// AccountLister lists accounts. *account.Service satisfies it.
+type AccountLister interface {
+	ListAccounts() ([]account.Account, error)
+}
+
+// GroupLister lists account groups. *accountgroup.Service satisfies it.
+type GroupLister interface {
+	ListAccountGroups() ([]accountgroup.AccountGroup, error)
+}
+
+// VoucherStreamer calls fn for every journal voucher. *journal.Service
+// satisfies it.
+type VoucherStreamer interface {
+	EachJournalVoucher(fn func(journal.JournalVoucher) error) error
+}
+
+// Reporter draws up financial reports from a Tigg namespace. Every report
+// loads the chart of accounts once and streams the vouchers, keeping only
+// running totals in memory.
+type Reporter struct {
+	accounts AccountLister
+	groups   GroupLister
+	vouchers VoucherStreamer
+
+	// Currency is the base currency reports are drawn up in. Foreign-currency
+	// vouchers are reported at their base amounts.
+	Currency string
+	// ShowZero keeps accounts with no balance and no movement in reports.
+	ShowZero bool
+	// Dimensions limits reports to the voucher lines carrying every listed
+	// dimension value. A filtered trial balance need not balance.
+	Dimensions dimension.Filter
+}
+
+func NewReporter(accounts AccountLister, groups GroupLister, vouchers VoucherStreamer) *Reporter {
+	return &Reporter{accounts: accounts, groups: groups, vouchers: vouchers, Currency: "NPR"}
+}
+
+// By returns a copy of r that also filters on the given dimension value.
+func (r *Reporter) By(name, value string) *Reporter {
+	by := *r
+	by.Dimensions = dimension.Filter{name: value}
+	for k, v := range r.Dimensions {
+		if k != name {
+			by.Dimensions[k] = v
+		}
+	}
+	return &by
+}
+
+// DimensionValues returns the values of the named dimension on the posted
+// lines r reports on, in sorted order. Untagged lines show up as "".
+func (r *Reporter) DimensionValues(name string) ([]string, error) {
+	c, err := r.loadChart()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	err = r.eachPosting(c, postedOnly, func(p posting) error {
+		seen[p.voucher.Items[p.line].Dimensions[name]] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(seen))
+	for v := range seen {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values, nil
+}
+
+// GroupBy draws up a report once per value of the named dimension, each over
+// only the lines carrying that value. For example
+//
+//	GroupBy(r, dimension.CostCenter, func(r *Reporter) (*TrialBalance, error) {
+//		return r.TrialBalance(from, to)
+//	})
+//
+// returns one trial balance per cost center, with untagged lines under "".
+func GroupBy[T any](r *Reporter, name string, report func(*Reporter) (T, error)) (map[string]T, error) {
+	values, err := r.DimensionValues(name)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]T, len(values))
+	for _, v := range values {
+		if out[v], err = report(r.By(name, v)); err != nil {
+			return nil, fmt.Errorf("%s %q: %w", name, v, err)
+		}
+	}
+	return out, nil
+}
+
+// chart is the chart of accounts with lookups by account ID and code.
+type chart struct {
+	accounts []account.Account
+	byID     map[string]int
+	byCode   map[string]int
+	groups   map[string]accountgroup.AccountGroup
+}
+
+func (r *Reporter) loadChart() (*chart, error) {
+	accounts, err := r.accounts.ListAccounts()
+	if err != nil {
+		return nil, fmt.Errorf("list accounts: %w", err)
+	}
+	groups, err := r.groups.ListAccountGroups()
+	if err != nil {
+		return nil, fmt.Errorf("list account groups: %w", err)
+	}
+
+	c := &chart{
+		accounts: append([]account.Account(nil), accounts...),
+		byID:     make(map[string]int, len(accounts)),
+		byCode:   make(map[string]int, len(accounts)),
+		groups:   make(map[string]accountgroup.AccountGroup, len(groups)),
+	}
+	sort.SliceStable(c.accounts, func(i, j int) bool { return c.accounts[i].Code < c.accounts[j].Code })
+	for i, acc := range c.accounts {
+		if acc.ID != "" {
+			c.byID[acc.ID] = i
+		}
+		if acc.Code != "" {
+			c.byCode[acc.Code] = i
+		}
+	}
+	for _, g := range groups {
+		c.groups[g.ID] = g
+	}
+	return c, nil
+}
+
+// lookup finds an account's index by ID, or failing that by code.
+func (c *chart) lookup(id, code string) (int, bool) {
+	if i, ok := c.byID[id]; ok {
+		return i, true
+	}
+	if i, ok := c.byCode[code]; ok {
+		return i, true
+	}
+	return 0, false
+}
+
+// groupOf returns the ID of the group an account sits directly under.
+func groupOf(acc account.Account) string {
+	if acc.ParentGroupID != nil && *acc.ParentGroupID != "" {
+		return *acc.ParentGroupID
+	}
+	return acc.PrimaryGroupID
+}
+
+// chain returns the groups from the top of the tree down to groupID.
+func (c *chart) chain(groupID string) []accountgroup.AccountGroup {
+	var out []accountgroup.AccountGroup
+	seen := map[string]bool{}
+	for groupID != "" && !seen[groupID] {
+		seen[groupID] = true
+		g, ok := c.groups[groupID]
+		if !ok {
+			break
+		}
+		out = append([]accountgroup.AccountGroup{g}, out...)
+		groupID = ""
+		if g.ParentGroupID != nil {
+			groupID = *g.ParentGroupID
+		}
+	}
+	return out
+}
+
+// under reports whether acc sits anywhere below the group groupID.
+func (c *chart) under(acc account.Account, groupID string) bool {
+	for _, g := range c.chain(groupOf(acc)) {
+		if g.ID == groupID {
+			return true
+		}
+	}
+	return false
+}
+
+// posting is one voucher line in the reporting currency, positive for a
+// debit and negative for a credit. index is the account's place in the chart,
+// which unlike its ID is always set.
+type posting struct {
+	voucher *journal.JournalVoucher
+	line    int
+	index   int
+	amount  money.Money
+}
+
+// eachPosting streams the vouchers and calls fn for every line of those whose
+// status is accepted by include, skipping lines r.Dimensions filters out. A line on an account missing from the chart,
+// or in a currency other than r.Currency, is an error.
+func (r *Reporter) eachPosting(c *chart, include func(journal.VoucherStatus) bool, fn func(posting) error) error {
+	return r.vouchers.EachJournalVoucher(func(jv journal.JournalVoucher) error {
+		if !include(jv.VoucherStatus) {
+			return nil
+		}
+		for i, item := range jv.Items {
+			if !r.Dimensions.Matches(item) {
+				continue
+			}
+			p, err := r.posting(c, &jv, i, item)
+			if err != nil {
+				return fmt.Errorf("voucher %s line %d: %w", jv.Code, i+1, err)
+			}
+			if err := fn(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *Reporter) posting(c *chart, jv *journal.JournalVoucher, i int, item journal.JournalVoucherItem) (posting, error) {
+	idx, ok := c.lookup(item.AccountID, item.AccountCode)
+	if !ok {
+		return posting{}, fmt.Errorf("unknown account %s", firstNonEmpty(item.AccountID, item.AccountCode))
+	}
+
+	amount, currency := item.Amount, jv.CurrencyCode
+	if jv.BaseCurrencyCode != "" && jv.BaseCurrencyCode != jv.CurrencyCode {
+		if item.BaseAmount == nil {
+			return posting{}, fmt.Errorf("foreign-currency line has no base amount")
+		}
+		amount, currency = *item.BaseAmount, jv.BaseCurrencyCode
+	}
+	if currency == "" {
+		currency = amount.Currency()
+	}
+	if currency != "" && currency != r.Currency {
+		return posting{}, fmt.Errorf("amount is in %s, not the reporting currency %s", currency, r.Currency)
+	}
+	amount = amount.WithCurrency(r.Currency)
+
+	switch item.TxnType {
+	case journal.TxnTypeDebit:
+	case journal.TxnTypeCredit:
+		amount = amount.Neg()
+	default:
+		return posting{}, fmt.Errorf("unknown txn type %q", item.TxnType)
+	}
+	return posting{voucher: jv, line: i, index: idx, amount: amount}, nil
+}
+
+func postedOnly(s journal.VoucherStatus) bool { return s == journal.StatusPosted }
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,565 @@
+package reports
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/accountgroup"
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/dimension"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+	"github.com/rohankarmacharya/TigIntegration/pkg/period"
+)
+
+func strPtr(s string) *string { return &s }
+
+func date(s string) calendar.Date { return calendar.MustParseDate(s, calendar.AD) }
+
+type stubBooks struct {
+	accounts []account.Account
+	groups   []accountgroup.AccountGroup
+	vouchers []journal.JournalVoucher
+}
+
+func (b *stubBooks) ListAccounts() ([]account.Account, error) { return b.accounts, nil }
+
+func (b *stubBooks) ListAccountGroups() ([]accountgroup.AccountGroup, error) { return b.groups, nil }
+
+func (b *stubBooks) EachJournalVoucher(fn func(journal.JournalVoucher) error) error {
+	for _, jv := range b.vouchers {
+		if err := fn(jv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dr(code, amount string) journal.JournalVoucherItem {
+	return journal.JournalVoucherItem{AccountCode: code, Amount: money.MustParse(amount, "NPR"), TxnType: journal.TxnTypeDebit}
+}
+
+func cr(code, amount string) journal.JournalVoucherItem {
+	return journal.JournalVoucherItem{AccountCode: code, Amount: money.MustParse(amount, "NPR"), TxnType: journal.TxnTypeCredit}
+}
+
+func voucher(code, d string, status journal.VoucherStatus, narration string, items ...journal.JournalVoucherItem) journal.JournalVoucher {
+	return journal.JournalVoucher{ID: "jv-" + code, Code: code, Date: date(d), CurrencyCode: "NPR", VoucherStatus: status, Narration: narration, Items: items}
+}
+
+// sampleBooks is a small trading business: 2023 is the prior year and 2024
+// the year under report.
+func sampleBooks() *stubBooks {
+	group := func(id, name, class, parent string) accountgroup.AccountGroup {
+		g := accountgroup.AccountGroup{ID: id, Name: name, AccountClassName: class}
+		if parent != "" {
+			g.ParentGroupID = strPtr(parent)
+		}
+		return g
+	}
+	acc := func(id, code, name, class, group string) account.Account {
+		return account.Account{ID: id, Code: code, Name: name, AccountClassName: class, PrimaryGroupID: group}
+	}
+	posted := journal.StatusPosted
+
+	return &stubBooks{
+		groups: []accountgroup.AccountGroup{
+			group("g-assets", "Assets", "Assets", ""),
+			group("g-current", "Current Assets", "Assets", "g-assets"),
+			group("g-bank", "Bank Accounts", "Assets", "g-current"),
+			group("g-debtors", "Sundry Debtors", "Assets", "g-current"),
+			group("g-fixed", "Fixed Assets", "Assets", "g-assets"),
+			group("g-liab", "Liabilities", "Liabilities", ""),
+			group("g-creditors", "Sundry Creditors", "Liabilities", "g-liab"),
+			group("g-loans", "Loans", "Liabilities", "g-liab"),
+			group("g-equity", "Capital", "Equity", ""),
+			group("g-income", "Income", "Income", ""),
+			group("g-exp", "Expenses", "Expenses", ""),
+			group("g-opex", "Operating Expenses", "Expenses", "g-exp"),
+		},
+		accounts: []account.Account{
+			acc("acc-cash", "CA0001", "Cash in Hand", "Assets", "g-current"),
+			acc("acc-bank", "BA0001", "NIC Asia", "Assets", "g-bank"),
+			acc("acc-himal", "AR0001", "Himal Traders", "Assets", "g-debtors"),
+			acc("acc-equip", "FA0001", "Office Equipment", "Assets", "g-fixed"),
+			acc("acc-accdep", "FA0002", "Accumulated Depreciation", "Assets", "g-fixed"),
+			acc("acc-everest", "AP0001", "Everest Supplies", "Liabilities", "g-creditors"),
+			acc("acc-loan", "LO0001", "Term Loan", "Liabilities", "g-loans"),
+			acc("acc-capital", "EQ0001", "Share Capital", "Equity", "g-equity"),
+			acc("acc-sales", "IN0001", "Sales", "Income", "g-income"),
+			acc("acc-rent", "EX0001", "Office Rent", "Expenses", "g-opex"),
+			acc("acc-dep", "EX0002", "Depreciation", "Expenses", "g-opex"),
+		},
+		vouchers: []journal.JournalVoucher{
+			voucher("JV-01", "2023-07-20", posted, "Capital introduced", dr("BA0001", "500000"), cr("EQ0001", "500000")),
+			voucher("JV-02", "2023-09-01", posted, "Sales to Himal", dr("AR0001", "100000"), cr("IN0001", "100000")),
+			voucher("JV-03", "2023-12-01", posted, "Rent", dr("EX0001", "30000"), cr("BA0001", "30000")),
+			voucher("JV-04", "2024-02-01", posted, "Laptops", dr("FA0001", "200000"), cr("BA0001", "200000")),
+			voucher("JV-05", "2024-03-01", posted, "Loan drawn", dr("BA0001", "150000"), cr("LO0001", "150000")),
+			voucher("JV-06", "2024-04-01", posted, "Sales to Himal", dr("AR0001", "250000"), cr("IN0001", "250000")),
+			voucher("JV-07", "2024-05-01", posted, "Himal paid", dr("BA0001", "180000"), cr("AR0001", "180000")),
+			voucher("JV-08", "2024-06-01", posted, "Rent on account", dr("EX0001", "60000"), cr("AP0001", "60000")),
+			voucher("JV-09", "2024-07-01", posted, "Paid Everest", dr("AP0001", "40000"), cr("BA0001", "40000")),
+			voucher("JV-10", "2024-08-01", journal.StatusVoided, "Wrong rent", dr("EX0001", "999"), cr("BA0001", "999")),
+			voucher("JV-11", "2024-09-01", journal.StatusDraft, "Cash sale", dr("CA0001", "5"), cr("IN0001", "5")),
+			voucher("JV-12", "2024-12-31", posted, "Depreciation", dr("EX0002", "20000"), cr("FA0002", "20000")),
+			voucher("JV-13", "2025-01-05", posted, "Sales", dr("BA0001", "1000"), cr("IN0001", "1000")),
+		},
+	}
+}
+
+func TestTrialBalance(t *testing.T) {
+	r := NewReporter(sampleBooks(), sampleBooks(), sampleBooks())
+	tb, err := r.TrialBalance(date("2024-01-01"), date("2024-12-31"))
+	if err != nil {
+		t.Fatalf("TrialBalance failed: %v", err)
+	}
+	if !tb.Balanced() {
+		t.Fatalf("expected the trial balance to balance, difference %s", tb.Difference())
+	}
+	if tb.Total.Debit.String() != "900000.00" || tb.DebitBalances.String() != "1040000.00" || tb.CreditBalances.String() != "1040000.00" {
+		t.Fatalf("unexpected totals %+v %s %s", tb.Total, tb.DebitBalances, tb.CreditBalances)
+	}
+
+	var classes []string
+	for _, c := range tb.Classes {
+		classes = append(classes, c.Name)
+	}
+	if got := strings.Join(classes, ","); got != "Assets,Liabilities,Equity,Income,Expenses" {
+		t.Fatalf("unexpected class order %s", got)
+	}
+
+	assets := tb.Classes[0]
+	if assets.Closing.String() != "910000.00" || len(assets.Children) != 1 || assets.Children[0].ID != "g-assets" {
+		t.Fatalf("unexpected assets node %+v", assets)
+	}
+	current := assets.Find(GroupNode, "g-current")
+	if current == nil || current.Closing.String() != "730000.00" || current.Children[0].Name != "Bank Accounts" {
+		t.Fatalf("unexpected current assets node %+v", current)
+	}
+	if cash := assets.Find(AccountNode, "acc-cash"); cash != nil {
+		t.Fatalf("expected the cash account, touched only by a draft, to be left out")
+	}
+
+	rent := tb.Classes[4].Find(AccountNode, "acc-rent")
+	if rent.Opening.String() != "30000.00" || rent.Debit.String() != "60000.00" || rent.Closing.String() != "90000.00" {
+		t.Fatalf("unexpected rent balance %+v", rent.Balance)
+	}
+	sales := tb.Classes[3].Find(AccountNode, "acc-sales")
+	if debit, credit := sales.Sides(); !debit.IsZero() || credit.String() != "350000.00" {
+		t.Fatalf("expected sales to show a 350000 credit, got %s/%s", debit, credit)
+	}
+
+	r.ShowZero = true
+	if tb, err = r.TrialBalance(calendar.Date{}, calendar.Date{}); err != nil {
+		t.Fatalf("TrialBalance failed: %v", err)
+	}
+	if cash := tb.Classes[0].Find(AccountNode, "acc-cash"); cash == nil {
+		t.Fatalf("expected ShowZero to keep the cash account")
+	}
+	if bank := tb.Classes[0].Find(AccountNode, "acc-bank"); !bank.Opening.IsZero() || bank.Closing.String() != "561000.00" {
+		t.Fatalf("expected an open range to include every voucher, got %+v", bank.Balance)
+	}
+
+	// Class names that differ only in spelling share one node.
+	books := sampleBooks()
+	books.accounts[3].AccountClassName = "Asset"
+	r = NewReporter(books, books, books)
+	if tb, err = r.TrialBalance(date("2024-01-01"), date("2024-12-31")); err != nil {
+		t.Fatalf("TrialBalance failed: %v", err)
+	}
+	if len(tb.Classes) != 5 || tb.Classes[0].Closing.String() != "910000.00" || tb.Classes[0].Find(AccountNode, "acc-equip") == nil {
+		t.Fatalf("expected Asset and Assets to be one class, got %d classes", len(tb.Classes))
+	}
+}
+
+func TestTrialBalanceErrors(t *testing.T) {
+	books := sampleBooks()
+	books.vouchers = append(books.vouchers, voucher("JV-99", "2024-01-01", journal.StatusPosted, "", dr("ZZ9999", "1"), cr("BA0001", "1")))
+	if _, err := NewReporter(books, books, books).TrialBalance(calendar.Date{}, calendar.Date{}); err == nil || !strings.Contains(err.Error(), "JV-99 line 1: unknown account ZZ9999") {
+		t.Fatalf("expected an unknown account error, got %v", err)
+	}
+
+	books = sampleBooks()
+	usd := voucher("JV-98", "2024-01-01", journal.StatusPosted, "", dr("BA0001", "1"), cr("IN0001", "1"))
+	usd.CurrencyCode = "USD"
+	books.vouchers = append(books.vouchers, usd)
+	if _, err := NewReporter(books, books, books).TrialBalance(calendar.Date{}, calendar.Date{}); err == nil || !strings.Contains(err.Error(), "not the reporting currency") {
+		t.Fatalf("expected a currency error, got %v", err)
+	}
+
+	books = sampleBooks()
+	books.vouchers = append(books.vouchers, voucher("JV-97", "2024-01-01", journal.StatusPosted, "", dr("BA0001", "10"), cr("IN0001", "9")))
+	tb, err := NewReporter(books, books, books).TrialBalance(calendar.Date{}, calendar.Date{})
+	if err != nil {
+		t.Fatalf("TrialBalance failed: %v", err)
+	}
+	if tb.Balanced() || tb.Difference().String() != "1.00" {
+		t.Fatalf("expected a difference of 1, got %s", tb.Difference())
+	}
+}
+
+func TestAccountsWithoutID(t *testing.T) {
+	// Accounts known only by code must not share a balance.
+	books := sampleBooks()
+	books.accounts[0].ID, books.accounts[1].ID = "", ""
+	r := NewReporter(books, books, books)
+	tb, err := r.TrialBalance(calendar.Date{}, calendar.Date{})
+	if err != nil {
+		t.Fatalf("TrialBalance failed: %v", err)
+	}
+	closing := map[string]string{}
+	tb.Classes[0].Walk(func(n *Node, _ int) {
+		if n.Kind == AccountNode {
+			closing[n.Code] = n.Closing.String()
+		}
+	})
+	if _, ok := closing["CA0001"]; ok || closing["BA0001"] != "561000.00" {
+		t.Fatalf("expected the bank balance on the bank account alone, got %v", closing)
+	}
+
+	books.vouchers = append(books.vouchers, voucher("JV-14", "2024-01-15", journal.StatusPosted, "Float", dr("CA0001", "50"), cr("BA0001", "50")))
+	gl, err := r.AccountLedger("CA0001", calendar.Date{}, calendar.Date{}, LedgerOptions{})
+	if err != nil {
+		t.Fatalf("AccountLedger failed: %v", err)
+	}
+	if len(gl.Accounts[0].Lines) != 1 || gl.Accounts[0].Closing.String() != "50.00" {
+		t.Fatalf("expected only the cash line in the cash ledger, got %+v", gl.Accounts[0])
+	}
+}
+
+func TestGeneralLedger(t *testing.T) {
+	books := sampleBooks()
+	// Vouchers arrive in no particular order.
+	books.vouchers = append(books.vouchers, voucher("JV-14", "2024-01-15", journal.StatusPosted, "Interest", dr("BA0001", "100"), cr("IN0001", "100")))
+	books.vouchers[len(books.vouchers)-1].Items[0].Narration = "Interest for December"
+	r := NewReporter(books, books, books)
+
+	gl, err := r.AccountLedger("BA0001", date("2024-01-01"), date("2024-12-31"), LedgerOptions{IncludeVoided: true})
+	if err != nil {
+		t.Fatalf("AccountLedger failed: %v", err)
+	}
+	bank := gl.Accounts[0]
+	if len(gl.Accounts) != 1 || bank.Opening.String() != "470000.00" || bank.Closing.String() != "560100.00" {
+		t.Fatalf("unexpected bank ledger %+v", bank.Balance)
+	}
+	if bank.Debit.String() != "330100.00" || bank.Credit.String() != "240000.00" {
+		t.Fatalf("unexpected bank totals %+v", bank.Balance)
+	}
+	var codes, balances []string
+	for _, l := range bank.Lines {
+		codes = append(codes, l.VoucherCode)
+		balances = append(balances, l.Balance.String())
+	}
+	if got := strings.Join(codes, ","); got != "JV-14,JV-04,JV-05,JV-07,JV-09,JV-10" {
+		t.Fatalf("unexpected line order %s", got)
+	}
+	if got := strings.Join(balances, ","); got != "470100.00,270100.00,420100.00,600100.00,560100.00,560100.00" {
+		t.Fatalf("unexpected running balances %s", got)
+	}
+	if first := bank.Lines[0]; first.Narration != "Interest for December" || first.Debit.String() != "100" || !first.Credit.IsZero() {
+		t.Fatalf("unexpected first line %+v", first)
+	}
+	if voided := bank.Lines[5]; !voided.Voided || voided.Credit.String() != "999" || voided.Narration != "Wrong rent" {
+		t.Fatalf("unexpected voided line %+v", voided)
+	}
+
+	var b strings.Builder
+	if _, err := gl.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	for _, want := range []string{"BA0001 NIC Asia", "Opening balance", "470000.00", strike("JV-10"), "Closing balance"} {
+		if !strings.Contains(b.String(), want) {
+			t.Fatalf("expected %q in\n%s", want, b.String())
+		}
+	}
+
+	if gl, err = r.GroupLedger("g-current", date("2024-01-01"), date("2024-12-31"), LedgerOptions{}); err != nil {
+		t.Fatalf("GroupLedger failed: %v", err)
+	}
+	if len(gl.Accounts) != 2 || gl.Accounts[0].Account.Code != "AR0001" || gl.Accounts[1].Account.Code != "BA0001" {
+		t.Fatalf("expected the debtor and bank ledgers, got %+v", gl.Accounts)
+	}
+	if n := len(gl.Accounts[1].Lines); n != 5 {
+		t.Fatalf("expected voided lines to be left out by default, got %d lines", n)
+	}
+
+	if _, err := r.AccountLedger("ZZ9999", calendar.Date{}, calendar.Date{}, LedgerOptions{}); err == nil {
+		t.Fatal("expected an unknown account to fail")
+	}
+	if _, err := r.GroupLedger("g-none", calendar.Date{}, calendar.Date{}, LedgerOptions{}); err == nil {
+		t.Fatal("expected an unknown group to fail")
+	}
+}
+
+func amounts(ms []money.Money) string {
+	var out []string
+	for _, m := range ms {
+		out = append(out, m.String())
+	}
+	return strings.Join(out, ",")
+}
+
+var (
+	year2024 = Column{Label: "2024", From: date("2024-01-01"), To: date("2024-12-31")}
+	year2023 = Column{Label: "2023", From: date("2023-01-01"), To: date("2023-12-31")}
+)
+
+func TestBalanceSheet(t *testing.T) {
+	r := NewReporter(sampleBooks(), sampleBooks(), sampleBooks())
+	bs, err := r.BalanceSheet(BalanceSheetLayout(), year2024, year2023)
+	if err != nil {
+		t.Fatalf("BalanceSheet failed: %v", err)
+	}
+	if !bs.Balanced() || len(bs.Warnings) != 0 {
+		t.Fatalf("expected the balance sheet to balance, got %s %v", amounts(bs.Result), bs.Warnings)
+	}
+	want := map[string]string{
+		"Assets":      "910000.00,570000.00",
+		"Liabilities": "170000.00,0.00",
+		"Equity":      "740000.00,570000.00",
+	}
+	for _, sec := range bs.Sections {
+		if got := amounts(sec.Totals); got != want[sec.Title] {
+			t.Fatalf("%s: expected %s, got %s", sec.Title, want[sec.Title], got)
+		}
+	}
+
+	equity := bs.Sections[2].Rows
+	if n := len(equity); n != 3 || equity[1].Kind != ResultNode || equity[2].Name != "Profit for the period" {
+		t.Fatalf("expected capital, retained earnings and profit rows, got %+v", equity)
+	}
+	if got := amounts(equity[1].Amounts) + "|" + amounts(equity[2].Amounts); got != "70000.00,0.00|170000.00,70000.00" {
+		t.Fatalf("unexpected retained earnings and profit %s", got)
+	}
+	if assets := bs.Sections[0].Rows; len(assets) != 2 || assets[0].ID != "g-current" || assets[1].ID != "g-fixed" || assets[0].Children[0].ID != "g-bank" {
+		t.Fatalf("expected the Assets group to be folded into the section, got %+v", assets)
+	}
+
+	bank := bs.Find(AccountNode, "acc-bank")
+	if bank == nil || len(bank.Refs) != 2 {
+		t.Fatalf("expected a drill-down reference per column, got %+v", bank)
+	}
+	gl, err := r.Drill(bank.Refs[0])
+	if err != nil {
+		t.Fatalf("Drill failed: %v", err)
+	}
+	if got := gl.Accounts[0].Closing; !got.Equal(bank.Amounts[0]) || len(gl.Accounts[0].Lines) != 4 {
+		t.Fatalf("expected the ledger to close at %s, got %s", bank.Amounts[0], got)
+	}
+
+	layout := BalanceSheetLayout()
+	layout.Sections = []Section{
+		{Title: "Current assets", Groups: []string{"Current Assets"}},
+		{Title: "Non-current assets", Groups: []string{"g-fixed"}},
+		{Title: "Equity", Classes: []string{"Capital"}, Credit: true},
+	}
+	bs, err = r.BalanceSheet(layout, year2024)
+	if err != nil {
+		t.Fatalf("BalanceSheet failed: %v", err)
+	}
+	if got := amounts(bs.Sections[0].Totals) + "|" + amounts(bs.Sections[1].Totals); got != "730000.00|180000.00" {
+		t.Fatalf("unexpected asset sections %s", got)
+	}
+	if first := bs.Sections[0].Rows[0]; first.ID != "g-bank" {
+		t.Fatalf("expected rows below the matched group, got %+v", first)
+	}
+	if bs.Balanced() || len(bs.Warnings) != 2 || !strings.Contains(bs.Warnings[0], "AP0001") {
+		t.Fatalf("expected warnings for the unplaced liabilities, got %v", bs.Warnings)
+	}
+
+	layout.ProfitSection = "Reserves"
+	if _, err := r.BalanceSheet(layout, year2024); err == nil {
+		t.Fatal("expected a missing profit section to fail")
+	}
+}
+
+func TestIncomeStatement(t *testing.T) {
+	r := NewReporter(sampleBooks(), sampleBooks(), sampleBooks())
+	is, err := r.IncomeStatement(IncomeStatementLayout(), year2024, year2023)
+	if err != nil {
+		t.Fatalf("IncomeStatement failed: %v", err)
+	}
+	if got := amounts(is.Result); got != "170000.00,70000.00" {
+		t.Fatalf("unexpected net profit %s", got)
+	}
+	if got := amounts(is.Sections[0].Totals) + "|" + amounts(is.Sections[1].Totals); got != "250000.00,100000.00|80000.00,30000.00" {
+		t.Fatalf("unexpected section totals %s", got)
+	}
+	opex := is.Find(GroupNode, "g-opex")
+	if opex == nil || len(opex.Children) != 2 || opex.Children[0].Code != "EX0001" {
+		t.Fatalf("unexpected operating expenses %+v", opex)
+	}
+	if ref := is.Find(AccountNode, "acc-rent").Refs[1]; ref.AccountCode != "EX0001" || ref.From.String() != "2023-01-01" {
+		t.Fatalf("unexpected ledger reference %+v", ref)
+	}
+}
+
+func TestPeriodColumns(t *testing.T) {
+	p, err := period.CalendarYear.PeriodOf(date("2024-03-15"), period.Monthly)
+	if err != nil {
+		t.Fatalf("PeriodOf failed: %v", err)
+	}
+	cols, err := PeriodColumns(p)
+	if err != nil {
+		t.Fatalf("PeriodColumns failed: %v", err)
+	}
+	var labels []string
+	for _, c := range cols {
+		labels = append(labels, c.Label+" "+c.From.String()+".."+c.To.String())
+	}
+	if got := strings.Join(labels, ","); got != "2024-M03 2024-03-01..2024-03-31,2024-M02 2024-02-01..2024-02-29,2023-M03 2023-03-01..2023-03-31" {
+		t.Fatalf("unexpected columns %s", got)
+	}
+}
+
+func sampleMapping() CashFlowMapping {
+	return CashFlowMapping{
+		Accounts: map[string]Activity{"CA0001": Cash, "FA0002": NonCash},
+		Groups: map[string]Activity{
+			"g-bank": Cash, "sundry debtors": Operating, "Sundry Creditors": Operating,
+			"Fixed Assets": Investing, "Loans": Financing, "Capital": Financing,
+		},
+	}
+}
+
+func TestCashFlow(t *testing.T) {
+	r := NewReporter(sampleBooks(), sampleBooks(), sampleBooks())
+	cf, err := r.CashFlow(sampleMapping(), year2024, year2023)
+	if err != nil {
+		t.Fatalf("CashFlow failed: %v", err)
+	}
+	if !cf.Reconciled() || len(cf.Warnings) != 0 {
+		t.Fatalf("expected the cash flow to reconcile, difference %s, warnings %v", amounts(cf.Difference), cf.Warnings)
+	}
+	for _, c := range []struct{ name, got, want string }{
+		{"net profit", amounts(cf.NetProfit), "170000.00,70000.00"},
+		{"non-cash items", amounts(cf.NonCashItems.Totals), "20000.00,0.00"},
+		{"working capital", amounts(cf.WorkingCapital.Totals), "-50000.00,-100000.00"},
+		{"operating", amounts(cf.Operating), "140000.00,-30000.00"},
+		{"investing", amounts(cf.Investing.Totals), "-200000.00,0.00"},
+		{"financing", amounts(cf.Financing.Totals), "150000.00,500000.00"},
+		{"net change", amounts(cf.NetChange), "90000.00,470000.00"},
+		{"opening cash", amounts(cf.OpeningCash), "470000.00,0.00"},
+		{"closing cash", amounts(cf.ClosingCash), "560000.00,470000.00"},
+	} {
+		if c.got != c.want {
+			t.Fatalf("%s: expected %s, got %s", c.name, c.want, c.got)
+		}
+	}
+	if rows := cf.NonCashItems.Rows; len(rows) != 1 || rows[0].Code != "FA0002" {
+		t.Fatalf("expected the account mapping to win over its group, got %+v", rows)
+	}
+	if rows := cf.WorkingCapital.Rows; len(rows) != 2 || rows[0].Code != "AP0001" || amounts(rows[1].Amounts) != "-70000.00,-100000.00" {
+		t.Fatalf("unexpected working capital rows %+v", rows)
+	}
+
+	mapping := sampleMapping()
+	delete(mapping.Groups, "Loans")
+	if cf, err = r.CashFlow(mapping, year2024, year2023); err != nil {
+		t.Fatalf("CashFlow failed: %v", err)
+	}
+	if cf.Reconciled() || amounts(cf.Difference) != "-150000.00,0.00" || len(cf.Warnings) != 1 || !strings.Contains(cf.Warnings[0], "LO0001") {
+		t.Fatalf("expected the unmapped loan to be reported, got %s %v", amounts(cf.Difference), cf.Warnings)
+	}
+
+	if _, err := r.CashFlow(CashFlowMapping{Groups: map[string]Activity{"Loans": Financing}}, year2024); err == nil {
+		t.Fatal("expected a mapping without cash accounts to fail")
+	}
+	if _, err := r.CashFlow(CashFlowMapping{Groups: map[string]Activity{"Loans": "LENDING"}}, year2024); err == nil {
+		t.Fatal("expected an unknown activity to fail")
+	}
+}
+
+func TestLoadCashFlowMapping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cashflow.json")
+	if err := os.WriteFile(path, []byte(`{"accounts": {"CA0001": "CASH"}, "groups": {"Loans": "FINANCING"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadCashFlowMapping(path)
+	if err != nil || m.Accounts["CA0001"] != Cash || m.Groups["Loans"] != Financing {
+		t.Fatalf("unexpected mapping %+v %v", m, err)
+	}
+	if err := os.WriteFile(path, []byte(`{"groups": {"Loans": "LENDING"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCashFlowMapping(path); err == nil || !strings.Contains(err.Error(), "LENDING") {
+		t.Fatalf("expected an unknown activity error, got %v", err)
+	}
+}
+
+func findAccount(tb *TrialBalance, id string) *Node {
+	for _, c := range tb.Classes {
+		if n := c.Find(AccountNode, id); n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+func TestDimensions(t *testing.T) {
+	books := sampleBooks()
+	tag := func(code, branch string) {
+		for i := range books.vouchers {
+			if books.vouchers[i].Code != code {
+				continue
+			}
+			for j := range books.vouchers[i].Items {
+				books.vouchers[i].Items[j].Dimensions = map[string]string{dimension.Branch: branch}
+			}
+		}
+	}
+	tag("JV-02", "PKR")
+	tag("JV-06", "KTM")
+	books.vouchers[6].Items[0].Dimensions = map[string]string{dimension.Branch: "KTM"}
+	r := NewReporter(books, books, books)
+
+	r.Dimensions = dimension.Filter{dimension.Branch: "KTM"}
+	tb, err := r.TrialBalance(calendar.Date{}, calendar.Date{})
+	if err != nil {
+		t.Fatalf("TrialBalance failed: %v", err)
+	}
+	if sales := findAccount(tb, "acc-sales"); sales == nil || sales.Closing.String() != "-250000.00" {
+		t.Fatalf("expected KTM sales of 250000, got %+v", sales)
+	}
+	// JV-07 is tagged on its bank line only, so the filtered books are out by
+	// the receipt.
+	if tb.Balanced() || tb.Difference().String() != "180000.00" {
+		t.Fatalf("expected a difference of 180000, got %s", tb.Difference())
+	}
+
+	r.Dimensions = nil
+	byBranch, err := GroupBy(r, dimension.Branch, func(r *Reporter) (*TrialBalance, error) {
+		return r.TrialBalance(calendar.Date{}, calendar.Date{})
+	})
+	if err != nil {
+		t.Fatalf("GroupBy failed: %v", err)
+	}
+	if len(byBranch) != 3 || byBranch["PKR"] == nil || byBranch["KTM"] == nil || byBranch[""] == nil {
+		t.Fatalf("expected trial balances for PKR, KTM and untagged lines, got %v", byBranch)
+	}
+	if sales := findAccount(byBranch["PKR"], "acc-sales"); sales.Closing.String() != "-100000.00" {
+		t.Fatalf("expected PKR sales of 100000, got %s", sales.Closing)
+	}
+	if sales := findAccount(byBranch[""], "acc-sales"); sales.Closing.String() != "-1000.00" {
+		t.Fatalf("expected untagged sales of 1000, got %s", sales.Closing)
+	}
+
+	// The groups add back up to the unfiltered books.
+	total, err := r.TrialBalance(calendar.Date{}, calendar.Date{})
+	if err != nil {
+		t.Fatalf("TrialBalance failed: %v", err)
+	}
+	sum := money.Zero("NPR")
+	for _, tb := range byBranch {
+		if sum, err = sum.Add(tb.Total.Debit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !sum.Equal(total.Total.Debit) {
+		t.Fatalf("expected grouped debits to add up to %s, got %s", total.Total.Debit, sum)
+	}
+}
@@ -0,0 +1,500 @@
+package reports
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/accountgroup"
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+	"github.com/rohankarmacharya/TigIntegration/pkg/period"
+)
+
+// ResultNode is a statement row computed from the income statement accounts
+// rather than read from an account: retained earnings and the period's
+// profit on a balance sheet.
+const ResultNode NodeKind = "result"
+
+// Column is one column of a financial statement. An income statement column
+// covers From to To. A balance sheet column is as at To; From splits the
+// profit into retained earnings before it and profit for the period after.
+type Column struct {
+	Label string        `json:"label"`
+	From  calendar.Date `json:"from"`
+	To    calendar.Date `json:"to"`
+}
+
+func periodColumn(p period.Period) Column {
+	return Column{Label: p.Key(), From: p.Start, To: p.End}
+}
+
+// PeriodColumns returns the columns for p, the period before it and the same
+// period of the previous fiscal year.
+func PeriodColumns(p period.Period) ([]Column, error) {
+	cfg := p.FiscalYear.Config
+	prior, err := cfg.PeriodOf(p.Start.AddDays(-1), p.Granularity)
+	if err != nil {
+		return nil, err
+	}
+	fy, err := cfg.FiscalYear(p.FiscalYear.Year - 1)
+	if err != nil {
+		return nil, err
+	}
+	periods, err := fy.Periods(p.Granularity)
+	if err != nil {
+		return nil, err
+	}
+	if p.Number < 1 || p.Number > len(periods) {
+		return nil, fmt.Errorf("period %s has no counterpart in %s", p.Key(), fy.Label())
+	}
+	return []Column{periodColumn(p), periodColumn(prior), periodColumn(periods[p.Number-1])}, nil
+}
+
+// YearColumns returns the columns for fy and the fiscal year before it.
+func YearColumns(fy period.FiscalYear) ([]Column, error) {
+	prior, err := fy.Config.FiscalYear(fy.Year - 1)
+	if err != nil {
+		return nil, err
+	}
+	return []Column{
+		{Label: fy.Label(), From: fy.Start, To: fy.End},
+		{Label: prior.Label(), From: prior.Start, To: prior.End},
+	}, nil
+}
+
+// Section is one section of a financial statement. An account belongs to a
+// section listing one of its groups, by ID or name, anywhere up its group
+// chain or as its PrimaryGroupName; the section naming the closest group
+// wins. Otherwise it belongs to the first section listing its class.
+type Section struct {
+	Title string `json:"title"`
+	// Classes are account class names. Singular and plural forms match, as
+	// do the synonyms account.NormalizeClass accepts.
+	Classes []string `json:"classes,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+	// Credit presents credit balances as positive amounts, as for
+	// liabilities, equity and income.
+	Credit bool `json:"credit,omitempty"`
+}
+
+// Layout arranges the sections of a financial statement.
+type Layout struct {
+	Title    string    `json:"title"`
+	Sections []Section `json:"sections"`
+	// ProfitSection is the title of the balance sheet section that carries
+	// retained earnings and the profit for the period.
+	ProfitSection    string `json:"profit_section,omitempty"`
+	RetainedEarnings string `json:"retained_earnings,omitempty"`
+	Profit           string `json:"profit,omitempty"`
+}
+
+// BalanceSheetLayout is a balance sheet with one section per class and the
+// profit carried into equity.
+func BalanceSheetLayout() Layout {
+	return Layout{
+		Title: "Balance Sheet",
+		Sections: []Section{
+			{Title: "Assets", Classes: []string{account.ClassAssets}},
+			{Title: "Liabilities", Classes: []string{account.ClassLiabilities}, Credit: true},
+			{Title: "Equity", Classes: []string{account.ClassEquity}, Credit: true},
+		},
+		ProfitSection:    "Equity",
+		RetainedEarnings: "Retained earnings",
+		Profit:           "Profit for the period",
+	}
+}
+
+// IncomeStatementLayout is an income statement with income and expenses.
+func IncomeStatementLayout() Layout {
+	return Layout{
+		Title: "Income Statement",
+		Sections: []Section{
+			{Title: "Income", Classes: []string{account.ClassIncome}, Credit: true},
+			{Title: "Expenses", Classes: []string{account.ClassExpenses}},
+		},
+	}
+}
+
+// LedgerRef points from a statement row back to the ledger lines behind one
+// of its amounts. Reporter.Drill resolves it.
+type LedgerRef struct {
+	AccountCode string        `json:"account_code"`
+	From        calendar.Date `json:"from"`
+	To          calendar.Date `json:"to"`
+}
+
+// StatementNode is a row of a financial statement with one amount per
+// column. Account rows carry a ledger reference per column.
+type StatementNode struct {
+	Kind     NodeKind         `json:"kind"`
+	ID       string           `json:"id,omitempty"`
+	Code     string           `json:"code,omitempty"`
+	Name     string           `json:"name"`
+	Amounts  []money.Money    `json:"amounts"`
+	Refs     []LedgerRef      `json:"refs,omitempty"`
+	Children []*StatementNode `json:"children,omitempty"`
+}
+
+// StatementSection is a section of a statement with its total per column.
+type StatementSection struct {
+	Title  string           `json:"title"`
+	Credit bool             `json:"credit,omitempty"`
+	Rows   []*StatementNode `json:"rows"`
+	Totals []money.Money    `json:"totals"`
+}
+
+// Statement is a balance sheet or income statement.
+type Statement struct {
+	Title    string              `json:"title"`
+	Currency string              `json:"currency"`
+	Columns  []Column            `json:"columns"`
+	Sections []*StatementSection `json:"sections"`
+	// Result is, per column, the credit sections' total less the debit
+	// sections'. It is the net profit on an income statement and zero on a
+	// balance sheet that balances.
+	Result []money.Money `json:"result"`
+	// Warnings lists accounts with a balance that no section takes.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Balanced reports whether Result is zero in every column, as it is for a
+// balance sheet of books that balance.
+func (st *Statement) Balanced() bool {
+	for _, m := range st.Result {
+		if !m.IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+// Find returns the first row in any section with the given kind and ID.
+func (st *Statement) Find(kind NodeKind, id string) *StatementNode {
+	for _, sec := range st.Sections {
+		for _, row := range sec.Rows {
+			if n := row.find(kind, id); n != nil {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+func (n *StatementNode) find(kind NodeKind, id string) *StatementNode {
+	if n.Kind == kind && n.ID == id {
+		return n
+	}
+	for _, c := range n.Children {
+		if found := c.find(kind, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// Drill returns the ledger lines behind a statement amount.
+func (r *Reporter) Drill(ref LedgerRef) (*GeneralLedger, error) {
+	return r.AccountLedger(ref.AccountCode, ref.From, ref.To, LedgerOptions{})
+}
+
+// BalanceSheet draws up a balance sheet as at the end of each column from the
+// accounts outside the income and expense classes. The income statement
+// accounts are carried into layout.ProfitSection as retained earnings and
+// profit for the period.
+func (r *Reporter) BalanceSheet(layout Layout, columns ...Column) (*Statement, error) {
+	return r.statement(layout, columns, true)
+}
+
+// IncomeStatement draws up an income statement over each column from the
+// income and expense accounts.
+func (r *Reporter) IncomeStatement(layout Layout, columns ...Column) (*Statement, error) {
+	return r.statement(layout, columns, false)
+}
+
+func (r *Reporter) statement(layout Layout, columns []Column, balanceSheet bool) (*Statement, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%s needs at least one column", layout.Title)
+	}
+	profitSection := -1
+	if balanceSheet {
+		for i, s := range layout.Sections {
+			if s.Title == layout.ProfitSection {
+				profitSection = i
+			}
+		}
+		if profitSection < 0 {
+			return nil, fmt.Errorf("%s has no section %q to carry the profit into", layout.Title, layout.ProfitSection)
+		}
+	}
+
+	c, err := r.loadChart()
+	if err != nil {
+		return nil, err
+	}
+	balances, err := r.columnBalances(c, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &Statement{Title: layout.Title, Currency: r.Currency, Columns: columns}
+	builders := make([]*sectionBuilder, len(layout.Sections))
+	for i, s := range layout.Sections {
+		builders[i] = &sectionBuilder{section: &StatementSection{Title: s.Title, Credit: s.Credit}, groups: map[string]*StatementNode{}}
+	}
+	retained, profit := r.zeros(len(columns)), r.zeros(len(columns))
+
+	for idx, acc := range c.accounts {
+		class, classErr := account.NormalizeClass(acc.AccountClassName)
+		pl := classErr == nil && (class == account.ClassIncome || class == account.ClassExpenses)
+
+		// Amounts are net debits until they are placed in a section.
+		amounts := r.zeros(len(columns))
+		for i := range columns {
+			b, ok := balances[i][idx]
+			if !ok {
+				continue
+			}
+			if balanceSheet {
+				amounts[i] = b.Closing
+			} else if amounts[i], err = b.Closing.Sub(b.Opening); err != nil {
+				return nil, err
+			}
+			if pl && balanceSheet {
+				if retained[i], err = retained[i].Add(b.Opening); err != nil {
+					return nil, err
+				}
+				if profit[i], err = profit[i].Add(b.Closing); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if classErr == nil && pl == balanceSheet {
+			continue
+		}
+		zero := allZero(amounts)
+		if zero && !r.ShowZero {
+			continue
+		}
+
+		chain := c.chain(groupOf(acc))
+		i, below := layout.place(acc, chain)
+		if i < 0 {
+			if !zero {
+				st.Warnings = append(st.Warnings, fmt.Sprintf("account %s %s (%s) has a balance but no section in the %s", acc.Code, acc.Name, acc.AccountClassName, layout.Title))
+			}
+			continue
+		}
+		n := &StatementNode{Kind: AccountNode, ID: acc.ID, Code: acc.Code, Name: acc.Name, Amounts: present(amounts, layout.Sections[i].Credit)}
+		for _, col := range columns {
+			n.Refs = append(n.Refs, LedgerRef{AccountCode: acc.Code, From: col.From, To: col.To})
+		}
+		builders[i].add(below, n)
+	}
+
+	for i, b := range builders {
+		root := &StatementNode{Kind: GroupNode, Children: b.rows}
+		if err := r.sumStatement(root, len(columns)); err != nil {
+			return nil, err
+		}
+		sec := b.section
+		sec.Rows = root.Children
+		if i == profitSection {
+			// profit holds the income statement accounts' balances at To;
+			// what they had at From is retained earnings.
+			for j := range columns {
+				if profit[j], err = profit[j].Sub(retained[j]); err != nil {
+					return nil, err
+				}
+			}
+			for _, res := range []struct {
+				name    string
+				amounts []money.Money
+			}{{layout.RetainedEarnings, retained}, {layout.Profit, profit}} {
+				if res.name == layout.RetainedEarnings && allZero(res.amounts) && !r.ShowZero {
+					continue
+				}
+				sec.Rows = append(sec.Rows, &StatementNode{Kind: ResultNode, Name: res.name, Amounts: present(res.amounts, sec.Credit)})
+			}
+		}
+		sec.Totals = r.zeros(len(columns))
+		for _, row := range sec.Rows {
+			if err := addAmounts(sec.Totals, row.Amounts); err != nil {
+				return nil, err
+			}
+		}
+		st.Sections = append(st.Sections, sec)
+	}
+
+	st.Result = r.zeros(len(columns))
+	for _, sec := range st.Sections {
+		if err := addAmounts(st.Result, present(sec.Totals, !sec.Credit)); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+// columnBalances totals posted lines per account for every column in one
+// pass over the vouchers.
+func (r *Reporter) columnBalances(c *chart, columns []Column) ([]map[int]*Balance, error) {
+	out := make([]map[int]*Balance, len(columns))
+	for i := range out {
+		out[i] = map[int]*Balance{}
+	}
+	err := r.eachPosting(c, postedOnly, func(p posting) error {
+		for i, col := range columns {
+			if err := r.postTo(out[i], p, col.From, col.To); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
+// place returns the index of the section acc belongs to and the part of its
+// group chain to show under the section, or -1.
+func (l Layout) place(acc account.Account, chain []accountgroup.AccountGroup) (int, []accountgroup.AccountGroup) {
+	for depth := len(chain) - 1; depth >= 0; depth-- {
+		for i, s := range l.Sections {
+			if s.hasGroup(chain[depth].ID, chain[depth].Name) {
+				return i, chain[depth+1:]
+			}
+		}
+	}
+	for i, s := range l.Sections {
+		if acc.PrimaryGroupName != "" && s.hasGroup("", acc.PrimaryGroupName) {
+			return i, nil
+		}
+	}
+	for i, s := range l.Sections {
+		if s.hasClass(acc.AccountClassName) {
+			if len(chain) > 0 && strings.EqualFold(chain[0].Name, s.Title) {
+				chain = chain[1:]
+			}
+			return i, chain
+		}
+	}
+	return -1, nil
+}
+
+func (s Section) hasGroup(id, name string) bool {
+	for _, g := range s.Groups {
+		if (id != "" && g == id) || strings.EqualFold(g, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Section) hasClass(class string) bool {
+	want, err := account.NormalizeClass(class)
+	for _, c := range s.Classes {
+		if strings.EqualFold(c, class) {
+			return true
+		}
+		if got, e := account.NormalizeClass(c); err == nil && e == nil && got == want {
+			return true
+		}
+	}
+	return false
+}
+
+// sectionBuilder grows the group tree of one statement section.
+type sectionBuilder struct {
+	section *StatementSection
+	rows    []*StatementNode
+	groups  map[string]*StatementNode
+}
+
+func (b *sectionBuilder) add(chain []accountgroup.AccountGroup, n *StatementNode) {
+	var parent *StatementNode
+	for _, g := range chain {
+		gn, ok := b.groups[g.ID]
+		if !ok {
+			gn = &StatementNode{Kind: GroupNode, ID: g.ID, Name: g.Name}
+			b.groups[g.ID] = gn
+			b.attach(parent, gn)
+		}
+		parent = gn
+	}
+	b.attach(parent, n)
+}
+
+func (b *sectionBuilder) attach(parent, n *StatementNode) {
+	if parent == nil {
+		b.rows = append(b.rows, n)
+		return
+	}
+	parent.Children = append(parent.Children, n)
+}
+
+// sumStatement fills in group amounts from their children and sorts the
+// children like a trial balance: groups by name, then accounts by code.
+func (r *Reporter) sumStatement(n *StatementNode, columns int) error {
+	if n.Kind != GroupNode {
+		return nil
+	}
+	n.Amounts = r.zeros(columns)
+	for _, c := range n.Children {
+		if err := r.sumStatement(c, columns); err != nil {
+			return err
+		}
+		if err := addAmounts(n.Amounts, c.Amounts); err != nil {
+			return fmt.Errorf("%s: %w", n.Name, err)
+		}
+	}
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		a, b := n.Children[i], n.Children[j]
+		if (a.Kind == AccountNode) != (b.Kind == AccountNode) {
+			return b.Kind == AccountNode
+		}
+		if a.Kind == AccountNode {
+			return a.Code < b.Code
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+	return nil
+}
+
+func (r *Reporter) zeros(n int) []money.Money {
+	out := make([]money.Money, n)
+	for i := range out {
+		out[i] = money.Zero(r.Currency)
+	}
+	return out
+}
+
+// present turns net debits into the amounts shown in a section, negating them
+// for credit sections.
+func present(amounts []money.Money, credit bool) []money.Money {
+	out := make([]money.Money, len(amounts))
+	for i, m := range amounts {
+		if credit {
+			m = m.Neg()
+		}
+		out[i] = m
+	}
+	return out
+}
+
+func addAmounts(dst, src []money.Money) error {
+	for i := range dst {
+		var err error
+		if dst[i], err = dst[i].Add(src[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func allZero(amounts []money.Money) bool {
+	for _, m := range amounts {
+		if !m.IsZero() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,311 @@
+package reports
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// Balance is an account's or a subtotal's position over a date range.
+// Opening and Closing are net debits, so a credit balance is negative; Debit
+// and Credit are the movements within the range, both positive.
+type Balance struct {
+	Opening money.Money `json:"opening"`
+	Debit   money.Money `json:"debit"`
+	Credit  money.Money `json:"credit"`
+	Closing money.Money `json:"closing"`
+}
+
+func zeroBalance(currency string) Balance {
+	z := money.Zero(currency)
+	return Balance{Opening: z, Debit: z, Credit: z, Closing: z}
+}
+
+// post adds a signed amount, to the opening balance when it falls before the
+// range and to the movements otherwise.
+func (b *Balance) post(amount money.Money, opening bool) error {
+	var err error
+	if opening {
+		b.Opening, err = b.Opening.Add(amount)
+	} else if amount.Sign() < 0 {
+		b.Credit, err = b.Credit.Add(amount.Neg())
+	} else {
+		b.Debit, err = b.Debit.Add(amount)
+	}
+	if err != nil {
+		return err
+	}
+	b.Closing, err = b.Closing.Add(amount)
+	return err
+}
+
+func (b *Balance) add(o Balance) error {
+	var err error
+	for _, f := range []struct{ dst, src *money.Money }{
+		{&b.Opening, &o.Opening}, {&b.Debit, &o.Debit}, {&b.Credit, &o.Credit}, {&b.Closing, &o.Closing},
+	} {
+		if *f.dst, err = f.dst.Add(*f.src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsZero reports whether there is no balance and no movement.
+func (b Balance) IsZero() bool {
+	return b.Opening.IsZero() && b.Debit.IsZero() && b.Credit.IsZero() && b.Closing.IsZero()
+}
+
+// Sides splits the closing balance into the debit and credit columns of a
+// trial balance. One of them is always zero.
+func (b Balance) Sides() (debit, credit money.Money) {
+	zero := money.Zero(b.Closing.Currency())
+	if b.Closing.Sign() < 0 {
+		return zero, b.Closing.Neg()
+	}
+	return b.Closing, zero
+}
+
+// NodeKind is what a report node stands for.
+type NodeKind string
+
+const (
+	ClassNode   NodeKind = "class"
+	GroupNode   NodeKind = "group"
+	AccountNode NodeKind = "account"
+)
+
+// Node is one row of a report tree: an account class, an account group or an
+// account. Class and group nodes carry the subtotal of their children.
+type Node struct {
+	Kind NodeKind `json:"kind"`
+	ID   string   `json:"id,omitempty"`
+	Code string   `json:"code,omitempty"`
+	Name string   `json:"name"`
+	Balance
+	Children []*Node `json:"children,omitempty"`
+}
+
+// Walk calls fn for n and every node below it, parents before children.
+// depth is 0 for n.
+func (n *Node) Walk(fn func(n *Node, depth int)) {
+	n.walk(fn, 0)
+}
+
+func (n *Node) walk(fn func(*Node, int), depth int) {
+	fn(n, depth)
+	for _, c := range n.Children {
+		c.walk(fn, depth+1)
+	}
+}
+
+// Find returns the node below n, n included, with the given kind and ID.
+func (n *Node) Find(kind NodeKind, id string) *Node {
+	var found *Node
+	n.Walk(func(c *Node, _ int) {
+		if found == nil && c.Kind == kind && c.ID == id {
+			found = c
+		}
+	})
+	return found
+}
+
+// TrialBalance lists every account's balance, rolled up through the account
+// group tree under one node per account class.
+type TrialBalance struct {
+	From     calendar.Date `json:"from"`
+	To       calendar.Date `json:"to"`
+	Currency string        `json:"currency"`
+	Classes  []*Node       `json:"classes"`
+	// Total sums every account. Its Closing is zero when the books balance.
+	Total Balance `json:"total"`
+	// DebitBalances and CreditBalances are the totals of the debit and credit
+	// columns of the closing balances.
+	DebitBalances  money.Money `json:"debit_balances"`
+	CreditBalances money.Money `json:"credit_balances"`
+}
+
+// Balanced reports whether debits equal credits, both for the movements
+// within the range and for the closing balances.
+func (tb *TrialBalance) Balanced() bool {
+	return tb.Total.Opening.IsZero() && tb.Total.Debit.Equal(tb.Total.Credit) && tb.Difference().IsZero()
+}
+
+// Difference returns DebitBalances - CreditBalances.
+func (tb *TrialBalance) Difference() money.Money {
+	diff, _ := tb.DebitBalances.Sub(tb.CreditBalances)
+	return diff
+}
+
+// TrialBalance totals posted voucher lines per account from from to to,
+// inclusive. Lines dated before from make up the opening balances. A zero from
+// starts at the first voucher and a zero to runs to the last.
+func (r *Reporter) TrialBalance(from, to calendar.Date) (*TrialBalance, error) {
+	c, err := r.loadChart()
+	if err != nil {
+		return nil, err
+	}
+	balances, err := r.balances(c, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	tb := &TrialBalance{From: from, To: to, Currency: r.Currency, Total: zeroBalance(r.Currency)}
+	if tb.Classes, err = r.tree(c, balances); err != nil {
+		return nil, err
+	}
+	for _, class := range tb.Classes {
+		if err := tb.Total.add(class.Balance); err != nil {
+			return nil, err
+		}
+	}
+
+	tb.DebitBalances, tb.CreditBalances = money.Zero(r.Currency), money.Zero(r.Currency)
+	for _, b := range balances {
+		debit, credit := b.Sides()
+		if tb.DebitBalances, err = tb.DebitBalances.Add(debit); err != nil {
+			return nil, err
+		}
+		if tb.CreditBalances, err = tb.CreditBalances.Add(credit); err != nil {
+			return nil, err
+		}
+	}
+	return tb, nil
+}
+
+// balances totals posted lines per chart index up to to, splitting them at
+// from.
+func (r *Reporter) balances(c *chart, from, to calendar.Date) (map[int]*Balance, error) {
+	balances := map[int]*Balance{}
+	err := r.eachPosting(c, postedOnly, func(p posting) error {
+		return r.postTo(balances, p, from, to)
+	})
+	return balances, err
+}
+
+// postTo adds p to its account's balance unless it falls after to. Zero dates
+// leave the range open.
+func (r *Reporter) postTo(balances map[int]*Balance, p posting, from, to calendar.Date) error {
+	date := p.voucher.Date
+	if !to.IsZero() && date.After(to) {
+		return nil
+	}
+	b, ok := balances[p.index]
+	if !ok {
+		zb := zeroBalance(r.Currency)
+		b = &zb
+		balances[p.index] = b
+	}
+	return b.post(p.amount, !from.IsZero() && date.Before(from))
+}
+
+// tree builds one node per account class holding the group tree down to the
+// accounts, with subtotals. Accounts without a balance are left out unless
+// r.ShowZero is set.
+func (r *Reporter) tree(c *chart, balances map[int]*Balance) ([]*Node, error) {
+	var classes []*Node
+	classByName := map[string]*Node{}
+	groupNodes := map[string]*Node{}
+
+	for i, acc := range c.accounts {
+		b := zeroBalance(r.Currency)
+		if bp, ok := balances[i]; ok {
+			b = *bp
+		}
+		if b.IsZero() && !r.ShowZero {
+			continue
+		}
+
+		// "Asset" and "Assets" are one class; unrecognised names stand alone.
+		name := acc.AccountClassName
+		if n, err := account.NormalizeClass(name); err == nil {
+			name = n
+		}
+		class, ok := classByName[name]
+		if !ok {
+			class = &Node{Kind: ClassNode, Name: name}
+			classByName[name] = class
+			classes = append(classes, class)
+		}
+		parent := class
+		for _, g := range c.chain(groupOf(acc)) {
+			key := name + "\x00" + g.ID
+			n, ok := groupNodes[key]
+			if !ok {
+				n = &Node{Kind: GroupNode, ID: g.ID, Name: g.Name}
+				groupNodes[key] = n
+				parent.Children = append(parent.Children, n)
+			}
+			parent = n
+		}
+		parent.Children = append(parent.Children, accountNode(acc, b))
+	}
+
+	sort.SliceStable(classes, func(i, j int) bool {
+		ri, rj := classRank(classes[i].Name), classRank(classes[j].Name)
+		if ri != rj {
+			return ri < rj
+		}
+		return classes[i].Name < classes[j].Name
+	})
+	for _, class := range classes {
+		if err := r.subtotal(class); err != nil {
+			return nil, err
+		}
+	}
+	return classes, nil
+}
+
+func accountNode(acc account.Account, b Balance) *Node {
+	return &Node{Kind: AccountNode, ID: acc.ID, Code: acc.Code, Name: acc.Name, Balance: b}
+}
+
+// subtotal fills in the balances of n's descendants and n from its accounts,
+// and sorts the children: groups by name, then accounts by code.
+func (r *Reporter) subtotal(n *Node) error {
+	if n.Kind == AccountNode {
+		return nil
+	}
+	n.Balance = zeroBalance(r.Currency)
+	for _, c := range n.Children {
+		if err := r.subtotal(c); err != nil {
+			return err
+		}
+		if err := n.Balance.add(c.Balance); err != nil {
+			return fmt.Errorf("%s: %w", n.Name, err)
+		}
+	}
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		a, b := n.Children[i], n.Children[j]
+		if (a.Kind == AccountNode) != (b.Kind == AccountNode) {
+			return b.Kind == AccountNode
+		}
+		if a.Kind == AccountNode {
+			return a.Code < b.Code
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+	return nil
+}
+
+var classOrder = map[string]int{
+	account.ClassAssets:      0,
+	account.ClassLiabilities: 1,
+	account.ClassEquity:      2,
+	account.ClassIncome:      3,
+	account.ClassExpenses:    4,
+}
+
+// classRank orders account classes as they appear on a trial balance, with
+// unrecognised classes last.
+func classRank(class string) int {
+	c, err := account.NormalizeClass(class)
+	if err != nil {
+		return len(classOrder)
+	}
+	return classOrder[c]
+}
@@ -0,0 +1,68 @@
+package sequence
+
+import (
+	"sort"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+)
+
+// AuditReport compares the codes issued for a Key with the vouchers in Tigg.
+type AuditReport struct {
+	Key    Key
+	Issued int64
+
+	// Missing lists issued codes that no voucher uses.
+	Missing []string
+	// Duplicates maps a code to the IDs of every voucher that uses it.
+	Duplicates map[string][]string
+	// Unissued lists codes in the key's format that were never handed out,
+	// e.g. typed by hand or allocated from another store.
+	Unissued []string
+}
+
+// Clean reports whether the audit found no problems.
+func (r *AuditReport) Clean() bool {
+	return len(r.Missing) == 0 && len(r.Duplicates) == 0 && len(r.Unissued) == 0
+}
+
+// Audit checks the vouchers returned by ListJournalVouchers against the codes
+// issued for key. Voided vouchers still count as using their code.
+func (g *Generator) Audit(key Key, vouchers []journal.JournalVoucher) (*AuditReport, error) {
+	issued, err := g.store.Current(key)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AuditReport{Key: key, Issued: issued, Duplicates: map[string][]string{}}
+	used := map[int64][]string{}
+	for _, jv := range vouchers {
+		n, ok := numberOf(key, jv.Code)
+		if !ok {
+			continue
+		}
+		used[n] = append(used[n], jv.ID)
+	}
+
+	for n := int64(1); n <= issued; n++ {
+		if len(used[n]) == 0 {
+			report.Missing = append(report.Missing, g.Format(key, n))
+		}
+	}
+
+	numbers := make([]int64, 0, len(used))
+	for n := range used {
+		numbers = append(numbers, n)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	for _, n := range numbers {
+		ids := used[n]
+		code := g.Format(key, n)
+		if len(ids) > 1 {
+			report.Duplicates[code] = ids
+		}
+		if n < 1 || n > issued {
+			report.Unissued = append(report.Unissued, code)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,105 @@
+package sequence
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Key identifies one numbering sequence.
+type Key struct {
+	Namespace   string
+	FiscalYear  string // e.g. "2081/82"
+	VoucherType string // e.g. "JV"; also used as the code prefix
+}
+
+func (k Key) String() string {
+	return fmt.Sprintf("%s/%s/%s", k.Namespace, k.FiscalYear, k.VoucherType)
+}
+
+// Store persists the last number issued for each Key. Increment must be atomic:
+// concurrent callers, including other processes sharing the store, must never
+// receive the same number.
+type Store interface {
+	Increment(key Key) (int64, error)
+	Current(key Key) (int64, error)
+}
+
+// Generator hands out voucher codes such as JV-2081/82-000123, numbered per
+// namespace, fiscal year and voucher type.
+type Generator struct {
+	store Store
+
+	// Width is the zero-padded width of the number part. Defaults to 6.
+	Width int
+}
+
+func NewGenerator(store Store) *Generator {
+	return &Generator{store: store, Width: 6}
+}
+
+// Next allocates the next code for key.
+func (g *Generator) Next(key Key) (string, error) {
+	if key.VoucherType == "" || key.FiscalYear == "" {
+		return "", fmt.Errorf("sequence key %s needs a voucher type and fiscal year", key)
+	}
+	n, err := g.store.Increment(key)
+	if err != nil {
+		return "", err
+	}
+	return g.Format(key, n), nil
+}
+
+// Format renders number n of key as a code without allocating it.
+func (g *Generator) Format(key Key, n int64) string {
+	return codePrefix(key) + fmt.Sprintf("%0*d", g.Width, n)
+}
+
+func codePrefix(key Key) string {
+	return key.VoucherType + "-" + key.FiscalYear + "-"
+}
+
+// ParseCode splits a code produced by Format into voucher type, fiscal year
+// and number. The number and fiscal year are the last two "-"-separated parts,
+// so voucher types may contain "-" but fiscal year labels may not. ok is false
+// for codes that do not follow the pattern.
+func ParseCode(code string) (voucherType, fiscalYear string, n int64, ok bool) {
+	rest, number, ok := cutLast(code, "-")
+	if !ok {
+		return "", "", 0, false
+	}
+	voucherType, fiscalYear, ok = cutLast(rest, "-")
+	if !ok || voucherType == "" || fiscalYear == "" {
+		return "", "", 0, false
+	}
+	n, ok = parseNumber(number)
+	if !ok {
+		return "", "", 0, false
+	}
+	return voucherType, fiscalYear, n, true
+}
+
+// numberOf returns the number of a code Format produced for key.
+func numberOf(key Key, code string) (int64, bool) {
+	rest, ok := strings.CutPrefix(code, codePrefix(key))
+	if !ok {
+		return 0, false
+	}
+	return parseNumber(rest)
+}
+
+func cutLast(s, sep string) (before, after string, ok bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+func parseNumber(s string) (int64, bool) {
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	return n, err == nil
+}
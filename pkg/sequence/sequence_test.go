@@ -0,0 +1,303 @@
+package sequence
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+)
+
+var testKey = Key{Namespace: "ripplebytes", FiscalYear: "2081/82", VoucherType: "JV"}
+
+func TestNextFormatsCode(t *testing.T) {
+	g := NewGenerator(NewMemoryStore())
+
+	code, err := g.Next(testKey)
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	if code != "JV-2081/82-000001" {
+		t.Fatalf("expected JV-2081/82-000001, got %s", code)
+	}
+
+	for _, c := range []struct {
+		code, vt, fy string
+		n            int64
+	}{
+		{code, "JV", "2081/82", 1},
+		{"JV-2024-000042", "JV", "2024", 42},
+		{"PUR-RET-2081/82-7", "PUR-RET", "2081/82", 7},
+	} {
+		vt, fy, n, ok := ParseCode(c.code)
+		if !ok || vt != c.vt || fy != c.fy || n != c.n {
+			t.Errorf("ParseCode(%s) = %s %s %d %v", c.code, vt, fy, n, ok)
+		}
+	}
+	for _, bad := range []string{"manual entry", "JV-000001", "JV-2024-", "JV-2024-12a"} {
+		if _, _, _, ok := ParseCode(bad); ok {
+			t.Errorf("expected ParseCode(%s) to fail", bad)
+		}
+	}
+
+	other := testKey
+	other.FiscalYear = "2082/83"
+	if code, _ := g.Next(other); code != "JV-2082/83-000001" {
+		t.Fatalf("expected a fresh sequence per fiscal year, got %s", code)
+	}
+}
+
+func TestConcurrentAllocation(t *testing.T) {
+	db, err := sql.Open("fakesql", t.TempDir())
+	if err != nil {
+		t.Fatalf("open fakesql: %v", err)
+	}
+	defer db.Close()
+	sqlStore, err := NewSQLStore(db)
+	if err != nil {
+		t.Fatalf("NewSQLStore failed: %v", err)
+	}
+
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"file":   NewFileStore(filepath.Join(t.TempDir(), "sequences.json")),
+		"sql":    sqlStore,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			g := NewGenerator(store)
+
+			var mu sync.Mutex
+			seen := map[string]bool{}
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					code, err := g.Next(testKey)
+					if err != nil {
+						t.Errorf("Next failed: %v", err)
+						return
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					if seen[code] {
+						t.Errorf("code %s issued twice", code)
+					}
+					seen[code] = true
+				}()
+			}
+			wg.Wait()
+
+			if n, _ := store.Current(testKey); n != 20 {
+				t.Fatalf("expected 20 codes issued, got %d", n)
+			}
+		})
+	}
+}
+
+// fakeSQL is a database/sql driver that understands just the statements
+// SQLStore sends. Transactions run one at a time, as on SQLite.
+type fakeSQL struct {
+	mu  sync.Mutex
+	dbs map[string]*fakeDB
+}
+
+type fakeDB struct {
+	tx   sync.Mutex
+	mu   sync.Mutex
+	rows map[string]int64
+}
+
+func init() { sql.Register("fakesql", &fakeSQL{dbs: map[string]*fakeDB{}}) }
+
+func (d *fakeSQL) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	db, ok := d.dbs[name]
+	if !ok {
+		db = &fakeDB{rows: map[string]int64{}}
+		d.dbs[name] = db
+	}
+	return &fakeConn{db: db}, nil
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: strings.Fields(query)[0]}, nil
+}
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.tx.Lock()
+	return fakeTx{c.db}, nil
+}
+
+type fakeTx struct{ db *fakeDB }
+
+func (tx fakeTx) Commit() error   { tx.db.tx.Unlock(); return nil }
+func (tx fakeTx) Rollback() error { tx.db.tx.Unlock(); return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	switch s.query {
+	case "CREATE":
+	case "INSERT":
+		if _, ok := s.db.rows[fmt.Sprint(args[:3])]; !ok {
+			s.db.rows[fmt.Sprint(args[:3])] = 0
+		}
+	case "UPDATE":
+		s.db.rows[fmt.Sprint(args)]++
+	default:
+		return nil, fmt.Errorf("fakesql: unexpected exec %s", s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if s.query != "SELECT" {
+		return nil, fmt.Errorf("fakesql: unexpected query %s", s.query)
+	}
+	n, ok := s.db.rows[fmt.Sprint(args)]
+	return &fakeRows{n: n, left: ok}, nil
+}
+
+type fakeRows struct {
+	n    int64
+	left bool
+}
+
+func (r *fakeRows) Columns() []string { return []string{"last_value"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if !r.left {
+		return io.EOF
+	}
+	dest[0], r.left = r.n, false
+	return nil
+}
+
+func TestStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sequences.json")
+	store := NewFileStore(path)
+	store.LockTimeout = 100 * time.Millisecond
+	if err := os.WriteFile(path+".lock", nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresh lock held by someone else is waited for and left in place.
+	if _, err := store.Increment(testKey); err == nil {
+		t.Fatal("expected a held lock to time out")
+	}
+	if _, err := os.Stat(path + ".lock"); err != nil {
+		t.Fatalf("expected the held lock to survive: %v", err)
+	}
+
+	old := time.Now().Add(-2 * store.StaleLock)
+	if err := os.Chtimes(path+".lock", old, old); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := store.Increment(testKey); err != nil || n != 1 {
+		t.Fatalf("expected a stale lock to be broken, got %d %v", n, err)
+	}
+	if matches, _ := filepath.Glob(path + ".lock*"); len(matches) != 0 {
+		t.Fatalf("expected no lock files left, got %v", matches)
+	}
+}
+
+func TestLockOwnership(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sequences.json")
+	store := NewFileStore(path)
+	store.StaleLock = 40 * time.Millisecond
+
+	// A holder outliving StaleLock keeps its lock fresh.
+	l, err := store.lock()
+	if err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	time.Sleep(3 * store.StaleLock)
+	if info, err := os.Stat(path + ".lock"); err != nil || time.Since(info.ModTime()) > store.StaleLock {
+		t.Fatalf("expected the held lock to be refreshed, got %v", err)
+	}
+
+	// Releasing leaves alone a lock that has passed to someone else.
+	if err := os.WriteFile(path+".lock", []byte("someone else"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if l.held() {
+		t.Fatal("expected the replaced lock not to be held")
+	}
+	l.release()
+	if b, err := os.ReadFile(path + ".lock"); err != nil || string(b) != "someone else" {
+		t.Fatalf("expected the other holder's lock to survive, got %q %v", b, err)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	g := NewGenerator(NewMemoryStore())
+	for i := 0; i < 4; i++ {
+		if _, err := g.Next(testKey); err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+	}
+
+	vouchers := []journal.JournalVoucher{
+		{ID: "a", Code: "JV-2081/82-000001"},
+		{ID: "b", Code: "JV-2081/82-000002"},
+		{ID: "c", Code: "JV-2081/82-000002"},
+		{ID: "d", Code: "JV-2081/82-000004"},
+		{ID: "e", Code: "JV-2081/82-000009"},
+		{ID: "f", Code: "JV-2080/81-000003"},
+		{ID: "g", Code: "manual entry"},
+	}
+
+	report, err := g.Audit(testKey, vouchers)
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if report.Clean() {
+		t.Fatal("expected audit to find problems")
+	}
+	if strings.Join(report.Missing, ",") != "JV-2081/82-000003" {
+		t.Errorf("unexpected missing codes: %v", report.Missing)
+	}
+	if ids := report.Duplicates["JV-2081/82-000002"]; len(ids) != 2 || len(report.Duplicates) != 1 {
+		t.Errorf("unexpected duplicates: %v", report.Duplicates)
+	}
+	if strings.Join(report.Unissued, ",") != "JV-2081/82-000009" {
+		t.Errorf("unexpected unissued codes: %v", report.Unissued)
+	}
+
+	// Calendar-year labels audit the same way.
+	calendarKey := Key{Namespace: "ripplebytes", FiscalYear: "2024", VoucherType: "JV"}
+	for i := 0; i < 2; i++ {
+		if _, err := g.Next(calendarKey); err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+	}
+	report, err = g.Audit(calendarKey, []journal.JournalVoucher{{ID: "a", Code: "JV-2024-000001"}, {ID: "b", Code: "JV-2024-000002"}})
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if !report.Clean() {
+		t.Errorf("expected a clean calendar-year audit, got %+v", report)
+	}
+}
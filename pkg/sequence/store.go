@@ -0,0 +1,306 @@
+package sequence
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/internal/jsonfile"
+)
+
+// MemoryStore keeps counters in memory. Safe for concurrent use within a process.
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[Key]int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: map[Key]int64{}}
+}
+
+func (s *MemoryStore) Increment(key Key) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters[key]++
+	return s.counters[key], nil
+}
+
+func (s *MemoryStore) Current(key Key) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counters[key], nil
+}
+
+// FileStore keeps counters in a JSON file. A lock file next to it serialises
+// access between processes, and each increment rewrites the file in full.
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+
+	// LockTimeout bounds how long Increment waits for another process.
+	LockTimeout time.Duration
+	// StaleLock is the age after which a leftover lock file is removed.
+	StaleLock time.Duration
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path, LockTimeout: 10 * time.Second, StaleLock: time.Minute}
+}
+
+type fileEntry struct {
+	Namespace   string `json:"namespace"`
+	FiscalYear  string `json:"fiscal_year"`
+	VoucherType string `json:"voucher_type"`
+	Last        int64  `json:"last"`
+}
+
+func (s *FileStore) Increment(key Key) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer l.release()
+
+	entries, err := s.load()
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	found := false
+	for i := range entries {
+		if entries[i].key() == key {
+			entries[i].Last++
+			n, found = entries[i].Last, true
+			break
+		}
+	}
+	if !found {
+		n = 1
+		entries = append(entries, fileEntry{Namespace: key.Namespace, FiscalYear: key.FiscalYear, VoucherType: key.VoucherType, Last: n})
+	}
+	if !l.held() {
+		return 0, fmt.Errorf("lost sequence lock %s", l.path)
+	}
+	if err := jsonfile.Write(s.path, entries); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *FileStore) Current(key Key) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		if e.key() == key {
+			return e.Last, nil
+		}
+	}
+	return 0, nil
+}
+
+func (e fileEntry) key() Key {
+	return Key{Namespace: e.Namespace, FiscalYear: e.FiscalYear, VoucherType: e.VoucherType}
+}
+
+func (s *FileStore) load() ([]fileEntry, error) {
+	var entries []fileEntry
+	if err := jsonfile.Read(s.path, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// fileLock is a held path.lock. The file holds a token naming its holder, so
+// the holder only ever refreshes or removes its own lock.
+type fileLock struct {
+	path  string
+	token string
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// lock creates path.lock exclusively, waiting for other holders. While held,
+// the lock's modification time is refreshed well within StaleLock, so only a
+// holder that has died is taken for stale.
+func (s *FileStore) lock() (*fileLock, error) {
+	lockPath := s.path + ".lock"
+	token, err := lockToken()
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(s.LockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			_, werr := f.WriteString(token)
+			if cerr := f.Close(); werr == nil {
+				werr = cerr
+			}
+			if werr != nil {
+				os.Remove(lockPath)
+				return nil, werr
+			}
+			l := &fileLock{path: lockPath, token: token, stop: make(chan struct{}), done: make(chan struct{})}
+			go l.heartbeat(s.StaleLock / 4)
+			return l, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > s.StaleLock {
+			if err := s.breakLock(lockPath); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for sequence lock %s", lockPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func lockToken() (string, error) {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%s", os.Getpid(), hex.EncodeToString(nonce)), nil
+}
+
+// held reports whether the lock file is still this holder's.
+func (l *fileLock) held() bool {
+	b, err := os.ReadFile(l.path)
+	return err == nil && string(b) == l.token
+}
+
+func (l *fileLock) heartbeat(every time.Duration) {
+	defer close(l.done)
+	if every <= 0 {
+		every = time.Second
+	}
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-t.C:
+			if !l.held() {
+				return
+			}
+			now := time.Now()
+			os.Chtimes(l.path, now, now)
+		}
+	}
+}
+
+// release stops the heartbeat and removes the lock file if it is still ours.
+func (l *fileLock) release() {
+	close(l.stop)
+	<-l.done
+	if l.held() {
+		os.Remove(l.path)
+	}
+}
+
+// breakLock removes a stale lock file. Another process may refresh or replace
+// the lock between the caller's check and the removal, so the lock is first
+// renamed aside, which only one process can do, and checked again: a fresh
+// lock is linked back. If the path has been taken meanwhile, two processes
+// would believe they hold the lock, so breakLock fails instead; the holder
+// whose lock was moved finds it gone before writing and fails too.
+func (s *FileStore) breakLock(lockPath string) error {
+	aside := fmt.Sprintf("%s.stale-%d-%d", lockPath, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(lockPath, aside); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer os.Remove(aside)
+	info, err := os.Stat(aside)
+	if err != nil {
+		return err
+	}
+	if time.Since(info.ModTime()) <= s.StaleLock {
+		if err := os.Link(aside, lockPath); err != nil {
+			if errors.Is(err, os.ErrExist) {
+				return fmt.Errorf("sequence lock %s was taken while breaking a stale lock", lockPath)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// SQLStore keeps counters in a SQL table and relies on the database for
+// atomicity. It is written for SQLite (register a driver such as
+// modernc.org/sqlite or mattn/go-sqlite3 and pass the *sql.DB) and uses only
+// "?" placeholders and ON CONFLICT, so MySQL-compatible dialects need a
+// different store.
+type SQLStore struct {
+	db *sql.DB
+}
+
+const sqlSchema = `CREATE TABLE IF NOT EXISTS voucher_sequences (
+	namespace    TEXT    NOT NULL,
+	fiscal_year  TEXT    NOT NULL,
+	voucher_type TEXT    NOT NULL,
+	last_value   INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (namespace, fiscal_year, voucher_type)
+)`
+
+// NewSQLStore creates the voucher_sequences table if needed.
+func NewSQLStore(db *sql.DB) (*SQLStore, error) {
+	if _, err := db.Exec(sqlSchema); err != nil {
+		return nil, err
+	}
+	return &SQLStore{db: db}, nil
+}
+
+func (s *SQLStore) Increment(key Key) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO voucher_sequences (namespace, fiscal_year, voucher_type, last_value)
+		VALUES (?, ?, ?, 0) ON CONFLICT DO NOTHING`, key.Namespace, key.FiscalYear, key.VoucherType); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE voucher_sequences SET last_value = last_value + 1
+		WHERE namespace = ? AND fiscal_year = ? AND voucher_type = ?`, key.Namespace, key.FiscalYear, key.VoucherType); err != nil {
+		return 0, err
+	}
+	var n int64
+	if err := tx.QueryRow(`SELECT last_value FROM voucher_sequences
+		WHERE namespace = ? AND fiscal_year = ? AND voucher_type = ?`, key.Namespace, key.FiscalYear, key.VoucherType).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+func (s *SQLStore) Current(key Key) (int64, error) {
+	var n int64
+	err := s.db.QueryRow(`SELECT last_value FROM voucher_sequences
+		WHERE namespace = ? AND fiscal_year = ? AND voucher_type = ?`, key.Namespace, key.FiscalYear, key.VoucherType).Scan(&n)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return n, err
+}
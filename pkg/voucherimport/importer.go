@@ -0,0 +1,327 @@
+package voucherimport
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+)
+
+// Input columns, matched case-insensitively against the header row.
+const (
+	ColVoucherCode   = "voucher_code"
+	ColDate          = "date"
+	ColCurrencyCode  = "currency_code"
+	ColNarration     = "narration"
+	ColAccountCode   = "account_code"
+	ColTxnType       = "txn_type"
+	ColAmount        = "amount"
+	ColLineNarration = "line_narration"
+)
+
+// Result columns appended to the report. They are ignored on input, except
+// that a voucher whose rows all carry a voucher_id is skipped on re-import.
+const (
+	ColStatus    = "import_status"
+	ColVoucherID = "voucher_id"
+	ColError     = "import_error"
+)
+
+// Row statuses written to ColStatus.
+const (
+	StatusCreated = "CREATED"
+	StatusSkipped = "SKIPPED"
+	StatusError   = "ERROR"
+)
+
+var requiredColumns = []string{ColVoucherCode, ColDate, ColAccountCode, ColTxnType, ColAmount}
+
+// VoucherCreator creates journal vouchers. *journal.Service satisfies it.
+type VoucherCreator interface {
+	CreateJournalVoucher(jv journal.JournalVoucher) (*journal.JournalVoucher, error)
+}
+
+// RowResult is the outcome for one input row.
+type RowResult struct {
+	Status    string
+	VoucherID string
+	Error     string
+}
+
+// Report pairs the input sheet with a result per row.
+type Report struct {
+	Input   *Sheet
+	Results []RowResult
+}
+
+// Failed returns the number of rows that could not be imported.
+func (r *Report) Failed() int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == StatusError {
+			n++
+		}
+	}
+	return n
+}
+
+// Sheet returns the input rows with the result columns filled in, ready to be
+// written back in the input's format and re-imported after fixing.
+func (r *Report) Sheet() *Sheet {
+	in := r.Input
+	header := append([]string(nil), in.Header...)
+	cols := make([]int, 3)
+	for k, name := range []string{ColStatus, ColVoucherID, ColError} {
+		cols[k] = in.Column(name)
+		if cols[k] < 0 {
+			cols[k] = len(header)
+			header = append(header, name)
+		}
+	}
+
+	out := &Sheet{Header: header, Rows: make([][]string, len(in.Rows)), numeric: in.numeric}
+	for i, row := range in.Rows {
+		cells := make([]string, len(header))
+		copy(cells, row)
+		cells[cols[0]] = r.Results[i].Status
+		cells[cols[1]] = r.Results[i].VoucherID
+		cells[cols[2]] = r.Results[i].Error
+		out.Rows[i] = cells
+	}
+	return out
+}
+
+// Importer turns a sheet with one row per line item into journal vouchers.
+type Importer struct {
+	vouchers VoucherCreator
+	accounts journal.AccountLister
+
+	// DefaultCurrency is used when the currency_code column is missing or empty.
+	DefaultCurrency string
+	// DateSystem is the calendar of the date column. Excel serial dates are
+	// always read as AD.
+	DateSystem calendar.System
+}
+
+func NewImporter(vouchers VoucherCreator, accounts journal.AccountLister) *Importer {
+	return &Importer{vouchers: vouchers, accounts: accounts, DefaultCurrency: "NPR"}
+}
+
+// ImportFile reads a CSV or XLSX file, imports it and writes the per-row report
+// to reportPath, which should use the same extension as the input.
+func (im *Importer) ImportFile(ctx context.Context, inputPath, reportPath string) (*Report, error) {
+	sheet, err := ReadFile(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	report, err := im.Import(ctx, sheet)
+	if err != nil {
+		return nil, err
+	}
+	return report, WriteFile(reportPath, report.Sheet())
+}
+
+type voucherGroup struct {
+	code string
+	rows []int
+}
+
+// Import groups rows by voucher code, resolves account codes with one account
+// listing, validates each voucher and creates it. Problems are reported per
+// row; the returned error is only set when the import could not run at all.
+func (im *Importer) Import(ctx context.Context, sheet *Sheet) (*Report, error) {
+	for _, c := range requiredColumns {
+		if sheet.Column(c) < 0 {
+			return nil, fmt.Errorf("import sheet is missing column %q", c)
+		}
+	}
+
+	report := &Report{Input: sheet, Results: make([]RowResult, len(sheet.Rows))}
+	accounts := &cachedAccounts{lister: im.accounts}
+
+	codeCol, idCol := sheet.Column(ColVoucherCode), sheet.Column(ColVoucherID)
+	var groups []*voucherGroup
+	byCode := map[string]*voucherGroup{}
+	for i, row := range sheet.Rows {
+		if blank(row) {
+			continue
+		}
+		code := cell(row, codeCol)
+		if code == "" {
+			report.Results[i] = RowResult{Status: StatusError, Error: "voucher_code is required"}
+			continue
+		}
+		g, ok := byCode[code]
+		if !ok {
+			g = &voucherGroup{code: code}
+			byCode[code] = g
+			groups = append(groups, g)
+		}
+		g.rows = append(g.rows, i)
+	}
+
+	for _, g := range groups {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		if id := alreadyImported(sheet, g, idCol); id != "" {
+			for _, i := range g.rows {
+				report.Results[i] = RowResult{Status: StatusSkipped, VoucherID: id}
+			}
+			continue
+		}
+
+		jv, rowErrs := im.build(ctx, sheet, g, accounts)
+		if jv != nil {
+			created, err := im.vouchers.CreateJournalVoucher(*jv)
+			if err != nil {
+				rowErrs = map[int][]string{-1: {err.Error()}}
+			} else {
+				for _, i := range g.rows {
+					report.Results[i] = RowResult{Status: StatusCreated, VoucherID: created.ID}
+				}
+				continue
+			}
+		}
+
+		// Rows without an error of their own point at the first one with one,
+		// numbered as in the file with the header on row 1.
+		rejected := fmt.Sprintf("voucher %s rejected", g.code)
+		for n, i := range g.rows {
+			if len(rowErrs[n]) > 0 {
+				rejected = fmt.Sprintf("voucher %s rejected: see row %d", g.code, i+2)
+				break
+			}
+		}
+		for n, i := range g.rows {
+			msgs := append(append([]string(nil), rowErrs[-1]...), rowErrs[n]...)
+			if len(msgs) == 0 {
+				msgs = []string{rejected}
+			}
+			report.Results[i] = RowResult{Status: StatusError, Error: strings.Join(msgs, "; ")}
+		}
+	}
+	return report, nil
+}
+
+// alreadyImported returns the voucher ID recorded on every row of g by a
+// previous run, or "" if any row lacks one.
+func alreadyImported(sheet *Sheet, g *voucherGroup, idCol int) string {
+	if idCol < 0 {
+		return ""
+	}
+	id := cell(sheet.Rows[g.rows[0]], idCol)
+	for _, i := range g.rows {
+		if cell(sheet.Rows[i], idCol) != id {
+			return ""
+		}
+	}
+	return id
+}
+
+var itemField = regexp.MustCompile(`^items\[(\d+)\]`)
+
+// build turns the rows of one group into a voucher. Errors are keyed by the
+// row's position within the group; key -1 holds voucher-level errors.
+func (im *Importer) build(ctx context.Context, sheet *Sheet, g *voucherGroup, accounts journal.AccountLister) (*journal.JournalVoucher, map[int][]string) {
+	errs := map[int][]string{}
+	col := func(name string) int { return sheet.Column(name) }
+
+	first := sheet.Rows[g.rows[0]]
+	date, err := im.parseDate(cell(first, col(ColDate)), sheet.isNumeric(g.rows[0], col(ColDate)))
+	if err != nil {
+		errs[-1] = append(errs[-1], err.Error())
+	}
+	currency := cell(first, col(ColCurrencyCode))
+	if currency == "" {
+		currency = im.DefaultCurrency
+	}
+
+	b := journal.NewBuilder(accounts, g.code, date, currency).Narration(cell(first, col(ColNarration)))
+	for n, i := range g.rows {
+		row := sheet.Rows[i]
+
+		if d, _ := im.parseDate(cell(row, col(ColDate)), sheet.isNumeric(i, col(ColDate))); !d.Equal(date) {
+			errs[n] = append(errs[n], fmt.Sprintf("date %q differs from the voucher's first row", cell(row, col(ColDate))))
+		}
+		if c := cell(row, col(ColCurrencyCode)); c != "" && c != currency {
+			errs[n] = append(errs[n], fmt.Sprintf("currency %q differs from the voucher's first row", c))
+		}
+
+		amount, err := sheet.Amount(i, col(ColAmount), currency)
+		if err != nil {
+			errs[n] = append(errs[n], err.Error())
+			continue
+		}
+
+		accountCode, narration := cell(row, col(ColAccountCode)), cell(row, col(ColLineNarration))
+		switch strings.ToUpper(cell(row, col(ColTxnType))) {
+		case journal.TxnTypeDebit, "DR":
+			b.Debit(accountCode, amount, narration)
+		case journal.TxnTypeCredit, "CR":
+			b.Credit(accountCode, amount, narration)
+		default:
+			errs[n] = append(errs[n], fmt.Sprintf("txn_type must be DEBIT or CREDIT, got %q", cell(row, col(ColTxnType))))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	jv, err := b.Build(ctx)
+	if err == nil {
+		return jv, nil
+	}
+	verrs, ok := err.(journal.ValidationErrors)
+	if !ok {
+		errs[-1] = append(errs[-1], err.Error())
+		return nil, errs
+	}
+	for _, fe := range verrs {
+		n := -1
+		if m := itemField.FindStringSubmatch(fe.Field); m != nil {
+			n, _ = strconv.Atoi(m[1])
+		}
+		errs[n] = append(errs[n], fe.Error())
+	}
+	return nil, errs
+}
+
+// parseDate accepts YYYY-MM-DD in im.DateSystem or, from a numeric cell, an
+// Excel serial day number, which is how XLSX stores date cells.
+func (im *Importer) parseDate(s string, numeric bool) (calendar.Date, error) {
+	if serial, err := strconv.ParseFloat(s, 64); numeric && err == nil && serial > 0 {
+		excelEpoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+		return calendar.NewDate(excelEpoch.AddDate(0, 0, int(serial))), nil
+	}
+	d, err := calendar.ParseDate(s, im.DateSystem)
+	if err != nil {
+		return calendar.Date{}, fmt.Errorf("date must be in YYYY-MM-DD format (%s), got %q", im.DateSystem, s)
+	}
+	return d, nil
+}
+
+// cachedAccounts lists accounts once and serves every later call from memory.
+type cachedAccounts struct {
+	lister   journal.AccountLister
+	accounts []account.Account
+	loaded   bool
+}
+
+func (c *cachedAccounts) ListAccounts() ([]account.Account, error) {
+	if !c.loaded {
+		accounts, err := c.lister.ListAccounts()
+		if err != nil {
+			return nil, err
+		}
+		c.accounts, c.loaded = accounts, true
+	}
+	return c.accounts, nil
+}
@@ -0,0 +1,164 @@
+package voucherimport
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/money"
+)
+
+// Format is the file format of an import sheet.
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+// FormatFromPath picks the format from a file extension.
+func FormatFromPath(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".xlsx":
+		return FormatXLSX, nil
+	default:
+		return "", fmt.Errorf("unsupported import file %q; expected .csv or .xlsx", path)
+	}
+}
+
+// Sheet is a table of string cells with a header row.
+type Sheet struct {
+	Header []string
+	Rows   [][]string
+
+	// numeric marks the cells of Rows that an XLSX file stored as numbers,
+	// keyed by row and column. Dates in such cells are Excel serial days.
+	numeric map[[2]int]bool
+}
+
+// isNumeric reports whether Rows[row][col] was read from a numeric cell.
+func (s *Sheet) isNumeric(row, col int) bool {
+	return s.numeric[[2]int{row, col}]
+}
+
+// Column returns the index of a header, matched case-insensitively, or -1.
+func (s *Sheet) Column(name string) int {
+	for i, h := range s.Header {
+		if strings.EqualFold(strings.TrimSpace(h), name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Cell returns Rows[row][col] trimmed, or "" when the column is missing.
+func (s *Sheet) Cell(row, col int) string {
+	return cell(s.Rows[row], col)
+}
+
+// Amount parses Rows[row][col] as an amount in currency. Thousands separators
+// are allowed, and amounts from numeric cells are rounded to the currency, as
+// they hold binary floats such as 1234.5600000000001.
+func (s *Sheet) Amount(row, col int, currency string) (money.Money, error) {
+	amount, err := money.Parse(strings.ReplaceAll(s.Cell(row, col), ",", ""), currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	if s.isNumeric(row, col) {
+		amount = amount.RoundToCurrency(money.RoundHalfEven)
+	}
+	return amount, nil
+}
+
+// cell returns row[col] trimmed, or "" when the column is missing.
+func cell(row []string, col int) string {
+	if col < 0 || col >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[col])
+}
+
+// blank reports whether every cell of row is empty.
+func blank(row []string) bool {
+	for _, c := range row {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadCSV reads a sheet from CSV. The first record is the header.
+func ReadCSV(r io.Reader) (*Sheet, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("csv has no header row")
+	}
+	return &Sheet{Header: records[0], Rows: records[1:]}, nil
+}
+
+// WriteCSV writes a sheet as CSV.
+func WriteCSV(w io.Writer, s *Sheet) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(s.Header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(s.Rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// ReadFile reads a CSV or XLSX sheet depending on the file extension.
+func ReadFile(path string) (*Sheet, error) {
+	format, err := FormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if format == FormatCSV {
+		return ReadCSV(f)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return ReadXLSX(f, info.Size())
+}
+
+// WriteFile writes a sheet as CSV or XLSX depending on the file extension.
+func WriteFile(path string, s *Sheet) error {
+	format, err := FormatFromPath(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if format == FormatCSV {
+		err = WriteCSV(f, s)
+	} else {
+		err = WriteXLSX(f, s)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,214 @@
+package voucherimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rohankarmacharya/TigIntegration/pkg/account"
+	"github.com/rohankarmacharya/TigIntegration/pkg/calendar"
+	"github.com/rohankarmacharya/TigIntegration/pkg/journal"
+)
+
+type stubAccounts struct{ calls int }
+
+func (s *stubAccounts) ListAccounts() ([]account.Account, error) {
+	s.calls++
+	return []account.Account{
+		{ID: "acc-rent", Code: "EX0001"},
+		{ID: "acc-salary", Code: "EX0002"},
+		{ID: "acc-bank", Code: "BA0001"},
+	}, nil
+}
+
+type stubVouchers struct{ created []journal.JournalVoucher }
+
+func (s *stubVouchers) CreateJournalVoucher(jv journal.JournalVoucher) (*journal.JournalVoucher, error) {
+	jv.ID = fmt.Sprintf("jv-%d", len(s.created)+1)
+	s.created = append(s.created, jv)
+	return &jv, nil
+}
+
+const sampleCSV = `voucher_code,date,currency_code,narration,account_code,txn_type,amount,line_narration
+JV-1,2024-07-16,NPR,Rent,EX0001,DEBIT,"25,000.00",Shrawan rent
+JV-1,2024-07-16,NPR,Rent,BA0001,CREDIT,25000.00,
+JV-2,2024-07-31,NPR,Salary,EX0002,DR,50000,
+JV-2,2024-07-31,NPR,Salary,BA0001,CR,49000,
+JV-3,2024-07-31,NPR,Typo,EX0404,DEBIT,10,
+JV-3,2024-07-31,NPR,Typo,BA0001,CREDIT,10,
+`
+
+func TestImportCSV(t *testing.T) {
+	sheet, err := ReadCSV(strings.NewReader(sampleCSV))
+	if err != nil {
+		t.Fatalf("ReadCSV failed: %v", err)
+	}
+
+	accounts, vouchers := &stubAccounts{}, &stubVouchers{}
+	report, err := NewImporter(vouchers, accounts).Import(context.Background(), sheet)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	if accounts.calls != 1 {
+		t.Fatalf("expected accounts to be listed once, got %d", accounts.calls)
+	}
+	if len(vouchers.created) != 1 || vouchers.created[0].Code != "JV-1" {
+		t.Fatalf("expected only JV-1 to be created, got %+v", vouchers.created)
+	}
+	if report.Failed() != 4 {
+		t.Fatalf("expected 4 failed rows, got %d", report.Failed())
+	}
+	if !strings.Contains(report.Results[2].Error, "total debits") {
+		t.Fatalf("expected balance error on JV-2, got %q", report.Results[2].Error)
+	}
+	if !strings.Contains(report.Results[4].Error, "EX0404") || strings.Contains(report.Results[5].Error, "EX0404") {
+		t.Fatalf("expected unknown account error on the offending row only, got %q / %q", report.Results[4].Error, report.Results[5].Error)
+	}
+	if report.Results[5].Error != "voucher JV-3 rejected: see row 6" {
+		t.Fatalf("expected the sibling row to point at row 6, got %q", report.Results[5].Error)
+	}
+
+	// Fix JV-2 in the report and re-import it; JV-1 must not be created again.
+	out := report.Sheet()
+	out.Rows[3][6] = "50000"
+	vouchers.created = nil
+	report, err = NewImporter(vouchers, accounts).Import(context.Background(), out)
+	if err != nil {
+		t.Fatalf("re-Import failed: %v", err)
+	}
+	if len(vouchers.created) != 1 || vouchers.created[0].Code != "JV-2" {
+		t.Fatalf("expected only JV-2 to be created on re-import, got %+v", vouchers.created)
+	}
+	if report.Results[0].Status != StatusSkipped || report.Results[0].VoucherID != "jv-1" {
+		t.Fatalf("expected JV-1 to be skipped, got %+v", report.Results[0])
+	}
+}
+
+func TestImportBSDates(t *testing.T) {
+	sheet, err := ReadCSV(strings.NewReader(`voucher_code,date,account_code,txn_type,amount
+JV-1,2081-04-01,EX0001,DEBIT,100
+JV-1,2081/04/01,BA0001,CREDIT,100
+`))
+	if err != nil {
+		t.Fatalf("ReadCSV failed: %v", err)
+	}
+
+	vouchers := &stubVouchers{}
+	im := NewImporter(vouchers, &stubAccounts{})
+	im.DateSystem = calendar.BS
+	report, err := im.Import(context.Background(), sheet)
+	if err != nil || report.Failed() != 0 {
+		t.Fatalf("Import failed: %v %+v", err, report.Results)
+	}
+	if got := vouchers.created[0].Date.Format(calendar.AD); got != "2024-07-16" {
+		t.Fatalf("expected 1 Shrawan 2081 to be 2024-07-16, got %s", got)
+	}
+}
+
+func TestXLSXRoundTrip(t *testing.T) {
+	in := &Sheet{
+		Header: []string{"voucher_code", "amount", "narration"},
+		Rows: [][]string{
+			{"JV-1", "25000.00", "Rent & <utilities>"},
+			{"JV-1", "", "  padded  "},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteXLSX(&buf, in); err != nil {
+		t.Fatalf("WriteXLSX failed: %v", err)
+	}
+	out, err := ReadXLSX(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("ReadXLSX failed: %v", err)
+	}
+
+	if strings.Join(out.Header, "|") != strings.Join(in.Header, "|") {
+		t.Fatalf("header changed: %v", out.Header)
+	}
+	if out.Rows[0][2] != "Rent & <utilities>" || out.Rows[1][1] != "" || out.Rows[1][2] != "  padded  " {
+		t.Fatalf("rows changed: %q", out.Rows)
+	}
+}
+
+func TestColumnNames(t *testing.T) {
+	for _, col := range []int{0, 25, 26, 51, 701, 702} {
+		idx, err := columnIndex(columnName(col) + "1")
+		if err != nil || idx != col {
+			t.Errorf("column %d -> %s -> %d (%v)", col, columnName(col), idx, err)
+		}
+	}
+}
+
+// xlsxWith returns a workbook whose only worksheet has the given sheetData.
+func xlsxWith(t *testing.T, sheetData string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range map[string]string{
+		"[Content_Types].xml":        xlsxContentTypes,
+		"_rels/.rels":                xlsxRootRels,
+		"xl/workbook.xml":            xlsxWorkbookXML,
+		"xl/_rels/workbook.xml.rels": xlsxWorkbookRels,
+		"xl/worksheets/sheet1.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + sheetData + `</sheetData></worksheet>`,
+	} {
+		fw, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestXLSXNumericCellsAndSparseRows(t *testing.T) {
+	str := func(ref, v string) string {
+		return fmt.Sprintf(`<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, v)
+	}
+	num := func(ref, v string) string { return fmt.Sprintf(`<c r="%s"><v>%s</v></c>`, ref, v) }
+	// Row 3 is blank and left out of the file, as Excel does. Dates are serial
+	// days; amounts carry binary float noise.
+	b := xlsxWith(t, `<row r="1">`+str("A1", "voucher_code")+str("B1", "date")+str("C1", "account_code")+str("D1", "txn_type")+str("E1", "amount")+`</row>`+
+		`<row r="2">`+str("A2", "JV-1")+num("B2", "45489")+str("C2", "EX0001")+str("D2", "DEBIT")+num("E2", "1234.5600000000001")+`</row>`+
+		`<row r="4">`+str("A4", "JV-1")+num("B4", "45489")+str("C4", "BA0001")+str("D4", "CREDIT")+num("E4", "1.23456E3")+`</row>`+
+		`<row r="5">`+str("A5", "JV-2")+str("B5", "45489")+str("C5", "EX0001")+str("D5", "DEBIT")+num("E5", "10")+`</row>`+
+		`<row r="6">`+str("A6", "JV-2")+str("B6", "45489")+str("C6", "BA0001")+str("D6", "CREDIT")+num("E6", "10")+`</row>`)
+
+	sheet, err := ReadXLSX(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("ReadXLSX failed: %v", err)
+	}
+	if len(sheet.Rows) != 5 || len(sheet.Rows[1]) != 0 || sheet.Rows[2][0] != "JV-1" {
+		t.Fatalf("expected the blank row to keep its place, got %q", sheet.Rows)
+	}
+	if sheet.Rows[2][4] != "1234.56" {
+		t.Fatalf("expected exponent form to be expanded, got %q", sheet.Rows[2][4])
+	}
+
+	vouchers := &stubVouchers{}
+	im := NewImporter(vouchers, &stubAccounts{})
+	report, err := im.Import(context.Background(), sheet)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if len(vouchers.created) != 1 || report.Results[1].Status != "" {
+		t.Fatalf("expected JV-1 created and the blank row ignored, got %+v", report.Results)
+	}
+	jv := vouchers.created[0]
+	if jv.Date.Format(calendar.AD) != "2024-07-16" || jv.Items[0].Amount.String() != "1234.56" {
+		t.Fatalf("unexpected voucher %s %s", jv.Date.Format(calendar.AD), jv.Items[0].Amount)
+	}
+	if r := report.Results[3]; r.Status != StatusError || !strings.Contains(r.Error, "YYYY-MM-DD") {
+		t.Fatalf("expected a serial number typed as text to be rejected, got %+v", r)
+	}
+}